@@ -1277,10 +1277,10 @@ func parsePDU2(settings *settings.GXDLMSSettings, cipher settings.GXICipher,
 				case enums.ApplicationContextNameShortNameWithCiphering:
 					msg = "\nMeter expects Short Name referencing with secured connection."
 				}
-				return ret, fmt.Errorf("association rejected: %v %v%s",
-					enums.AssociationResultPermanentRejected,
-					enums.SourceDiagnosticApplicationContextNameNotSupported,
-					msg)
+				e := dlmserrors.NewGXDLMSAssociationError(enums.AssociationResultPermanentRejected,
+					enums.SourceDiagnosticApplicationContextNameNotSupported)
+				e.Message = msg
+				return ret, e
 			}
 		case uint8(constants.BerTypeContext) | uint8(constants.BerTypeConstructed) | uint8(internal.PduTypeCalledApTitle):
 			if v, err := buff.Uint8(); err != nil || v != 3 {
@@ -1673,7 +1673,7 @@ func parsePDU2(settings *settings.GXDLMSSettings, cipher settings.GXICipher,
 	}
 	if !settings.IsServer() && xml == nil &&
 		resultComponent != enums.AssociationResultAccepted && ret != 0 {
-		return ret, fmt.Errorf("association rejected: %v %v", resultComponent, ret)
+		return ret, dlmserrors.NewGXDLMSAssociationError(resultComponent, ret)
 	}
 	return ret, nil
 }
@@ -1853,16 +1853,20 @@ func generateAARE(conf *settings.GXDLMSSettings,
 		return err
 	}
 	// Tag
-	if _, ok := diagnostic.(enums.SourceDiagnostic); ok {
+	var value byte
+	switch v := diagnostic.(type) {
+	case enums.SourceDiagnostic:
+		value = byte(v)
 		err = data.SetUint8(0xA1)
-		if err != nil {
-			return err
-		}
-	} else {
+	case enums.AcseServiceProvider:
+		value = byte(v)
 		err = data.SetUint8(0xA2)
-		if err != nil {
-			return err
-		}
+	default:
+		value = diagnostic.(byte)
+		err = data.SetUint8(0xA2)
+	}
+	if err != nil {
+		return err
 	}
 	err = data.SetUint8(3)
 	if err != nil {
@@ -1876,7 +1880,7 @@ func generateAARE(conf *settings.GXDLMSSettings,
 	if err != nil {
 		return err
 	}
-	err = data.SetUint8(diagnostic.(byte))
+	err = data.SetUint8(value)
 	if err != nil {
 		return err
 	}
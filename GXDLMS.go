@@ -35,6 +35,7 @@ package dlms
 //---------------------------------------------------------------------------
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
@@ -557,6 +558,10 @@ func getHdlcData(server bool,
 		}
 		return getHdlcData(server, settings, reply, data, notify)
 	}
+	// Remove acknowledged I-frames from the retransmission queue.
+	if data.xml == nil && settings.Hdlc != nil && ((frame&0x1) == 0 || (frame&0x3) == 0x1) {
+		settings.Hdlc.Acknowledge(frame >> 5)
+	}
 	// If server is using same client and server address for notifications.
 	if (frame == 0x13 || frame == 0x3) && !isNotify && notify != nil {
 		isNotify = true
@@ -1398,7 +1403,8 @@ func handleReadResponse(settings *settings.GXDLMSSettings, reply *GXReplyData, i
 	// If we are reading value first time or block is handed.
 	first := cnt == 0 || reply.commandType == uint8(constants.SingleReadResponseDataBlockResult)
 	if first {
-		cnt, err := types.GetObjectCount(reply.Data)
+		var err error
+		cnt, err = types.GetObjectCount(reply.Data)
 		if err != nil {
 			return false, err
 		}
@@ -1471,6 +1477,12 @@ func handleReadResponse(settings *settings.GXDLMSSettings, reply *GXReplyData, i
 				return false, err
 			}
 			reply.Error = int(v)
+			if reply.xml == nil && cnt != 1 {
+				if reply.ItemErrors == nil {
+					reply.ItemErrors = make(map[int]enums.ErrorCode)
+				}
+				reply.ItemErrors[len(values)+len(reply.ItemErrors)] = enums.ErrorCode(v)
+			}
 			if reply.xml != nil {
 				if standardXml {
 					reply.xml.AppendStartTag(int(internal.TranslatorTagsChoice), "", "", false)
@@ -1880,6 +1892,7 @@ func handleGetResponseWithList(settings *settings.GXDLMSSettings, reply *GXReply
 		return err
 	}
 	values := make([]any, 0, cnt)
+	reply.ItemErrors = nil
 	if reply.xml != nil {
 		reply.xml.AppendStartTag(int(internal.TranslatorTagsResult), "Qty", reply.xml.IntegerToHex(cnt, 2, false), false)
 	}
@@ -1894,6 +1907,12 @@ func handleGetResponseWithList(settings *settings.GXDLMSSettings, reply *GXReply
 				return err
 			}
 			reply.Error = int(ch)
+			if reply.xml == nil {
+				if reply.ItemErrors == nil {
+					reply.ItemErrors = make(map[int]enums.ErrorCode)
+				}
+				reply.ItemErrors[pos] = enums.ErrorCode(ch)
+			}
 		} else {
 			reply.ReadPosition = reply.Data.Position()
 			if reply.xml != nil {
@@ -2255,7 +2274,7 @@ func handleAccessResponse(settings *settings.GXDLMSSettings, reply *GXReplyData)
 		if err != nil {
 			return err
 		}
-		reply.Time = ret.(*types.GXDateTime).Value
+		reply.Time = ret.(types.GXDateTime).Value
 	}
 	if reply.xml != nil {
 		reply.xml.AppendStartTag(int(enums.CommandAccessResponse), "", "", false)
@@ -2330,6 +2349,7 @@ func handleDataNotification(settings *settings.GXDLMSSettings, reply *GXReplyDat
 	if err != nil {
 		return err
 	}
+	reply.invokeId = invokeId
 	reply.Time = time.Time{}
 	len, err := reply.Data.Uint8()
 	if err != nil {
@@ -2344,7 +2364,7 @@ func handleDataNotification(settings *settings.GXDLMSSettings, reply *GXReplyDat
 		if err != nil {
 			return err
 		}
-		reply.Time = ret.(*types.GXDateTime).Value
+		reply.Time = ret.(types.GXDateTime).Value
 	}
 	if reply.xml != nil {
 		reply.xml.AppendStartTag(int(enums.CommandDataNotification), "", "", false)
@@ -2533,6 +2553,14 @@ func receiverReady(settings *settings.GXDLMSSettings, reply *GXReplyData) ([]byt
 	var data [][]byte
 	var err error
 	if reply.moreData == enums.RequestTypesGBT {
+		if reply.gbtLost {
+			// Acknowledge the last received block and the peer sends lost blocks again.
+			err = settings.IncreaseGbtRetries()
+			if err != nil {
+				return nil, err
+			}
+			reply.gbtLost = false
+		}
 		p := NewGXDLMSLNParameters(settings, 0, enums.CommandGeneralBlockTransfer, 0, nil, nil, 0xff, enums.CommandNone)
 		p.gbtWindowSize = reply.gbtWindowSize
 		p.blockNumberAck = reply.BlockNumber
@@ -3028,11 +3056,9 @@ func getLNPdu(p *GXDLMSLNParameters, reply *types.GXByteBuffer) error {
 				}
 			}
 		}
-		// TODO:
-		// if (reply.Size != 0 && p.command != enums.CommandGeneralBlockTransfer && p.settings.CryptoNotifier != nil && p.settings.CryptoNotifier.pdu != nil)
-		// {
-		// p.settings.CryptoNotifier.pdu(p.settings.CryptoNotifier, reply.Array())
-		// }
+		if ciphering && p.settings.Trace != nil && reply.Size() != 0 && p.command != enums.CommandGeneralBlockTransfer {
+			p.pdu = bytes.Clone(reply.Array())
+		}
 		if ciphering && reply.Size() != 0 && p.command != enums.CommandReleaseRequest && (!p.multipleBlocks || (p.settings.NegotiatedConformance&enums.ConformanceGeneralBlockTransfer) == 0) {
 			// GBT ciphering is done for all the data, not just block.
 			var tmp []byte
@@ -3138,10 +3164,12 @@ func getLnMessages(p *GXDLMSLNParameters) ([][]byte, error) {
 		frame = 0x13
 	}
 	for {
+		p.pdu = nil
 		err = getLNPdu(p, &reply)
 		if err != nil {
 			return nil, err
 		}
+		pdu := tracedPdu(p.settings, &reply, p.pdu)
 		p.lastBlock = true
 		if p.attributeDescriptor == nil {
 			p.settings.BlockIndex++
@@ -3152,48 +3180,20 @@ func getLnMessages(p *GXDLMSLNParameters) ([][]byte, error) {
 				panic("assert failed: MaxPduSize() < reply.Size")
 			}
 		}
-		for reply.Position() != reply.Size() {
-			switch p.settings.InterfaceType {
-			case enums.InterfaceTypeWRAPPER, enums.InterfaceTypePrimeDcWrapper:
-				tmp, err := getWrapperFrame(p.settings, p.command, &reply)
-				if err != nil {
-					return nil, err
-				}
-				messages = append(messages, tmp)
-			case enums.InterfaceTypeHDLC, enums.InterfaceTypeHdlcWithModeE:
-				tmp, err := getHdlcFrame(p.settings, frame, &reply, true)
-				if err != nil {
-					return nil, err
-				}
-				messages = append(messages, tmp)
-				if reply.Position() != reply.Size() {
-					frame = p.settings.NextSend(false)
-				}
-			case enums.InterfaceTypePDU:
-				messages = append(messages, reply.Array())
-				reply.SetPosition(reply.Size())
-			case enums.InterfaceTypePlc:
-				tmp, err := getPlcFrame(p.settings, 0x90, &reply)
-				if err != nil {
-					return nil, err
-				}
-				messages = append(messages, tmp)
-			case enums.InterfaceTypePlcHdlc:
-				tmp, err := getMacHdlcFrame(p.settings, frame, 0, &reply)
-				if err != nil {
-					return nil, err
-				}
-				messages = append(messages, tmp)
-			case enums.InterfaceTypeSMS:
-				tmp, err := getSMSFrame(p.settings, p.command, &reply)
-				if err != nil {
-					return nil, err
-				}
-				messages = append(messages, tmp)
-			default:
-				panic("InterfaceType out of range")
+		// Sent GBT blocks are kept until the peer acknowledges them.
+		if p.command == enums.CommandGeneralBlockTransfer && p.data != nil {
+			block, err := reply.SubArray(0, reply.Size())
+			if err != nil {
+				return nil, err
 			}
+			p.settings.AddGbtBlock(uint16(p.blockIndex-1), block)
 		}
+		tmp, err := getFrames(p.settings, p.command, frame, &reply)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, tmp...)
+		traceSent(p.settings, tmp, pdu)
 		reply.Clear()
 		frame = 0
 		if p.data == nil || p.data.Position() == p.data.Size() {
@@ -3203,6 +3203,68 @@ func getLnMessages(p *GXDLMSLNParameters) ([][]byte, error) {
 	return messages, err
 }
 
+// getFrames returns the PDU split to the frames of the used interface type.
+//
+// Parameters:
+//
+//	settings: DLMS settings.
+//	command: Command.
+//	frame: HDLC frame sequence number. If zero new is generated.
+//	reply: PDU to send.
+//
+// Returns:
+//
+//	Generated frames.
+func getFrames(settings *settings.GXDLMSSettings, command enums.Command, frame byte, reply *types.GXByteBuffer) ([][]byte, error) {
+	messages := make([][]byte, 0)
+	// Amount of HDLC frames in the PDU.
+	count := 0
+	for reply.Position() != reply.Size() {
+		switch settings.InterfaceType {
+		case enums.InterfaceTypeWRAPPER, enums.InterfaceTypePrimeDcWrapper:
+			tmp, err := getWrapperFrame(settings, command, reply)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, tmp)
+		case enums.InterfaceTypeHDLC, enums.InterfaceTypeHdlcWithModeE:
+			count++
+			tmp, err := getHdlcFrame(settings, frame, reply, isHdlcFinalFrame(settings, frame, count, reply))
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, tmp)
+			if reply.Position() != reply.Size() {
+				frame = settings.NextSend(false)
+			}
+		case enums.InterfaceTypePDU:
+			messages = append(messages, reply.Array())
+			reply.SetPosition(reply.Size())
+		case enums.InterfaceTypePlc:
+			tmp, err := getPlcFrame(settings, 0x90, reply)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, tmp)
+		case enums.InterfaceTypePlcHdlc:
+			tmp, err := getMacHdlcFrame(settings, frame, 0, reply)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, tmp)
+		case enums.InterfaceTypeSMS:
+			tmp, err := getSMSFrame(settings, command, reply)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, tmp)
+		default:
+			panic("InterfaceType out of range")
+		}
+	}
+	return messages, nil
+}
+
 // getSnMessages returns the get all Short Name messages. Client uses this to generate messages.
 //
 // Parameters:
@@ -3226,10 +3288,15 @@ func getSnMessages(p *GXDLMSSNParameters) ([][]byte, error) {
 	}
 
 	for {
+		// Amount of HDLC frames in the PDU.
+		count := 0
+		p.pdu = nil
 		err := getSNPdu(p, &reply)
 		if err != nil {
 			return nil, err
 		}
+		pdu := tracedPdu(p.Settings, &reply, p.pdu)
+		first := len(messages)
 		if p.Command != enums.CommandAarq && p.Command != enums.CommandAare {
 			if int(p.Settings.MaxPduSize()) < reply.Size() {
 				panic("assert failed: MaxPduSize() < reply.Size")
@@ -3246,7 +3313,8 @@ func getSnMessages(p *GXDLMSSNParameters) ([][]byte, error) {
 				messages = append(messages, tmp)
 			} else if p.Settings.InterfaceType == enums.InterfaceTypeHDLC ||
 				p.Settings.InterfaceType == enums.InterfaceTypeHdlcWithModeE {
-				tmp, err := getHdlcFrame(p.Settings, frame, &reply, true)
+				count++
+				tmp, err := getHdlcFrame(p.Settings, frame, &reply, isHdlcFinalFrame(p.Settings, frame, count, &reply))
 				if err != nil {
 					return nil, err
 				}
@@ -3280,6 +3348,7 @@ func getSnMessages(p *GXDLMSSNParameters) ([][]byte, error) {
 			}
 		}
 
+		traceSent(p.Settings, messages[first:], pdu)
 		reply.Clear()
 		frame = 0
 
@@ -3438,6 +3507,9 @@ func getSNPdu(p *GXDLMSSNParameters, reply *types.GXByteBuffer) error {
 		}
 		s := settings.NewAesGcmParameter(tag, p.Settings, cipher.Security(), cipher.SecuritySuite(), uint64(cipher.InvocationCounter()), cipher.SystemTitle(), bk, ak)
 		cipher.SetInvocationCounter(cipher.InvocationCounter() + 1)
+		if p.Settings.Trace != nil {
+			p.pdu = bytes.Clone(reply.Array())
+		}
 		tmp, err := settings.EncryptAesGcm(s, reply.Array())
 		if err != nil {
 			return err
@@ -3641,6 +3713,104 @@ func getSMSFrame(settings *settings.GXDLMSSettings, command enums.Command, data
 	return bb.Array(), nil
 }
 
+// getHdlcControlIndex returns the position of the control field in the HDLC frame.
+//
+// Parameters:
+//
+//	frame: HDLC frame.
+//
+// Returns:
+//
+//	Position of the control field or -1 if frame is not a HDLC frame.
+func getHdlcControlIndex(frame []byte) int {
+	if len(frame) < 9 || frame[0] != internal.HDLCFrameStartEnd {
+		return -1
+	}
+	// BOP and frame format are skipped.
+	pos := 3
+	// Address is ended when the lowest bit is set.
+	for addresses := 0; addresses != 2; pos++ {
+		if pos == len(frame) {
+			return -1
+		}
+		if (frame[pos] & 0x1) != 0 {
+			addresses++
+		}
+	}
+	return pos
+}
+
+// setHdlcPollBit sets the poll bit for the HDLC frame and updates the checksums.
+//
+// Parameters:
+//
+//	frame: HDLC frame.
+//
+// Returns:
+//
+//	HDLC frame where poll bit is set.
+func setHdlcPollBit(frame []byte) ([]byte, error) {
+	pos := getHdlcControlIndex(frame)
+	if pos == -1 {
+		return nil, errors.New("invalid HDLC frame")
+	}
+	if (frame[pos] & 0x10) != 0 {
+		return frame, nil
+	}
+	bb := types.GXByteBuffer{}
+	err := bb.Set(frame[:pos])
+	if err != nil {
+		return nil, err
+	}
+	err = bb.SetUint8(frame[pos] | 0x10)
+	if err != nil {
+		return nil, err
+	}
+	err = bb.SetUint16(countFCS16(bb.Array(), 1, bb.Size()-1))
+	if err != nil {
+		return nil, err
+	}
+	// Information field is between header CRC and frame CRC.
+	if len(frame) > pos+4 {
+		err = bb.Set(frame[pos+3 : len(frame)-3])
+		if err != nil {
+			return nil, err
+		}
+		err = bb.SetUint16(countFCS16(bb.Array(), 1, bb.Size()-1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = bb.SetUint8(internal.HDLCFrameStartEnd)
+	if err != nil {
+		return nil, err
+	}
+	return bb.Array(), nil
+}
+
+// isHdlcFinalFrame returns true if the poll/final bit is set for the HDLC frame.
+// When the window size is bigger than one, the poll bit is set only for the last frame
+// of the window and the last frame of the PDU.
+//
+// Parameters:
+//
+//	settings: DLMS settings.
+//	frame: HDLC frame sequence number.
+//	count: One based index of the frame in the PDU.
+//	data: Data to send.
+//
+// Returns:
+//
+//	True, if poll/final bit is set.
+func isHdlcFinalFrame(settings *settings.GXDLMSSettings, frame uint8, count int, data *types.GXByteBuffer) bool {
+	// UI frames are not windowed.
+	if frame == 0x13 || frame == 0x3 || settings.Hdlc == nil {
+		return true
+	}
+	window := int(settings.Hdlc.WindowSizeTX())
+	return window < 2 || count%window == 0 || data.Available() <= int(settings.Hdlc.MaxInfoTX())
+}
+
 // getHdlcFrame returns the get HDLC frame for data.
 //
 // Parameters:
@@ -3766,6 +3936,10 @@ func getHdlcFrame(settings *settings.GXDLMSSettings, frame uint8, data *types.GX
 	if err != nil {
 		return nil, err
 	}
+	// I-frames are kept until the peer acknowledges them so they can be sent again after REJ or timeout.
+	if (frame&0x1) == 0 && settings.Hdlc != nil {
+		settings.Hdlc.AddUnacknowledged(bb.Array())
+	}
 	if data != nil {
 		// Remove sent data in server side.
 		if settings.IsServer() {
@@ -4213,14 +4387,35 @@ func handleGbt(settings *settings.GXDLMSSettings, data *GXReplyData) error {
 		return err
 	}
 	if data.xml == nil {
+		// Remove the blocks that peer has received.
+		if settings.AcknowledgeGbtBlocks(bna, windowSize) {
+			// Peer has not received all the sent blocks and they must be sent again.
+			data.BlockNumberAck = bna
+			data.gbtRetransmit = true
+			data.Data.SetSize(index)
+			data.command = enums.CommandNone
+			return nil
+		}
 		// Remove existing data when first block is received.
 		if bn == 1 {
 			index = 0
+		} else if bn != data.BlockNumber+1 {
+			// Block is received twice or previous block is lost.
+			// Blocks after the last received block are ignored and the peer sends them again.
+			if bn > data.BlockNumber {
+				data.gbtLost = true
+			}
+			data.streaming = (bc & 0x40) != 0
+			data.moreData |= enums.RequestTypesGBT
+			data.Data.SetSize(index)
+			data.command = enums.CommandNone
+			return nil
 		} else if bna != uint16(settings.BlockIndex-1) {
 			data.Data.SetSize(index)
 			data.command = enums.CommandNone
 			return nil
 		}
+		settings.ResetGbtRetries()
 	}
 	data.BlockNumber = bn
 	data.BlockNumberAck = bna
@@ -4426,6 +4621,9 @@ func getValueFromData(settings *settings.GXDLMSSettings, reply *GXReplyData) err
 				// Add items to collection.
 				reply.Value = append(reply.Value.(types.GXArray), a...)
 			}
+			reply.ReadPosition = data.Position()
+			// Element count.
+			reply.TotalCount = info.Count
 		} else {
 			reply.DataType = info.Type
 			reply.Value = value
@@ -4731,6 +4929,13 @@ func GetPdu(conf *settings.GXDLMSSettings, data *GXReplyData) error {
 		}
 		cmd = enums.Command(ch)
 		data.command = cmd
+		if conf.Trace != nil {
+			// Ciphered PDU is replaced with the decrypted PDU when it's handled.
+			data.pdu, err = data.Data.SubArray(index, data.Data.Size()-index)
+			if err != nil {
+				return err
+			}
+		}
 		if conf.Closing {
 			conf.Closing = false
 			if data.xml == nil && cmd != enums.CommandReleaseResponse && cmd != enums.CommandDisconnectMode && cmd != enums.CommandConfirmedServiceError && cmd != enums.CommandExceptionResponse && cmd != enums.CommandAare {
@@ -4801,6 +5006,9 @@ func GetPdu(conf *settings.GXDLMSSettings, data *GXReplyData) error {
 			if data.xml != nil || (!conf.IsServer() && (data.moreData&enums.RequestTypesFrame) == 0) {
 				err = handleSetRequest(conf, nil, data.Data, nil, data.xml, enums.CommandNone)
 			}
+		case enums.CommandUnconfirmedWriteRequest:
+			// Server handles this.
+			break
 		case enums.CommandMethodRequest:
 			if data.xml != nil || (!conf.IsServer() && (data.moreData&enums.RequestTypesFrame) == 0) {
 				err = handleMethodRequest(conf, nil, data.Data, nil, nil, data.xml, enums.CommandNone)
@@ -39,6 +39,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"reflect"
 	"time"
 
@@ -115,6 +116,10 @@ type GXDLMSClient struct {
 	// New DLMS Conformance Tests tests expect protected release.
 	// It's not optional anymore.
 	UseProtectedRelease bool
+
+	// Object and attribute or method of the last read, write or method request.
+	// Target is given to the reply so the data access error can tell what failed.
+	target requestTarget
 }
 
 // Gets the DLMS settings object containing all communication parameters.
@@ -632,6 +637,41 @@ func (g *GXDLMSClient) SetOverwriteAttributeAccessRights(value bool) error {
 	return nil
 }
 
+// setTarget saves the object and the attribute or the method of the request.
+//
+// Parameters:
+//
+//	name: Object short name or Logical Name.
+//	objectType: Object type.
+//	index: Attribute or method index.
+//	method: Is index a method index.
+func (g *GXDLMSClient) setTarget(name any, objectType enums.ObjectType, index int, method bool) {
+	g.target = requestTarget{objectType: objectType, index: index, method: method}
+	if ln, ok := name.(string); ok {
+		g.target.logicalName = ln
+	} else if sn, err := shortName(name); err == nil {
+		if obj := g.Objects().FindBySN(sn); obj != nil {
+			g.target.logicalName = obj.Base().LogicalName()
+		}
+	}
+}
+
+// shortName returns the base name of the object as unsigned value.
+// Object short names are signed, but the name can be also given as unsigned.
+func shortName(name any) (uint16, error) {
+	switch v := name.(type) {
+	case int16:
+		return uint16(v), nil
+	case uint16:
+		return v, nil
+	case int:
+		if v >= math.MinInt16 && v <= math.MaxUint16 {
+			return uint16(v), nil
+		}
+	}
+	return 0, gxcommon.ErrInvalidArgument
+}
+
 func createDLMSObject(settings *settings.GXDLMSSettings,
 	ClassID uint16,
 	Version any,
@@ -652,7 +692,12 @@ func createDLMSObject(settings *settings.GXDLMSSettings,
 		return nil, err
 	}
 	if obj != nil {
-		updateObjectData(obj, type_, Version, accessRights.(types.GXStructure), lnVersion)
+		// Short name object list doesn't contain the access rights.
+		if rights, ok := accessRights.(types.GXStructure); ok {
+			updateObjectData(obj, type_, Version, rights, lnVersion)
+		} else if v, ok := Version.(uint8); ok {
+			obj.Base().Version = v
+		}
 	}
 	return obj, nil
 }
@@ -777,12 +822,12 @@ func (g *GXDLMSClient) parseSNObjects(buff *types.GXByteBuffer,
 		if err != nil {
 			return nil, err
 		}
-		objects := ret.(types.GXArray)
+		objects, ok := ret.(types.GXStructure)
 		info.Clear()
-		if len(objects) != 4 {
+		if !ok || len(objects) != 4 {
 			return nil, errors.New("Invalid structure format.")
 		}
-		baseName := objects[0].(int8)
+		baseName := objects[0].(int16)
 		ot := objects[1].(uint16)
 		comp, err := createDLMSObject(g.settings, ot, objects[2], baseName, objects[3], nil, 2)
 		if err != nil {
@@ -902,6 +947,7 @@ func (g *GXDLMSClient) Method2(name any,
 	}
 	var err error
 	g.settings.ResetBlockIndex()
+	g.setTarget(name, objectType, index, true)
 	if type_ == enums.DataTypeNone && value != nil {
 		type_, err = internal.GetDLMSDataType(reflect.TypeOf(value))
 		if err != nil {
@@ -954,7 +1000,10 @@ func (g *GXDLMSClient) Method2(name any,
 		if index > count {
 			return nil, gxcommon.ErrInvalidArgument
 		}
-		sn := name.(uint16)
+		sn, err := shortName(name)
+		if err != nil {
+			return nil, err
+		}
 		index = (ind + (index-1)*0x8)
 		sn = sn + uint16(index)
 		err = attributeDescriptor.SetUint16(sn)
@@ -975,6 +1024,7 @@ func (g *GXDLMSClient) Method2(name any,
 
 func (g *GXDLMSClient) Write2(name any, value any, type_ enums.DataType, objectType enums.ObjectType, index int, mode int) ([][]byte, error) {
 	g.settings.ResetBlockIndex()
+	g.setTarget(name, objectType, index, false)
 	if type_ == enums.DataTypeNone && value != nil {
 		type_, err := internal.GetDLMSDataType(reflect.TypeOf(value))
 		if err != nil {
@@ -1023,7 +1073,10 @@ func (g *GXDLMSClient) Write2(name any, value any, type_ enums.DataType, objectT
 		}
 	} else {
 		// Add name.
-		sn := name.(uint16)
+		sn, err := shortName(name)
+		if err != nil {
+			return nil, err
+		}
 		sn = sn + uint16(((index - 1) * 8))
 		err = attributeDescriptor.SetUint16(sn)
 		if err != nil {
@@ -1044,6 +1097,7 @@ func (g *GXDLMSClient) Read2(name any, objectType enums.ObjectType, attributeOrd
 		return nil, gxcommon.ErrInvalidArgument
 	}
 	g.settings.ResetBlockIndex()
+	g.setTarget(name, objectType, attributeOrdinal, false)
 	attributeDescriptor := types.GXByteBuffer{}
 	var reply [][]byte
 	var err error
@@ -1081,7 +1135,10 @@ func (g *GXDLMSClient) Read2(name any, objectType enums.ObjectType, attributeOrd
 		reply, err = getLnMessages(p)
 	} else {
 		var requestType byte
-		sn := name.(uint16)
+		sn, err := shortName(name)
+		if err != nil {
+			return nil, err
+		}
 		sn = sn + uint16(((attributeOrdinal - 1) * 8))
 		err = attributeDescriptor.SetUint16(sn)
 		if err != nil {
@@ -1876,6 +1933,9 @@ func (g *GXDLMSClient) UpdateValues(list []types.GXKeyValuePair[objects.IGXDLMSB
 	var err error
 	pos := 0
 	for _, it := range list {
+		if pos == len(values) {
+			break
+		}
 		e := internal.NewValueEventArgs(g.settings, it.Key, byte(it.Value))
 		e.Value = values[pos]
 		var type_ enums.DataType
@@ -2107,6 +2167,8 @@ func (g *GXDLMSClient) ReadList(list []types.GXKeyValuePair[objects.IGXDLMSBase,
 	}
 	var err error
 	g.settings.ResetBlockIndex()
+	// List request has no single target.
+	g.target = requestTarget{}
 	messages := [][]byte{}
 	data := types.GXByteBuffer{}
 	if g.UseLogicalNameReferencing() {
@@ -2236,6 +2298,8 @@ func (g *GXDLMSClient) WriteList(list []types.GXKeyValuePair[objects.IGXDLMSBase
 		}
 	}
 	g.settings.ResetBlockIndex()
+	// List request has no single target.
+	g.target = requestTarget{}
 	var messages [][]byte
 	data := types.GXByteBuffer{}
 	if g.UseLogicalNameReferencing() {
@@ -2739,6 +2803,94 @@ func (g *GXDLMSClient) ReceiverReady(reply *GXReplyData) ([]byte, error) {
 	return receiverReady(g.settings, reply)
 }
 
+// IsReplyExpected returns true if the peer replies to the sent message.
+// When HDLC window size is bigger than one, the peer replies only to the frame where the poll bit is set.
+//
+// Parameters:
+//
+//	data: Message to send.
+//
+// Returns:
+//
+//	True, if reply is expected.
+func (g *GXDLMSClient) IsReplyExpected(data []byte) bool {
+	if !useHdlc(g.settings.InterfaceType) || g.settings.Hdlc == nil {
+		return true
+	}
+	pos := getHdlcControlIndex(data)
+	if pos == -1 {
+		return true
+	}
+	return (data[pos] & 0x10) != 0
+}
+
+// Retransmit returns the HDLC I-frames that the meter has not acknowledged.
+// Frames are sent again if the meter rejects the frame or the reply is not received in the time.
+// Poll bit is set for the last returned frame.
+//
+// Returns:
+//
+//	Frames to send again.
+func (g *GXDLMSClient) Retransmit() ([][]byte, error) {
+	if g.settings.Hdlc == nil {
+		return nil, errors.New("retransmit is supported only with HDLC framing")
+	}
+	frames := g.settings.Hdlc.Unacknowledged()
+	if len(frames) == 0 {
+		return nil, nil
+	}
+	messages := make([][]byte, len(frames))
+	copy(messages, frames)
+	last, err := setHdlcPollBit(messages[len(messages)-1])
+	if err != nil {
+		return nil, err
+	}
+	messages[len(messages)-1] = last
+	return messages, nil
+}
+
+// GbtRetransmit returns the General Block Transfer blocks that the meter has not received.
+// Use IsRetransmitRequested to check if blocks must be sent again.
+// Error is returned if the blocks are sent again more times than allowed with GbtMaxRetries.
+//
+// Parameters:
+//
+//	reply: Reply data.
+//
+// Returns:
+//
+//	Blocks to send again.
+func (g *GXDLMSClient) GbtRetransmit(reply *GXReplyData) ([][]byte, error) {
+	if !reply.gbtRetransmit {
+		return nil, nil
+	}
+	err := g.settings.IncreaseGbtRetries()
+	if err != nil {
+		return nil, err
+	}
+	reply.gbtRetransmit = false
+	messages := make([][]byte, 0)
+	for _, it := range g.settings.GbtBlocks() {
+		tmp, err := getFrames(g.settings, enums.CommandGeneralBlockTransfer, 0, types.NewGXByteBufferWithData(it))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, tmp...)
+	}
+	return messages, nil
+}
+
+// GbtMaxRetries returns the maximum amount of General Block Transfer retransmissions.
+func (g *GXDLMSClient) GbtMaxRetries() uint8 {
+	return g.settings.GbtMaxRetries()
+}
+
+// SetGbtMaxRetries sets the maximum amount of General Block Transfer retransmissions.
+// Transfer is aborted if lost blocks are not received after the retransmissions.
+func (g *GXDLMSClient) SetGbtMaxRetries(value uint8) {
+	g.settings.SetGbtMaxRetries(value)
+}
+
 // GetData returns the removes the frame from the packet, and returns DLMS PDU.
 //
 // Parameters:
@@ -2767,7 +2919,13 @@ func (g *GXDLMSClient) GetDataFromByteArray(reply []byte, data *GXReplyData, not
 //	Is frame complete.
 func (g *GXDLMSClient) GetData(reply *types.GXByteBuffer, data *GXReplyData, notify *GXReplyData) (bool, error) {
 	data.xml = nil
+	data.target = g.target
+	start := reply.Position()
 	ret, err := getData(g.settings, reply, data, notify)
+	if g.settings.Trace != nil && reply.Position() > start {
+		frame, _ := reply.SubArray(start, reply.Position()-start)
+		traceReceived(g.settings, frame, data, notify)
+	}
 	if err != nil {
 		if g.translator == nil || g.throwExceptions {
 			return false, err
@@ -3110,15 +3268,14 @@ func (g *GXDLMSClient) AccessRequest(time_ *time.Time, list []GXDLMSAccessItem)
 //
 // Returns:
 //
-//	Data notification data.
+//	Data notification data. Updated objects and attribute indexes are returned for the information report.
 func (g *GXDLMSClient) ParseReport(reply *GXReplyData, list []*types.GXKeyValuePair[objects.IGXDLMSBase, int]) (any, error) {
 	var err error
 	if reply.Command() == enums.CommandEventNotification {
 		err = handleEventNotification(g.settings, reply, list)
 		return nil, err
 	} else if reply.Command() == enums.CommandInformationReport {
-		err = handleInformationReport(g.settings, reply, list)
-		return nil, err
+		return handleInformationReport(g.settings, reply)
 	} else if reply.Command() == enums.CommandDataNotification {
 		return reply.Value, nil
 	}
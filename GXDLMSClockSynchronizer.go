@@ -0,0 +1,379 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// maxShiftTime is the maximum time that can be shifted with shift_time method.
+const maxShiftTime = 900 * time.Second
+
+// presetValidity is the validity interval before and after the expected meter time when preset time is used.
+const presetValidity = time.Minute
+
+// GXDLMSClockSyncReport describes the measured clock drift and the selected correction.
+type GXDLMSClockSyncReport struct {
+	// Time read from the meter.
+	MeterTime types.GXDateTime
+
+	// Time when the meter is estimated to read its clock.
+	// This is the middle point of the request and the reply.
+	Reference time.Time
+
+	// Round-trip time of the read.
+	RoundTrip time.Duration
+
+	// Drift is the meter time minus the reference time.
+	Drift time.Duration
+
+	// Selected correction.
+	Decision enums.ClockSyncDecision
+
+	// Shifted seconds when the clock is shifted.
+	Shift int
+
+	// New meter time when the time is written or preset.
+	Time time.Time
+
+	// Time when the synchronisation should be retried if it's deferred.
+	RetryAt time.Time
+
+	// Correction crosses the capture period boundary because the drift is larger than the capture period.
+	CrossesBoundary bool
+
+	// Reason for the decision.
+	Reason string
+
+	// Generated PDUs.
+	Messages [][]byte
+}
+
+// String returns the report as text.
+func (g *GXDLMSClockSyncReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Meter time: %s\n", g.MeterTime.Value.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Reference time: %s\n", g.Reference.Format(time.RFC3339Nano))
+	fmt.Fprintf(&sb, "Round-trip time: %s\n", g.RoundTrip)
+	fmt.Fprintf(&sb, "Drift: %s\n", g.Drift)
+	fmt.Fprintf(&sb, "Decision: %s\n", g.Decision)
+	switch g.Decision {
+	case enums.ClockSyncDecisionShift:
+		fmt.Fprintf(&sb, "Shift: %d s\n", g.Shift)
+	case enums.ClockSyncDecisionPreset, enums.ClockSyncDecisionWrite:
+		fmt.Fprintf(&sb, "New time: %s\n", g.Time.Format(time.RFC3339))
+	case enums.ClockSyncDecisionDeferred:
+		fmt.Fprintf(&sb, "Retry at: %s\n", g.RetryAt.Format(time.RFC3339))
+	}
+	if g.Reason != "" {
+		fmt.Fprintf(&sb, "Reason: %s\n", g.Reason)
+	}
+	for _, it := range g.Messages {
+		sb.WriteString("    ")
+		sb.WriteString(types.ToHex(it, true))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// GXDLMSClockSynchronizer measures the drift of the meter clock and corrects it.
+//
+// Small drift is corrected with shift_time method. Larger drift is corrected
+// by writing the time or with the preset adjusting time methods.
+// Correction is deferred if it would cross the capture period boundary of the load profiles.
+type GXDLMSClockSynchronizer struct {
+	// DLMS client.
+	Client *GXDLMSClient
+
+	// Exchange sends the generated messages to the meter.
+	Exchange GXDLMSExchange
+
+	// Synchronised clock object.
+	Target *objects.GXDLMSClock
+
+	// Profiles whose capture period boundaries are not crossed.
+	// If nil, the profile generic objects of the client are used.
+	Profiles []*objects.GXDLMSProfileGeneric
+
+	// Drift that is not corrected.
+	Tolerance time.Duration
+
+	// Minimum distance of the corrected time from the capture period boundary.
+	BoundaryMargin time.Duration
+
+	// If set, preset adjusting time is used instead of writing the time.
+	UsePresetTime bool
+
+	// If set, Synchronize returns the planned PDUs without sending them.
+	DryRun bool
+
+	// Clock returns the current time. If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// NewGXDLMSClockSynchronizer creates a new clock synchroniser.
+//
+// Parameters:
+//
+//	client: DLMS client.
+//	exchange: Exchanges data with the meter.
+//	clock: Synchronised clock object.
+func NewGXDLMSClockSynchronizer(client *GXDLMSClient, exchange GXDLMSExchange, clock *objects.GXDLMSClock) *GXDLMSClockSynchronizer {
+	return &GXDLMSClockSynchronizer{Client: client, Exchange: exchange, Target: clock,
+		Tolerance: time.Second, BoundaryMargin: 10 * time.Second}
+}
+
+func (g *GXDLMSClockSynchronizer) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// Measure reads the meter time and calculates the drift.
+// Half of the round-trip time is used as the transmission delay.
+//
+// Returns:
+//
+//	Measured drift.
+func (g *GXDLMSClockSynchronizer) Measure() (*GXDLMSClockSyncReport, error) {
+	if g.Client == nil || g.Exchange == nil || g.Target == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	messages, err := g.Client.Read(g.Target, 2)
+	if err != nil {
+		return nil, err
+	}
+	reply := NewGXReplyData()
+	start := g.now()
+	err = readDataBlock(g.Client, g.Exchange, messages, reply)
+	end := g.now()
+	if err != nil {
+		return nil, fmt.Errorf("reading the clock failed. %w", err)
+	}
+	if _, err = g.Client.UpdateValue(g.Target, 2, reply.Value, nil); err != nil {
+		return nil, err
+	}
+	ret := &GXDLMSClockSyncReport{MeterTime: g.Target.Time, RoundTrip: end.Sub(start)}
+	ret.Reference = start.Add(ret.RoundTrip / 2)
+	ret.Drift = ret.MeterTime.Value.Sub(ret.Reference)
+	return ret, nil
+}
+
+// Plan selects the correction for the measured drift and generates the PDUs.
+//
+// Parameters:
+//
+//	report: Measured drift.
+func (g *GXDLMSClockSynchronizer) Plan(report *GXDLMSClockSyncReport) error {
+	if g.Client == nil || g.Target == nil || report == nil {
+		return gxcommon.ErrInvalidArgument
+	}
+	report.Decision = enums.ClockSyncDecisionNone
+	report.Messages = nil
+	drift := report.Drift
+	if drift < 0 {
+		drift = -drift
+	}
+	if drift <= g.Tolerance {
+		report.Reason = fmt.Sprintf("Drift is within the tolerance %s.", g.Tolerance)
+		return nil
+	}
+	now := g.now()
+	// Expected meter time when the request arrives.
+	delay := report.RoundTrip / 2
+	meterTime := report.MeterTime.Value.Add(now.Sub(report.Reference) + delay)
+	wait, ok := g.boundaryWait(meterTime, meterTime.Add(-report.Drift))
+	if !ok {
+		report.CrossesBoundary = true
+	} else if wait != 0 {
+		report.Decision = enums.ClockSyncDecisionDeferred
+		report.RetryAt = now.Add(wait)
+		report.Reason = "Correction would cross the capture period boundary."
+		return nil
+	}
+	var err error
+	loc := report.MeterTime.Value.Location()
+	switch {
+	case drift <= maxShiftTime:
+		report.Shift = int(math.Round(-report.Drift.Seconds()))
+		if report.Shift == 0 {
+			report.Reason = "Drift is less than one second."
+			return nil
+		}
+		report.Decision = enums.ClockSyncDecisionShift
+		report.Messages, err = g.Target.ShiftTime(g.Client, report.Shift)
+	case g.UsePresetTime:
+		report.Decision = enums.ClockSyncDecisionPreset
+		// Time is adjusted when the second request arrives.
+		report.Time = now.Add(3 * delay).In(loc)
+		start := meterTime.Add(-presetValidity)
+		end := meterTime.Add(report.RoundTrip + presetValidity)
+		var messages [][]byte
+		if messages, err = g.Target.PresetAdjustingTime(g.Client, &report.Time, &start, &end); err == nil {
+			report.Messages = append(report.Messages, messages...)
+			if messages, err = g.Target.AdjustToPresetTime(g.Client); err == nil {
+				report.Messages = append(report.Messages, messages...)
+			}
+		}
+	default:
+		report.Decision = enums.ClockSyncDecisionWrite
+		report.Time = now.Add(delay).In(loc)
+		g.Target.Time = types.GXDateTime{Value: report.Time,
+			Status: report.MeterTime.Status & enums.ClockStatusDaylightSavingActive}
+		report.Messages, err = g.Client.Write(g.Target, 2)
+	}
+	if err == nil {
+		if report.CrossesBoundary {
+			report.Reason = "Drift is larger than the capture period and the boundary is crossed."
+		} else if report.Decision == enums.ClockSyncDecisionShift {
+			report.Reason = fmt.Sprintf("Drift is within the shift limit %s.", maxShiftTime)
+		} else {
+			report.Reason = fmt.Sprintf("Drift exceeds the shift limit %s.", maxShiftTime)
+		}
+	}
+	return err
+}
+
+// Synchronize measures the drift and sends the correction to the meter.
+//
+// Returns:
+//
+//	Synchronisation report.
+func (g *GXDLMSClockSynchronizer) Synchronize() (*GXDLMSClockSyncReport, error) {
+	report, err := g.Measure()
+	if err != nil {
+		return nil, err
+	}
+	if err = g.Plan(report); err != nil || g.DryRun {
+		return report, err
+	}
+	for pos, it := range report.Messages {
+		reply := NewGXReplyData()
+		if err = readDataBlock(g.Client, g.Exchange, [][]byte{it}, reply); err != nil {
+			// Messages are generated before they are sent and the error is mapped to the message.
+			var accessError *dlmserrors.GXDLMSAccessError
+			if errors.As(err, &accessError) {
+				return report, fmt.Errorf("clock %s failed. %w", report.Decision, g.syncError(report, pos, accessError.ErrorCode))
+			}
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// syncError returns the data access error of the failed synchronisation message.
+func (g *GXDLMSClockSynchronizer) syncError(report *GXDLMSClockSyncReport, pos int, code enums.ErrorCode) error {
+	index, method := 2, false
+	switch report.Decision {
+	case enums.ClockSyncDecisionShift:
+		index, method = 6, true
+	case enums.ClockSyncDecisionPreset:
+		// Adjust to preset time is the last message.
+		index, method = 5, true
+		if pos == len(report.Messages)-1 {
+			index = 4
+		}
+	}
+	return dlmserrors.NewGXDLMSAccessError(code, enums.ObjectTypeClock, g.Target.LogicalName(), index, method)
+}
+
+// profiles returns the load profiles where the capture period is used.
+func (g *GXDLMSClockSynchronizer) profiles() []*objects.GXDLMSProfileGeneric {
+	if g.Profiles != nil {
+		return g.Profiles
+	}
+	var ret []*objects.GXDLMSProfileGeneric
+	for _, it := range g.Client.Objects().GetObjects(enums.ObjectTypeProfileGeneric) {
+		if pg, ok := it.(*objects.GXDLMSProfileGeneric); ok {
+			ret = append(ret, pg)
+		}
+	}
+	return ret
+}
+
+// captureBoundary returns the first capture time at or after the given time.
+// Capture times are aligned to the midnight of the meter local time.
+func captureBoundary(value time.Time, period time.Duration) time.Time {
+	midnight := time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
+	n := (value.Sub(midnight) + period - 1) / period
+	return midnight.Add(n * period)
+}
+
+// boundaryWait returns how long the correction must wait so that
+// the meter time doesn't cross the capture period boundary when it's changed from old to corrected.
+// False is returned if the boundary can't be avoided.
+func (g *GXDLMSClockSynchronizer) boundaryWait(old time.Time, corrected time.Time) (time.Duration, bool) {
+	lo, hi := old, corrected
+	if hi.Before(lo) {
+		lo, hi = hi, lo
+	}
+	lo = lo.Add(-g.BoundaryMargin)
+	hi = hi.Add(g.BoundaryMargin)
+	var wait time.Duration
+	profiles := g.profiles()
+	// Waiting for one profile may move the correction over the boundary of another.
+	for retry := 0; retry != 10; retry++ {
+		moved := false
+		for _, it := range profiles {
+			if it.CapturePeriod == 0 {
+				continue
+			}
+			period := time.Duration(it.CapturePeriod) * time.Second
+			if hi.Sub(lo) >= period {
+				return 0, false
+			}
+			b := captureBoundary(lo.Add(wait), period)
+			if !b.After(hi.Add(wait)) {
+				wait += b.Sub(lo.Add(wait)) + time.Second
+				moved = true
+			}
+		}
+		if !moved {
+			return wait, true
+		}
+	}
+	return 0, false
+}
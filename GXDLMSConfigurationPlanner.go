@@ -0,0 +1,457 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXDLMSConfigurationAction is a planned write or method invocation.
+type GXDLMSConfigurationAction struct {
+	// Set or Action.
+	Command enums.AccessServiceCommandType
+
+	// Written attributes or invoked method.
+	// When several attributes are written they are sent with one WriteList request.
+	Items []types.GXKeyValuePair[objects.IGXDLMSBase, int]
+
+	// Method parameters.
+	Data any
+
+	// Method parameter data type.
+	DataType enums.DataType
+
+	// Generated PDUs.
+	Messages [][]byte
+
+	// Error code returned by the meter when the plan is applied.
+	Error enums.ErrorCode
+}
+
+// description returns the command and the accessed attributes or method.
+func (g *GXDLMSConfigurationAction) description() string {
+	var sb strings.Builder
+	sb.WriteString(g.Command.String())
+	for _, it := range g.Items {
+		fmt.Fprintf(&sb, " %s %s:%d", it.Key.Base().ObjectType(), it.Key.Base().LogicalName(), it.Value)
+	}
+	return sb.String()
+}
+
+// accessError returns the data access error that the meter returned for the action.
+func (g *GXDLMSConfigurationAction) accessError() error {
+	if len(g.Items) != 1 {
+		return fmt.Errorf("%s failed. %w", g.description(),
+			dlmserrors.NewGXDLMSAccessError(g.Error, enums.ObjectTypeNone, "", 0, false))
+	}
+	it := g.Items[0]
+	return fmt.Errorf("%s failed. %w", g.Command,
+		dlmserrors.NewGXDLMSAccessError(g.Error, it.Key.Base().ObjectType(), it.Key.Base().LogicalName(),
+			it.Value, g.Command == enums.AccessServiceCommandTypeAction))
+}
+
+// String returns the action and generated PDUs as text.
+func (g *GXDLMSConfigurationAction) String() string {
+	var sb strings.Builder
+	sb.WriteString(g.description())
+	sb.WriteString("\n")
+	for _, it := range g.Messages {
+		sb.WriteString("    ")
+		sb.WriteString(types.ToHex(it, true))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// GXDLMSConfigurationPlan contains the actions needed to reach the desired state.
+type GXDLMSConfigurationPlan struct {
+	// Actions in the order they are executed.
+	Actions []*GXDLMSConfigurationAction
+
+	// Changed attributes and methods that the client is not allowed to access.
+	Denied []*GXDLMSAccessItem
+
+	// Objects of the desired state that are not in the meter.
+	Missing []objects.IGXDLMSBase
+}
+
+// String returns the plan as text.
+func (g *GXDLMSConfigurationPlan) String() string {
+	var sb strings.Builder
+	for _, it := range g.Actions {
+		sb.WriteString(it.String())
+	}
+	for _, it := range g.Denied {
+		fmt.Fprintf(&sb, "Denied %s %s %s:%d\n", it.Command, it.Target.Base().ObjectType(), it.Target.Base().LogicalName(), it.Index)
+	}
+	for _, it := range g.Missing {
+		fmt.Fprintf(&sb, "Missing %s %s\n", it.Base().ObjectType(), it.Base().LogicalName())
+	}
+	return sb.String()
+}
+
+// configurationWrite is a changed attribute.
+type configurationWrite struct {
+	// Object where the written value is taken.
+	source objects.IGXDLMSBase
+	// Object in the meter.
+	target objects.IGXDLMSBase
+	index  int
+}
+
+// GXDLMSConfigurationPlanner computes the writes and method invocations
+// that are needed to change the meter configuration to the desired state.
+//
+// Attributes are written before methods are invoked. If the active activity calendar differs,
+// it's written to the passive calendar and the passive calendar is activated.
+type GXDLMSConfigurationPlanner struct {
+	// DLMS client.
+	Client *GXDLMSClient
+
+	// Exchange sends the generated messages to the meter.
+	Exchange GXDLMSExchange
+
+	// Comparer is used to find the changed attributes.
+	// Access rights are not compared and dynamic values are ignored by default.
+	Comparer *objects.GXDLMSObjectComparer
+
+	// If set, Apply returns the planned PDUs without sending them.
+	DryRun bool
+
+	// Methods invoked after the attributes are written.
+	methods []*GXDLMSConfigurationAction
+}
+
+// NewGXDLMSConfigurationPlanner creates a new configuration planner.
+//
+// Parameters:
+//
+//	client: DLMS client.
+//	exchange: Exchanges data with the meter.
+func NewGXDLMSConfigurationPlanner(client *GXDLMSClient, exchange GXDLMSExchange) *GXDLMSConfigurationPlanner {
+	comparer := objects.NewGXDLMSObjectComparer()
+	comparer.CompareAccess = false
+	comparer.Ignore = objects.IgnoreDynamicValues
+	return &GXDLMSConfigurationPlanner{Client: client, Exchange: exchange, Comparer: comparer}
+}
+
+// AddMethod adds a method that is invoked after the attributes are written.
+// Methods are invoked in the order they are added, example push after the push setup is written.
+//
+// Parameters:
+//
+//	target: COSEM object.
+//	index: Method index.
+//	data: Method parameters.
+//	dataType: Data type of the parameters.
+func (g *GXDLMSConfigurationPlanner) AddMethod(target objects.IGXDLMSBase, index int, data any, dataType enums.DataType) {
+	g.methods = append(g.methods, &GXDLMSConfigurationAction{
+		Command:  enums.AccessServiceCommandTypeAction,
+		Items:    []types.GXKeyValuePair[objects.IGXDLMSBase, int]{*types.NewGXKeyValuePair(target, index)},
+		Data:     data,
+		DataType: dataType,
+	})
+}
+
+// Plan computes the actions and generates the PDUs.
+// PDUs contain the invoke ID and the invocation counter and they must be sent in the same order as they are generated.
+//
+// Parameters:
+//
+//	desired: Desired objects, example loaded from the XML file.
+//	current: Objects read from the meter.
+//
+// Returns:
+//
+//	Configuration plan.
+func (g *GXDLMSConfigurationPlanner) Plan(desired objects.GXDLMSObjectCollection, current objects.GXDLMSObjectCollection) (*GXDLMSConfigurationPlan, error) {
+	if g.Client == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	comparer := g.Comparer
+	if comparer == nil {
+		comparer = objects.NewGXDLMSObjectComparer()
+	}
+	plan := &GXDLMSConfigurationPlan{}
+	var writes []configurationWrite
+	var methods []*GXDLMSConfigurationAction
+	for _, d := range desired {
+		c := current.FindByLN(d.Base().ObjectType(), d.Base().LogicalName())
+		if c == nil {
+			plan.Missing = append(plan.Missing, d)
+			continue
+		}
+		diff := comparer.CompareObject(c, d)
+		var indexes []int
+		for _, it := range diff.Values {
+			indexes = append(indexes, it.Index)
+		}
+		for _, it := range diff.CaptureObjects {
+			indexes = append(indexes, it.Index)
+		}
+		sort.Ints(indexes)
+		var source objects.IGXDLMSBase = d
+		if calendar, ok := d.(*objects.GXDLMSActivityCalendar); ok {
+			var activate bool
+			var err error
+			source, indexes, activate, err = g.passiveCalendar(calendar, indexes)
+			if err != nil {
+				return nil, err
+			}
+			if activate {
+				if g.Client.CanInvoke(c, 1) {
+					methods = append(methods, &GXDLMSConfigurationAction{
+						Command:  enums.AccessServiceCommandTypeAction,
+						Items:    []types.GXKeyValuePair[objects.IGXDLMSBase, int]{*types.NewGXKeyValuePair(c, 1)},
+						Data:     int8(0),
+						DataType: enums.DataTypeInt8,
+					})
+				} else {
+					plan.Denied = append(plan.Denied, NewGXDLMSAccessItem(enums.AccessServiceCommandTypeAction, c, 1))
+				}
+			}
+		}
+		for _, index := range indexes {
+			if g.Client.CanWrite(c, index) {
+				writes = append(writes, configurationWrite{source: source, target: c, index: index})
+			} else {
+				plan.Denied = append(plan.Denied, NewGXDLMSAccessItem(enums.AccessServiceCommandTypeSet, c, uint8(index)))
+			}
+		}
+	}
+	for _, it := range g.methods {
+		if g.Client.CanInvoke(it.Items[0].Key, it.Items[0].Value) {
+			methods = append(methods, it)
+		} else {
+			plan.Denied = append(plan.Denied, NewGXDLMSAccessItem(enums.AccessServiceCommandTypeAction, it.Items[0].Key, uint8(it.Items[0].Value)))
+		}
+	}
+	err := g.planWrites(plan, writes)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range methods {
+		m := *it
+		m.Messages, err = g.Client.Method(m.Items[0].Key, m.Items[0].Value, m.Data, m.DataType)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, &m)
+	}
+	return plan, nil
+}
+
+// Apply computes the plan and sends it to the meter.
+// If DryRun is set, the plan is returned without sending it.
+// Apply stops to the first failed action, because later actions might depend on it.
+//
+// Parameters:
+//
+//	desired: Desired objects, example loaded from the XML file.
+//	current: Objects read from the meter.
+//
+// Returns:
+//
+//	Executed configuration plan.
+func (g *GXDLMSConfigurationPlanner) Apply(desired objects.GXDLMSObjectCollection, current objects.GXDLMSObjectCollection) (*GXDLMSConfigurationPlan, error) {
+	plan, err := g.Plan(desired, current)
+	if err != nil || g.DryRun {
+		return plan, err
+	}
+	if g.Exchange == nil {
+		return plan, gxcommon.ErrInvalidArgument
+	}
+	for _, it := range plan.Actions {
+		reply := NewGXReplyData()
+		err = readDataBlock(g.Client, g.Exchange, it.Messages, reply)
+		var accessError *dlmserrors.GXDLMSAccessError
+		if errors.As(err, &accessError) {
+			it.Error = accessError.ErrorCode
+			return plan, it.accessError()
+		}
+		if err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// passiveCalendar returns the object where the values are written.
+// If the active calendar is changed, the desired active calendar is copied to the passive calendar.
+func (g *GXDLMSConfigurationPlanner) passiveCalendar(calendar *objects.GXDLMSActivityCalendar, indexes []int) (objects.IGXDLMSBase, []int, bool, error) {
+	activate := false
+	for _, it := range indexes {
+		if it >= 2 && it <= 5 {
+			activate = true
+			break
+		}
+	}
+	if !activate {
+		return calendar, indexes, false, nil
+	}
+	tmp, err := objects.NewGXDLMSActivityCalendar(calendar.LogicalName(), calendar.ShortName)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	tmp.CalendarNameActive = calendar.CalendarNameActive
+	tmp.SeasonProfileActive = calendar.SeasonProfileActive
+	tmp.WeekProfileTableActive = calendar.WeekProfileTableActive
+	tmp.DayProfileTableActive = calendar.DayProfileTableActive
+	tmp.CalendarNamePassive = calendar.CalendarNameActive
+	tmp.SeasonProfilePassive = calendar.SeasonProfileActive
+	tmp.WeekProfileTablePassive = calendar.WeekProfileTableActive
+	tmp.DayProfileTablePassive = calendar.DayProfileTableActive
+	tmp.Time = calendar.Time
+	// Passive calendar is written before activation. Activation time is written if it's changed.
+	ret := []int{6, 7, 8, 9}
+	for _, it := range indexes {
+		if it == 10 {
+			ret = append(ret, it)
+		}
+	}
+	return tmp, ret, true, nil
+}
+
+// planWrites splits the writes to the WriteList requests that fit in one PDU.
+func (g *GXDLMSConfigurationPlanner) planWrites(plan *GXDLMSConfigurationPlan, writes []configurationWrite) error {
+	useList := g.Client.UseLogicalNameReferencing() && (g.Client.NegotiatedConformance()&enums.ConformanceMultipleReferences) != 0
+	maxSize := int(g.Client.settings.MaxPduSize())
+	var batch []configurationWrite
+	// Size of the request header.
+	size := 12
+	for _, it := range writes {
+		s, err := g.valueSize(it.source, it.index)
+		if err != nil {
+			return err
+		}
+		// Attribute descriptor is 10 bytes.
+		s += 10
+		if len(batch) != 0 && (!useList || size+s > maxSize) {
+			err = g.addWrites(plan, batch)
+			if err != nil {
+				return err
+			}
+			batch = nil
+			size = 12
+		}
+		batch = append(batch, it)
+		size += s
+	}
+	if len(batch) != 0 {
+		return g.addWrites(plan, batch)
+	}
+	return nil
+}
+
+// addWrites generates the write messages.
+func (g *GXDLMSConfigurationPlanner) addWrites(plan *GXDLMSConfigurationPlan, batch []configurationWrite) error {
+	action := &GXDLMSConfigurationAction{Command: enums.AccessServiceCommandTypeSet}
+	var err error
+	if len(batch) == 1 {
+		it := batch[0]
+		action.Items = append(action.Items, *types.NewGXKeyValuePair(it.target, it.index))
+		value, dt, err := g.writeValue(it.source, it.index)
+		if err != nil {
+			return err
+		}
+		// Meter object is used to get the short name and the access mode.
+		mode := int(it.target.Base().GetAccess3(it.index))
+		action.Messages, err = g.Client.Write2(it.target.Base().Name(), value, dt, it.target.Base().ObjectType(), it.index, mode)
+		if err != nil {
+			return err
+		}
+	} else {
+		var list []types.GXKeyValuePair[objects.IGXDLMSBase, int]
+		for _, it := range batch {
+			action.Items = append(action.Items, *types.NewGXKeyValuePair(it.target, it.index))
+			list = append(list, *types.NewGXKeyValuePair(it.source, it.index))
+		}
+		action.Messages, err = g.Client.WriteList(list)
+		if err != nil {
+			return err
+		}
+	}
+	plan.Actions = append(plan.Actions, action)
+	return nil
+}
+
+// writeValue returns the written value and data type in the same way as Write.
+func (g *GXDLMSConfigurationPlanner) writeValue(item objects.IGXDLMSBase, index int) (any, enums.DataType, error) {
+	settings := g.Client.settings
+	value, err := item.GetValue(settings, internal.NewValueEventArgs(settings, item, uint8(index)))
+	if err != nil {
+		return nil, enums.DataTypeNone, err
+	}
+	dt, err := item.GetDataType(index)
+	if err != nil {
+		return nil, enums.DataTypeNone, err
+	}
+	if dt == enums.DataTypeNone {
+		dt, err = internal.GetDLMSDataType(reflect.TypeOf(value))
+		if err != nil {
+			return nil, enums.DataTypeNone, err
+		}
+	}
+	// If values is show as string, but send as byte array.
+	if v, ok := value.(string); ok && dt == enums.DataTypeOctetString && item.Base().GetUIDataType(index) == enums.DataTypeString {
+		value = []byte(v)
+	}
+	return value, dt, nil
+}
+
+// valueSize returns the size of the encoded attribute value.
+func (g *GXDLMSConfigurationPlanner) valueSize(item objects.IGXDLMSBase, index int) (int, error) {
+	value, dt, err := g.writeValue(item, index)
+	if err != nil {
+		return 0, err
+	}
+	bb := types.GXByteBuffer{}
+	err = internal.SetData(g.Client.settings, &bb, dt, value)
+	if err != nil {
+		return 0, err
+	}
+	return bb.Size(), nil
+}
@@ -0,0 +1,345 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXDLMSExchange sends data to the meter and returns the received bytes.
+// If data is nil, nothing is sent and the next received bytes are returned.
+type GXDLMSExchange func(data []byte) ([]byte, error)
+
+// GXDLMSAttributeError describes an attribute that was not read during the discovery.
+type GXDLMSAttributeError struct {
+	// COSEM object.
+	Target objects.IGXDLMSBase
+
+	// Attribute index.
+	Index int
+
+	// Data access error returned by the meter.
+	ErrorCode enums.ErrorCode
+
+	// Error that occurred while reading or updating the value.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *GXDLMSAttributeError) Error() string {
+	var str string
+	if e.Err != nil {
+		str = e.Err.Error()
+	} else {
+		str = dlmserrors.ErrorCodeDescription(e.ErrorCode)
+	}
+	return fmt.Sprintf("%s %s:%d %s", e.Target.Base().ObjectType(), e.Target.Base().LogicalName(), e.Index, str)
+}
+
+// Unwrap returns the underlying error.
+// If the meter returned a data access error, it is returned as dlmserrors.GXDLMSAccessError.
+func (e *GXDLMSAttributeError) Unwrap() error {
+	if e.Err == nil && e.ErrorCode != enums.ErrorCodeOk {
+		return dlmserrors.NewGXDLMSAccessError(e.ErrorCode, e.Target.Base().ObjectType(), e.Target.Base().LogicalName(), e.Index, false)
+	}
+	return e.Err
+}
+
+// GXDLMSDiscoverySnapshot contains the objects read from the meter.
+type GXDLMSDiscoverySnapshot struct {
+	// Objects of the association view with the read attribute values.
+	Objects objects.GXDLMSObjectCollection
+
+	// Attributes that the meter failed to return.
+	Errors []*GXDLMSAttributeError
+
+	// Time when the discovery was started.
+	Time time.Time
+}
+
+// AttributeError returns the read error of the attribute or nil if attribute was read.
+func (g *GXDLMSDiscoverySnapshot) AttributeError(target objects.IGXDLMSBase, index int) *GXDLMSAttributeError {
+	for _, it := range g.Errors {
+		if it.Target == target && it.Index == index {
+			return it
+		}
+	}
+	return nil
+}
+
+// SaveToFile saves the discovered objects to the XML file.
+//
+// Parameters:
+//
+//	filename: File name.
+//	settings: XML writer settings.
+func (g *GXDLMSDiscoverySnapshot) SaveToFile(filename string, settings *objects.GXXmlWriterSettings) error {
+	return g.Objects.SaveToFile(filename, settings)
+}
+
+// GXDLMSDiscovery reads the association view and all the readable attributes of the meter.
+type GXDLMSDiscovery struct {
+	// DLMS client.
+	Client *GXDLMSClient
+
+	// Exchange sends the generated messages to the meter.
+	Exchange GXDLMSExchange
+
+	// Inactive objects are ignored.
+	IgnoreInactiveObjects bool
+
+	// Profile generic buffers are read if set. Buffers can be huge and they are skipped by default.
+	ReadBuffers bool
+
+	// Clock returns the current time. If nil, time.Now is used.
+	Clock func() time.Time
+}
+
+// NewGXDLMSDiscovery creates a new discovery for the connected client.
+//
+// Parameters:
+//
+//	client: DLMS client. Association must be established before discovery is started.
+//	exchange: Exchanges data with the meter.
+func NewGXDLMSDiscovery(client *GXDLMSClient, exchange GXDLMSExchange) *GXDLMSDiscovery {
+	return &GXDLMSDiscovery{Client: client, Exchange: exchange, IgnoreInactiveObjects: true}
+}
+
+func (g *GXDLMSDiscovery) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// Discover reads the association view and the readable attributes of each object.
+// Attributes are read with ReadList when the meter supports multiple references.
+// Attribute errors are collected to the snapshot and discovery continues.
+//
+// Returns:
+//
+//	Discovered objects.
+func (g *GXDLMSDiscovery) Discover() (*GXDLMSDiscoverySnapshot, error) {
+	if g.Client == nil || g.Exchange == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	snapshot := &GXDLMSDiscoverySnapshot{Time: g.now()}
+	messages, err := g.Client.GetObjectsRequest()
+	if err != nil {
+		return nil, err
+	}
+	reply := NewGXReplyData()
+	err = readDataBlock(g.Client, g.Exchange, messages, reply)
+	if err != nil {
+		return nil, fmt.Errorf("association view read failed. %w", err)
+	}
+	snapshot.Objects, err = g.Client.ParseObjects(reply.Data, g.IgnoreInactiveObjects)
+	if err != nil {
+		return nil, err
+	}
+	list := g.attributesToRead(snapshot.Objects)
+	if (g.Client.NegotiatedConformance() & enums.ConformanceMultipleReferences) == 0 {
+		for _, it := range list {
+			g.readAttribute(snapshot, it.Key, it.Value)
+		}
+		return snapshot, nil
+	}
+	// Items are split so that each read request fits in one PDU.
+	count := int((g.Client.settings.MaxPduSize() - 12) / 10)
+	if count > 10 {
+		count = 10
+	}
+	if count < 1 {
+		count = 1
+	}
+	for pos := 0; pos < len(list); pos += count {
+		end := min(pos+count, len(list))
+		g.readList(snapshot, list[pos:end])
+	}
+	return snapshot, nil
+}
+
+// attributesToRead returns the readable attributes of the objects.
+// Logical name is known from the association view and it's not read.
+func (g *GXDLMSDiscovery) attributesToRead(objs objects.GXDLMSObjectCollection) []types.GXKeyValuePair[objects.IGXDLMSBase, int] {
+	var list []types.GXKeyValuePair[objects.IGXDLMSBase, int]
+	for _, obj := range objs {
+		for _, index := range obj.GetAttributeIndexToRead(true) {
+			if index == 1 || !g.Client.CanRead(obj, index) {
+				continue
+			}
+			if !g.ReadBuffers && index == 2 && obj.Base().ObjectType() == enums.ObjectTypeProfileGeneric {
+				continue
+			}
+			list = append(list, *types.NewGXKeyValuePair(obj, index))
+		}
+	}
+	return list
+}
+
+// readList reads the attributes with one request.
+// If the meter rejects the whole request, attributes are read one by one.
+func (g *GXDLMSDiscovery) readList(snapshot *GXDLMSDiscoverySnapshot, list []types.GXKeyValuePair[objects.IGXDLMSBase, int]) {
+	if len(list) == 1 {
+		g.readAttribute(snapshot, list[0].Key, list[0].Value)
+		return
+	}
+	reply := NewGXReplyData()
+	messages, err := g.Client.ReadList(list)
+	if err == nil {
+		err = readDataBlock(g.Client, g.Exchange, messages, reply)
+	}
+	// Items that failed to read are handled below.
+	var accessError *dlmserrors.GXDLMSAccessError
+	if errors.As(err, &accessError) && len(reply.ItemErrors) != 0 {
+		err = nil
+	}
+	values, ok := reply.Value.([]any)
+	if err != nil || !ok || len(values)+len(reply.ItemErrors) != len(list) {
+		for _, it := range list {
+			g.readAttribute(snapshot, it.Key, it.Value)
+		}
+		return
+	}
+	pos := 0
+	for index, it := range list {
+		if code, ok := reply.ItemErrors[index]; ok {
+			g.addError(snapshot, it.Key, it.Value, code, nil)
+			continue
+		}
+		g.updateValue(snapshot, it.Key, it.Value, values[pos])
+		pos++
+	}
+}
+
+// readAttribute reads one attribute.
+func (g *GXDLMSDiscovery) readAttribute(snapshot *GXDLMSDiscoverySnapshot, target objects.IGXDLMSBase, index int) {
+	reply := NewGXReplyData()
+	messages, err := g.Client.Read(target, index)
+	if err == nil {
+		err = readDataBlock(g.Client, g.Exchange, messages, reply)
+	}
+	var accessError *dlmserrors.GXDLMSAccessError
+	if errors.As(err, &accessError) {
+		g.addError(snapshot, target, index, accessError.ErrorCode, nil)
+	} else if err != nil {
+		g.addError(snapshot, target, index, enums.ErrorCodeOk, err)
+	} else {
+		g.updateValue(snapshot, target, index, reply.Value)
+	}
+}
+
+func (g *GXDLMSDiscovery) updateValue(snapshot *GXDLMSDiscoverySnapshot, target objects.IGXDLMSBase, index int, value any) {
+	_, err := g.Client.UpdateValue(target, index, value, nil)
+	if err != nil {
+		g.addError(snapshot, target, index, enums.ErrorCodeOk, err)
+		return
+	}
+	target.Base().SetLastReadTime(index, snapshot.Time)
+}
+
+func (g *GXDLMSDiscovery) addError(snapshot *GXDLMSDiscoverySnapshot, target objects.IGXDLMSBase, index int, code enums.ErrorCode, err error) {
+	snapshot.Errors = append(snapshot.Errors, &GXDLMSAttributeError{Target: target, Index: index, ErrorCode: code, Err: err})
+}
+
+// readDataBlock sends the messages and reads the reply until all data is received.
+// Data access error that the meter returns is returned as dlmserrors.GXDLMSAccessError.
+func readDataBlock(client *GXDLMSClient, exchange GXDLMSExchange, messages [][]byte, reply *GXReplyData) error {
+	for _, it := range messages {
+		err := readData(client, exchange, it, reply)
+		if err != nil {
+			return err
+		}
+		for reply.IsMoreData() {
+			var data []byte
+			if !reply.IsStreaming() {
+				data, err = client.ReceiverReady(reply)
+				if err != nil {
+					return err
+				}
+			}
+			err = readData(client, exchange, data, reply)
+			if err != nil {
+				return err
+			}
+		}
+		if err = reply.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readData sends the data and reads bytes until the frame is complete.
+func readData(client *GXDLMSClient, exchange GXDLMSExchange, data []byte, reply *GXReplyData) error {
+	notify := NewGXReplyData()
+	received, err := exchange(data)
+	if err != nil {
+		return err
+	}
+	buff := types.NewGXByteBufferWithData(received)
+	for {
+		complete, err := client.GetData(buff, reply, notify)
+		if err != nil {
+			return err
+		}
+		if complete {
+			return nil
+		}
+		// Notify messages received while reading are ignored.
+		if notify.IsComplete() && !notify.IsMoreData() {
+			notify.Clear()
+		}
+		received, err = exchange(nil)
+		if err != nil {
+			return err
+		}
+		if len(received) == 0 {
+			return errors.New("no reply received from the meter")
+		}
+		err = buff.Set(received)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -35,71 +35,14 @@
 //---------------------------------------------------------------------------
 
 import (
-	"fmt"
-
+	"github.com/Gurux/gxdlms-go/dlmserrors"
 	"github.com/Gurux/gxdlms-go/enums"
 )
 
-// DLMS specific exception response.
-// https://www.gurux.fi/Gurux.DLMS.ErrorCodes
-type GXDLMSExceptionResponse struct {
-	exceptionStateError   enums.ExceptionStateError
-	exceptionServiceError enums.ExceptionServiceError
-	value                 any
-	message               string
-}
-
-func (g *GXDLMSExceptionResponse) ExceptionStateError() enums.ExceptionStateError {
-	return g.exceptionStateError
-}
-func (g *GXDLMSExceptionResponse) ExceptionServiceError() enums.ExceptionServiceError {
-	return g.exceptionServiceError
-}
-func (g *GXDLMSExceptionResponse) Value() any {
-	return g.value
-}
-
-func getStateError(stateError enums.ExceptionStateError) string {
-	switch stateError {
-	case enums.ExceptionStateErrorServiceNotAllowed:
-		return "Service not allowed"
-	case enums.ExceptionStateErrorServiceUnknown:
-		return "Service unknown"
-	}
-	return ""
-}
-
-func getServiceError(serviceError enums.ExceptionServiceError, value *any) string {
-	switch serviceError {
-	case enums.ExceptionServiceErrorOperationNotPossible:
-		return "Operation not possible"
-	case enums.ExceptionServiceErrorOtherReason:
-		return "Other reason"
-	case enums.ExceptionServiceErrorServiceNotSupported:
-		return "Service not supported"
-	case enums.ExceptionServiceErrorPduTooLong:
-		return "PDU is too long"
-	case enums.ExceptionServiceErrorDecipheringError:
-		return "Deciphering failed"
-	case enums.ExceptionServiceErrorInvocationCounterError:
-		return "Invocation counter is invalid. Expected value is " + fmt.Sprint(value)
-	}
-	return ""
-}
+// GXDLMSExceptionResponse is DLMS specific exception response.
+type GXDLMSExceptionResponse = dlmserrors.GXDLMSExceptionResponse
 
+// NewGXDLMSExceptionResponse creates a new instance of GXDLMSExceptionResponse.
 func NewGXDLMSExceptionResponse(stateError enums.ExceptionStateError, type_ enums.ExceptionServiceError, value any) *GXDLMSExceptionResponse {
-	return &GXDLMSExceptionResponse{
-		exceptionStateError:   stateError,
-		exceptionServiceError: type_,
-		value:                 value,
-		message:               "https://www.gurux.fi/Gurux.DLMS.ErrorCodes",
-	}
-}
-
-// Error implements the error interface.
-func (e *GXDLMSExceptionResponse) Error() string {
-	return fmt.Sprintf(
-		"Exception response %s exception. %s",
-		getStateError(e.exceptionStateError),
-		getServiceError(e.exceptionServiceError, &e.value))
+	return dlmserrors.NewGXDLMSExceptionResponse(stateError, type_, value)
 }
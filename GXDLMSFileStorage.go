@@ -0,0 +1,342 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// objectsFileName is the name of the file where the object values are saved.
+const objectsFileName = "objects.xml"
+
+// GXDLMSFileStorage stores the state of the served objects to a directory.
+// Object values are saved to objects.xml using the XML format of the object collection.
+// Profile generic buffers are not saved there. Captured rows are appended to
+// an append-only log file, named by the logical name, one row per line.
+type GXDLMSFileStorage struct {
+	// Directory where the files are saved.
+	Directory string
+
+	settings *settings.GXDLMSSettings
+
+	// Amount of the rows in the log files.
+	rows map[*objects.GXDLMSProfileGeneric]int
+}
+
+// NewGXDLMSFileStorage creates a new file storage.
+//
+// Parameters:
+//
+//	directory: Directory where the files are saved.
+//
+// Returns:
+//
+//	Created file storage.
+func NewGXDLMSFileStorage(directory string) *GXDLMSFileStorage {
+	return &GXDLMSFileStorage{
+		Directory: directory,
+		settings:  settings.NewGXDLMSSettings(nil),
+		rows:      map[*objects.GXDLMSProfileGeneric]int{},
+	}
+}
+
+// Load restores the saved object values and replays the profile generic logs.
+//
+// Parameters:
+//
+//	items: Served objects.
+func (g *GXDLMSFileStorage) Load(items *objects.GXDLMSObjectCollection) error {
+	f, err := os.Open(filepath.Join(g.Directory, objectsFileName))
+	if err == nil {
+		err = items.UpdateFromStream(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, it := range *items {
+		if pg, ok := it.(*objects.GXDLMSProfileGeneric); ok {
+			if err = g.loadBuffer(pg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadBuffer reads the profile generic buffer from the log file.
+func (g *GXDLMSFileStorage) loadBuffer(target *objects.GXDLMSProfileGeneric) error {
+	data, err := os.ReadFile(g.logFile(target))
+	if err != nil {
+		if os.IsNotExist(err) {
+			target.Buffer = target.Buffer[:0]
+			target.EntriesInUse = 0
+			g.rows[target] = 0
+			return nil
+		}
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	buffer := [][]any{}
+	for pos, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		row, err := g.decodeRow(line)
+		if err != nil {
+			// Last row might be partially written if the server was stopped while writing it.
+			if pos == len(lines)-1 {
+				break
+			}
+			return err
+		}
+		buffer = append(buffer, row)
+	}
+	g.rows[target] = len(buffer)
+	if target.ProfileEntries != 0 && len(buffer) > int(target.ProfileEntries) {
+		buffer = buffer[len(buffer)-int(target.ProfileEntries):]
+	}
+	target.Buffer = buffer
+	target.EntriesInUse = uint32(len(buffer))
+	return nil
+}
+
+// Save saves the values of all objects. Profile generic buffers are not saved.
+//
+// Parameters:
+//
+//	items: Served objects.
+//	target: Changed object.
+func (g *GXDLMSFileStorage) Save(items *objects.GXDLMSObjectCollection, target objects.IGXDLMSBase) error {
+	if err := os.MkdirAll(g.Directory, 0o755); err != nil {
+		return err
+	}
+	// Buffers are in the log files.
+	buffers := map[*objects.GXDLMSProfileGeneric][][]any{}
+	for _, it := range *items {
+		if pg, ok := it.(*objects.GXDLMSProfileGeneric); ok {
+			buffers[pg] = pg.Buffer
+			pg.Buffer = nil
+		}
+	}
+	defer func() {
+		for pg, buffer := range buffers {
+			pg.Buffer = buffer
+		}
+	}()
+	return g.writeFile(filepath.Join(g.Directory, objectsFileName), func(w *bufio.Writer) error {
+		return items.SaveToStream(w, nil)
+	})
+}
+
+// Capture appends the captured row to the log file of the profile generic.
+// The log file is compacted when it holds twice the profile entries.
+//
+// Parameters:
+//
+//	target: Profile generic.
+//	row: Captured row.
+func (g *GXDLMSFileStorage) Capture(target *objects.GXDLMSProfileGeneric, row []any) error {
+	if target.ProfileEntries != 0 && g.rows[target] >= 2*int(target.ProfileEntries) {
+		return g.writeBuffer(target)
+	}
+	line, err := g.encodeRow(target, row)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(g.Directory, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(g.logFile(target), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(line + "\n")
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	g.rows[target]++
+	return nil
+}
+
+// ResetBuffer removes the log file of the profile generic.
+//
+// Parameters:
+//
+//	target: Profile generic.
+func (g *GXDLMSFileStorage) ResetBuffer(target *objects.GXDLMSProfileGeneric) error {
+	err := os.Remove(g.logFile(target))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	g.rows[target] = 0
+	return nil
+}
+
+// writeBuffer replaces the log file with the current buffer of the profile generic.
+func (g *GXDLMSFileStorage) writeBuffer(target *objects.GXDLMSProfileGeneric) error {
+	if err := os.MkdirAll(g.Directory, 0o755); err != nil {
+		return err
+	}
+	err := g.writeFile(g.logFile(target), func(w *bufio.Writer) error {
+		for _, row := range target.Buffer {
+			line, err := g.encodeRow(target, row)
+			if err != nil {
+				return err
+			}
+			if _, err = w.WriteString(line + "\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	g.rows[target] = len(target.Buffer)
+	return nil
+}
+
+// writeFile writes a temporary file and renames it so that a stopped server never leaves a partial file.
+func (g *GXDLMSFileStorage) writeFile(name string, write func(w *bufio.Writer) error) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// logFile returns the name of the log file of the profile generic.
+func (g *GXDLMSFileStorage) logFile(target *objects.GXDLMSProfileGeneric) string {
+	return filepath.Join(g.Directory, target.LogicalName()+".log")
+}
+
+// encodeRow converts the row to the hex string.
+func (g *GXDLMSFileStorage) encodeRow(target *objects.GXDLMSProfileGeneric, row []any) (string, error) {
+	bb := types.NewGXByteBuffer()
+	err := bb.SetUint8(uint8(enums.DataTypeStructure))
+	if err != nil {
+		return "", err
+	}
+	err = types.SetObjectCount(len(row), bb)
+	if err != nil {
+		return "", err
+	}
+	for pos, value := range row {
+		dt, err := rowDataType(target, pos, value)
+		if err != nil {
+			return "", err
+		}
+		err = internal.SetData(g.settings, bb, dt, value)
+		if err != nil {
+			return "", err
+		}
+	}
+	return internal.ToHex(bb.Array(), false, 0, bb.Size()), nil
+}
+
+// decodeRow converts the hex string to the row.
+func (g *GXDLMSFileStorage) decodeRow(line string) ([]any, error) {
+	data, err := internal.HexToBytes(line)
+	if err != nil {
+		return nil, err
+	}
+	info := internal.GXDataInfo{}
+	value, err := internal.GetData(g.settings, types.NewGXByteBufferWithData(data), &info)
+	if err != nil {
+		return nil, err
+	}
+	row, ok := value.(types.GXStructure)
+	if !ok || !info.Complete {
+		return nil, errors.New("Invalid profile generic row.")
+	}
+	return row, nil
+}
+
+// rowDataType returns the data type of the captured value.
+func rowDataType(target *objects.GXDLMSProfileGeneric, pos int, value any) (enums.DataType, error) {
+	switch value.(type) {
+	case nil:
+		return enums.DataTypeNone, nil
+	case types.GXDateTime, *types.GXDateTime:
+		return enums.DataTypeDateTime, nil
+	case types.GXDate, *types.GXDate:
+		return enums.DataTypeDate, nil
+	case types.GXTime, *types.GXTime:
+		return enums.DataTypeTime, nil
+	case objects.IGXDLMSBase:
+		return enums.DataTypeNone, errors.New("Capturing the whole object is not supported.")
+	}
+	if pos < len(target.CaptureObjects) {
+		c := target.CaptureObjects[pos]
+		if c.Value.AttributeIndex != 0 && c.Value.DataIndex == 0 {
+			dt, err := c.Key.GetDataType(c.Value.AttributeIndex)
+			if err == nil && dt != enums.DataTypeNone && dt != enums.DataTypeArray && dt != enums.DataTypeStructure {
+				return dt, nil
+			}
+		}
+	}
+	return internal.GetDLMSDataType(reflect.TypeOf(value))
+}
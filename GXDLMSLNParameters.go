@@ -90,6 +90,9 @@ type GXDLMSLNParameters struct {
 
 	// Access mode.
 	AccessMode int
+
+	// Plain PDU before ciphering. This is used for tracing.
+	pdu []byte
 }
 
 func NewGXDLMSLNParameters(settings *settings.GXDLMSSettings,
@@ -0,0 +1,439 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXDLMSPushEventArgs contains the push message received from the meter.
+type GXDLMSPushEventArgs struct {
+	// Meter that has sent the push.
+	Meter *GXDLMSPushMeter
+
+	// Source address of the meter.
+	Address int
+
+	// Received command.
+	Command enums.Command
+
+	// Long invoke ID and priority.
+	InvokeID uint32
+
+	// Is the push confirmed. Confirmed pushes are acknowledged.
+	Confirmed bool
+
+	// Send time of the push. Zero if the meter didn't send it.
+	Time time.Time
+
+	// Used security level.
+	Security enums.Security
+
+	// Invocation counter of the ciphered push.
+	InvocationCounter uint32
+
+	// Received value.
+	Value any
+
+	// Objects and attribute indexes that were updated from the push object list of the push setup.
+	Objects []types.GXKeyValuePair[objects.IGXDLMSBase, objects.GXDLMSCaptureObject]
+}
+
+// GXDLMSPushError describes a push message that was rejected.
+type GXDLMSPushError struct {
+	// Meter that has sent the push or nil if the meter is unknown.
+	Meter *GXDLMSPushMeter
+
+	// Source address of the meter.
+	Address int
+
+	// System title of the meter or nil if the push is not ciphered.
+	SystemTitle []byte
+
+	// Reason why the push was rejected.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *GXDLMSPushError) Error() string {
+	if len(e.SystemTitle) != 0 {
+		return fmt.Sprintf("push from %s rejected. %s", types.ToHex(e.SystemTitle, false), e.Err)
+	}
+	return fmt.Sprintf("push from %d rejected. %s", e.Address, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *GXDLMSPushError) Unwrap() error {
+	return e.Err
+}
+
+// GXDLMSPushListener receives push messages from several meters.
+// Sender is identified by the source address or the system title and
+// the ciphered pushes are decrypted with the keys of the meter found from the registry.
+type GXDLMSPushListener struct {
+	// Meters that are allowed to send push messages.
+	Registry IGXDLMSPushRegistry
+
+	// Used interface type.
+	InterfaceType enums.InterfaceType
+
+	// Client address where the meters send the pushes.
+	// Zero accepts all client addresses.
+	ClientAddress int
+
+	// OnPush is called when the push is received and accepted.
+	// Other pushes are not handled before OnPush returns, so the objects are not changed while they are used.
+	OnPush func(e *GXDLMSPushEventArgs)
+
+	// OnError is called when the push is rejected while the connection is handled.
+	// Error is returned as GXDLMSPushError when the sender is known.
+	OnError func(err error)
+
+	// Meter objects and invocation counters are updated and reported one push at the time.
+	mutex sync.Mutex
+}
+
+// NewGXDLMSPushListener creates a new push listener that uses WRAPPER interface type.
+//
+// Parameters:
+//
+//	registry: Meters that are allowed to send push messages.
+func NewGXDLMSPushListener(registry IGXDLMSPushRegistry) *GXDLMSPushListener {
+	return &GXDLMSPushListener{Registry: registry, InterfaceType: enums.InterfaceTypeWRAPPER}
+}
+
+// newClient returns the client that is used to parse the received frames.
+func (g *GXDLMSPushListener) newClient() (*GXDLMSClient, error) {
+	if g.Registry == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	return NewGXDLMSClient(true, g.ClientAddress, 0, enums.AuthenticationNone, nil, g.InterfaceType)
+}
+
+// HandlePacket handles one received datagram.
+//
+// Parameters:
+//
+//	data: Received datagram.
+//
+// Returns:
+//
+//	Acknowledgement that is sent to the meter or nil if the push is not confirmed.
+func (g *GXDLMSPushListener) HandlePacket(data []byte) ([]byte, error) {
+	client, err := g.newClient()
+	if err != nil {
+		return nil, err
+	}
+	reply := NewGXReplyData()
+	complete, err := client.GetData(types.NewGXByteBufferWithData(data), reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !complete || reply.IsMoreData() {
+		return nil, dlmserrors.ErrDataTooShort
+	}
+	return g.handle(client, reply)
+}
+
+// HandleConnection reads push messages from the connection until it's closed.
+// Acknowledgements of the confirmed pushes are written to the same connection.
+//
+// Parameters:
+//
+//	conn: Connection to the meter.
+func (g *GXDLMSPushListener) HandleConnection(conn io.ReadWriter) error {
+	client, err := g.newClient()
+	if err != nil {
+		return err
+	}
+	buff := types.NewGXByteBuffer()
+	reply := NewGXReplyData()
+	received := make([]byte, 1024)
+	for {
+		count, err := conn.Read(received)
+		if count != 0 {
+			buff.Set(received[:count])
+			if err2 := g.receive(conn, client, buff, reply); err2 != nil {
+				return err2
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// receive handles all complete frames of the buffer.
+func (g *GXDLMSPushListener) receive(conn io.Writer, client *GXDLMSClient, buff *types.GXByteBuffer, reply *GXReplyData) error {
+	for buff.Available() != 0 {
+		if reply.Data.Size() == 0 {
+			// Meter address is resolved again for each push.
+			client.settings.ServerAddress = 0
+		}
+		complete, err := client.GetData(buff, reply, nil)
+		if err != nil {
+			// Received data is dropped.
+			buff.Clear()
+			reply.Clear()
+			g.onError(err)
+			return nil
+		}
+		if !complete {
+			break
+		}
+		if reply.IsMoreData() {
+			continue
+		}
+		ack, err := g.handle(client, reply)
+		reply.Clear()
+		if err != nil {
+			g.onError(err)
+		} else if ack != nil {
+			if _, err = conn.Write(ack); err != nil {
+				return err
+			}
+		}
+	}
+	buff.Trim()
+	return nil
+}
+
+// Serve accepts the connections and handles each connection in its own goroutine.
+// Serve returns when the listener is closed.
+//
+// Parameters:
+//
+//	listener: Network listener.
+func (g *GXDLMSPushListener) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := g.HandleConnection(conn); err != nil {
+				g.onError(err)
+			}
+		}()
+	}
+}
+
+func (g *GXDLMSPushListener) onError(err error) {
+	if g.OnError != nil {
+		g.OnError(err)
+	}
+}
+
+// handle decrypts and parses the received push and maps the values to the objects.
+func (g *GXDLMSPushListener) handle(client *GXDLMSClient, reply *GXReplyData) ([]byte, error) {
+	e := &GXDLMSPushEventArgs{Address: reply.TargetAddress}
+	var systemTitle []byte
+	if reply.Command() == enums.CommandGeneralGloCiphering {
+		var err error
+		if reply, systemTitle, err = g.decrypt(client, reply, e); err != nil {
+			return nil, &GXDLMSPushError{Meter: e.Meter, Address: e.Address, SystemTitle: systemTitle, Err: err}
+		}
+	} else {
+		meter, err := g.Registry.Find(e.Address, nil)
+		if err != nil {
+			return nil, err
+		}
+		if meter == nil {
+			return nil, &GXDLMSPushError{Address: e.Address, Err: dlmserrors.ErrUnknownPushSender}
+		}
+		e.Meter = meter
+		if meter.Security != enums.SecurityNone {
+			return nil, &GXDLMSPushError{Meter: meter, Address: e.Address, Err: dlmserrors.ErrInvalidSecurity}
+		}
+	}
+	e.Command = reply.Command()
+	e.InvokeID = reply.InvokeId()
+	e.Confirmed = e.Command == enums.CommandDataNotification && (e.InvokeID&0x40000000) != 0
+	e.Time = reply.Time
+	e.Value = reply.Value
+	g.mutex.Lock()
+	err := g.update(client, e)
+	if err == nil && g.OnPush != nil {
+		g.OnPush(e)
+	}
+	g.mutex.Unlock()
+	if err != nil {
+		return nil, &GXDLMSPushError{Meter: e.Meter, Address: e.Address, SystemTitle: systemTitle, Err: err}
+	}
+	if !e.Confirmed {
+		return nil, nil
+	}
+	return g.acknowledge(client, e.InvokeID)
+}
+
+// decrypt decrypts the general-glo-ciphering APDU and parses the decrypted push.
+func (g *GXDLMSPushListener) decrypt(client *GXDLMSClient, reply *GXReplyData, e *GXDLMSPushEventArgs) (*GXReplyData, []byte, error) {
+	data := types.NewGXByteBufferWithData(reply.Data.Array())
+	tag, err := data.Uint8()
+	if err != nil {
+		return nil, nil, err
+	}
+	count, err := types.GetObjectCount(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	systemTitle := make([]byte, count)
+	if err = data.Get(systemTitle); err != nil {
+		return nil, nil, err
+	}
+	if count, err = types.GetObjectCount(data); err != nil {
+		return nil, systemTitle, err
+	}
+	if data.Available() < count || count < 5 {
+		return nil, systemTitle, dlmserrors.ErrDataTooShort
+	}
+	meter, err := g.Registry.Find(e.Address, systemTitle)
+	if err != nil {
+		return nil, systemTitle, err
+	}
+	if meter == nil {
+		return nil, systemTitle, dlmserrors.ErrUnknownPushSender
+	}
+	e.Meter = meter
+	sc, err := data.Uint8()
+	if err != nil {
+		return nil, systemTitle, err
+	}
+	ic, err := data.Uint32()
+	if err != nil {
+		return nil, systemTitle, err
+	}
+	e.Security = enums.Security(sc & 0x30)
+	e.InvocationCounter = ic
+	// All the protections that the meter requires must be used.
+	if e.Security&meter.Security != meter.Security || (sc&0x80) != 0 {
+		return nil, systemTitle, dlmserrors.ErrInvalidSecurity
+	}
+	p := settings.NewAesGcmParameter(tag, client.settings, e.Security, enums.SecuritySuite(sc&0xF),
+		uint64(ic), systemTitle, meter.BlockCipherKey, meter.AuthenticationKey)
+	p.Broacast = (sc & 0x40) != 0
+	p.Type = settings.CountTypeData | settings.CountTypeTag
+	ciphered, err := data.SubArray(data.Position(), count-5)
+	if err != nil {
+		return nil, systemTitle, err
+	}
+	plain, err := settings.DecryptAesGcm(p, types.NewGXByteBufferWithData(ciphered))
+	if err != nil {
+		return nil, systemTitle, err
+	}
+	ret := NewGXReplyData()
+	ret.TargetAddress = reply.TargetAddress
+	if err = ret.Data.Set(plain); err != nil {
+		return nil, systemTitle, err
+	}
+	if err = GetPdu(client.settings, ret); err != nil {
+		return nil, systemTitle, err
+	}
+	return ret, systemTitle, nil
+}
+
+// update verifies the invocation counter and maps the pushed values to the objects of the push setup.
+// Caller must hold the mutex.
+func (g *GXDLMSPushListener) update(client *GXDLMSClient, e *GXDLMSPushEventArgs) error {
+	meter := e.Meter
+	if e.Security != enums.SecurityNone {
+		if e.InvocationCounter <= meter.InvocationCounter {
+			return NewGXDLMSExceptionResponse(enums.ExceptionStateErrorServiceNotAllowed,
+				enums.ExceptionServiceErrorInvocationCounterError, meter.InvocationCounter+1)
+		}
+		meter.InvocationCounter = e.InvocationCounter
+		if err := g.Registry.Update(meter); err != nil {
+			return err
+		}
+	}
+	if meter.PushSetup == nil || e.Command != enums.CommandDataNotification {
+		return nil
+	}
+	var values []any
+	switch v := e.Value.(type) {
+	case types.GXStructure:
+		values = v
+	case []any:
+		values = v
+	default:
+		return fmt.Errorf("invalid push value type: %T", e.Value)
+	}
+	if err := meter.PushSetup.GetPushValues(client, values); err != nil {
+		return err
+	}
+	e.Objects = meter.PushSetup.PushObjectList
+	return nil
+}
+
+// acknowledge returns the data notification confirm frame.
+func (g *GXDLMSPushListener) acknowledge(client *GXDLMSClient, invokeID uint32) ([]byte, error) {
+	pdu := types.NewGXByteBuffer()
+	if err := pdu.SetUint8(enums.CommandDataNotificationConfirm); err != nil {
+		return nil, err
+	}
+	if err := pdu.SetUint32(invokeID); err != nil {
+		return nil, err
+	}
+	switch {
+	case client.settings.InterfaceType == enums.InterfaceTypeWRAPPER:
+		return getWrapperFrame(client.settings, enums.CommandDataNotificationConfirm, pdu)
+	case useHdlc(client.settings.InterfaceType):
+		if err := addLLCBytes(client.settings, pdu); err != nil {
+			return nil, err
+		}
+		return getHdlcFrame(client.settings, 0x13, pdu, true)
+	}
+	return bytes.Clone(pdu.Array()), nil
+}
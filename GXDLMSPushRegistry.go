@@ -0,0 +1,164 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/objects"
+)
+
+// GXDLMSPushMeter describes a meter that sends push messages to the push listener.
+type GXDLMSPushMeter struct {
+	// Name of the meter.
+	Name string
+
+	// WRAPPER or HDLC source address of the meter.
+	// Zero if the meter is identified only by the system title.
+	Address int
+
+	// System title of the meter.
+	SystemTitle []byte
+
+	// Minimum security level that the pushes must use.
+	Security enums.Security
+
+	// Block cipher key.
+	BlockCipherKey []byte
+
+	// Authentication key.
+	AuthenticationKey []byte
+
+	// Invocation counter of the last accepted push.
+	InvocationCounter uint32
+
+	// Push setup which push object list describes the pushed values.
+	// Received values are not mapped to the objects if push setup is nil.
+	PushSetup *objects.GXDLMSPushSetup
+}
+
+// IGXDLMSPushRegistry returns the meters that are allowed to send push messages.
+type IGXDLMSPushRegistry interface {
+	// Find returns the meter that has sent the push.
+	//
+	// Parameters:
+	//
+	//	address: Source address of the meter.
+	//	systemTitle: System title of the meter or nil if the push is not ciphered.
+	//
+	// Returns:
+	//
+	//	Meter or nil if the meter is unknown.
+	Find(address int, systemTitle []byte) (*GXDLMSPushMeter, error)
+
+	// Update stores the invocation counter of the meter after the ciphered push is accepted.
+	//
+	// Parameters:
+	//
+	//	meter: Updated meter.
+	Update(meter *GXDLMSPushMeter) error
+}
+
+// GXDLMSPushRegistry keeps the push meters in memory.
+type GXDLMSPushRegistry struct {
+	mutex         sync.RWMutex
+	byAddress     map[int]*GXDLMSPushMeter
+	bySystemTitle map[string]*GXDLMSPushMeter
+}
+
+// NewGXDLMSPushRegistry creates a new in-memory push registry.
+func NewGXDLMSPushRegistry() *GXDLMSPushRegistry {
+	return &GXDLMSPushRegistry{
+		byAddress:     map[int]*GXDLMSPushMeter{},
+		bySystemTitle: map[string]*GXDLMSPushMeter{},
+	}
+}
+
+// Add adds a new meter to the registry.
+//
+// Parameters:
+//
+//	meter: Added meter. Address or system title must be given.
+func (g *GXDLMSPushRegistry) Add(meter *GXDLMSPushMeter) error {
+	if meter == nil || (meter.Address == 0 && len(meter.SystemTitle) == 0) {
+		return gxcommon.ErrInvalidArgument
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if meter.Address != 0 {
+		g.byAddress[meter.Address] = meter
+	}
+	if len(meter.SystemTitle) != 0 {
+		g.bySystemTitle[hex.EncodeToString(meter.SystemTitle)] = meter
+	}
+	return nil
+}
+
+// Remove removes the meter from the registry.
+//
+// Parameters:
+//
+//	meter: Removed meter.
+func (g *GXDLMSPushRegistry) Remove(meter *GXDLMSPushMeter) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.byAddress[meter.Address] == meter {
+		delete(g.byAddress, meter.Address)
+	}
+	key := hex.EncodeToString(meter.SystemTitle)
+	if g.bySystemTitle[key] == meter {
+		delete(g.bySystemTitle, key)
+	}
+}
+
+// Find implements IGXDLMSPushRegistry.
+// Meter is searched by the system title when it's given and by the address otherwise.
+func (g *GXDLMSPushRegistry) Find(address int, systemTitle []byte) (*GXDLMSPushMeter, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	if len(systemTitle) != 0 {
+		return g.bySystemTitle[hex.EncodeToString(systemTitle)], nil
+	}
+	return g.byAddress[address], nil
+}
+
+// Update implements IGXDLMSPushRegistry.
+// Meters are kept in memory and nothing is stored.
+func (g *GXDLMSPushRegistry) Update(meter *GXDLMSPushMeter) error {
+	return nil
+}
@@ -0,0 +1,432 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// pushState is a push that is waiting to be sent.
+type pushState struct {
+	// Time when the push is sent.
+	due time.Time
+
+	// End of the communication window. Zero if the window is not limited.
+	end time.Time
+
+	// Amount of failed attempts.
+	retry int
+}
+
+// GXDLMSPushScheduler executes the single action schedules and sends the pushes on the server side.
+//
+// Evaluate is called periodically. Skipped fields of the execution times are handled as wildcards
+// and the referenced script is executed when the execution time has elapsed. If the script invokes
+// the push method of the push setup, the push is sent inside the communication window after
+// the randomisation delay. Failed push is repeated after the repetition delay until the retries are used.
+//
+// Times are handled in the meter local time. The location of the clock time is used.
+// Evaluate and Trigger are not safe for concurrent use.
+type GXDLMSPushScheduler struct {
+	// Server whose objects are scheduled.
+	Server *GXDLMSServer
+
+	// Clock returns the current time. time.Now is used if this is not set.
+	Clock func() time.Time
+
+	// Random returns the randomisation delay between zero and the given maximum delay.
+	// Random delay is used if this is not set.
+	Random func(max time.Duration) time.Duration
+
+	// Optional auto connect object. Calling windows are used
+	// if the push setup doesn't define communication windows.
+	AutoConnect *objects.GXDLMSAutoConnect
+
+	// OnPush sends the generated push frames. Push is repeated if an error is returned.
+	OnPush func(push *objects.GXDLMSPushSetup, messages [][]byte) error
+
+	// OnError is called when the push is not sent after all retries.
+	OnError func(push *objects.GXDLMSPushSetup, err error)
+
+	// OnExecute is called to execute the script.
+	// Script actions are applied to the object model and the pushes are triggered if this is not set.
+	OnExecute func(table *objects.GXDLMSScriptTable, script *objects.GXDLMSScript) error
+
+	// Time when the action schedules were evaluated.
+	last time.Time
+
+	// Pushes that are waiting to be sent.
+	pending map[*objects.GXDLMSPushSetup]*pushState
+}
+
+// NewGXDLMSPushScheduler creates a new push scheduler.
+//
+// Parameters:
+//
+//	server: DLMS server.
+//	onPush: Sends the generated push frames.
+func NewGXDLMSPushScheduler(server *GXDLMSServer, onPush func(push *objects.GXDLMSPushSetup, messages [][]byte) error) *GXDLMSPushScheduler {
+	return &GXDLMSPushScheduler{Server: server, OnPush: onPush, pending: map[*objects.GXDLMSPushSetup]*pushState{}}
+}
+
+// now returns the current time.
+func (g *GXDLMSPushScheduler) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// random returns the randomisation delay.
+func (g *GXDLMSPushScheduler) random(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	if g.Random != nil {
+		return g.Random(max)
+	}
+	return time.Duration(rand.Int64N(int64(max) + 1))
+}
+
+// Reset clears the waiting pushes and the evaluation time.
+func (g *GXDLMSPushScheduler) Reset() {
+	g.last = time.Time{}
+	g.pending = map[*objects.GXDLMSPushSetup]*pushState{}
+}
+
+// Trigger schedules the push. Push is sent in the next communication window
+// after the randomisation delay. If the push is already waiting, it's not scheduled again.
+//
+// Parameters:
+//
+//	push: Push setup.
+func (g *GXDLMSPushScheduler) Trigger(push *objects.GXDLMSPushSetup) error {
+	if push == nil {
+		return gxcommon.ErrInvalidArgument
+	}
+	if _, ok := g.pending[push]; ok {
+		return nil
+	}
+	return g.schedule(push, g.now(), 0, true)
+}
+
+// Pending returns true if the push is waiting to be sent.
+func (g *GXDLMSPushScheduler) Pending(push *objects.GXDLMSPushSetup) bool {
+	_, ok := g.pending[push]
+	return ok
+}
+
+// Next returns the next time when a script is executed or a push is sent.
+// Zero time is returned if nothing is scheduled.
+func (g *GXDLMSPushScheduler) Next() time.Time {
+	if g.Server == nil {
+		return time.Time{}
+	}
+	now := g.now()
+	var ret time.Time
+	update := func(value time.Time) {
+		if ret.IsZero() || value.Before(ret) {
+			ret = value
+		}
+	}
+	for _, it := range g.pending {
+		update(it.due)
+	}
+	for _, it := range g.Server.items {
+		if schedule, ok := it.(*objects.GXDLMSActionSchedule); ok {
+			for pos := range schedule.ExecutionTime {
+				if t, ok := findDateTime(&schedule.ExecutionTime[pos], now.Add(time.Second), true); ok {
+					update(t)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Evaluate executes the scripts of the elapsed execution times and sends the pushes that are due.
+// Execution times before the first evaluation are not executed.
+func (g *GXDLMSPushScheduler) Evaluate() error {
+	if g.Server == nil {
+		return dlmserrors.ErrServerNotInitialized
+	}
+	if g.pending == nil {
+		g.pending = map[*objects.GXDLMSPushSetup]*pushState{}
+	}
+	now := g.now()
+	if !g.last.IsZero() {
+		for _, it := range g.Server.items {
+			if schedule, ok := it.(*objects.GXDLMSActionSchedule); ok && g.elapsed(schedule, now) {
+				if err := g.execute(schedule); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	g.last = now
+	for push, state := range g.pending {
+		if now.Before(state.due) {
+			continue
+		}
+		if !state.end.IsZero() && now.After(state.end) {
+			// Communication window was missed.
+			if err := g.schedule(push, now, state.retry, true); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := g.send(push, state, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elapsed returns true if an execution time of the action schedule has elapsed after the last evaluation.
+func (g *GXDLMSPushScheduler) elapsed(schedule *objects.GXDLMSActionSchedule, now time.Time) bool {
+	for pos := range schedule.ExecutionTime {
+		if t, ok := findDateTime(&schedule.ExecutionTime[pos], now, false); ok && t.After(g.last) {
+			return true
+		}
+	}
+	return false
+}
+
+// execute executes the script of the action schedule.
+func (g *GXDLMSPushScheduler) execute(schedule *objects.GXDLMSActionSchedule) error {
+	ln := schedule.ExecutedScriptLogicalName
+	if schedule.Target != nil {
+		ln = schedule.Target.LogicalName()
+	}
+	if ln == "" || ln == "0.0.0.0.0.0" {
+		return nil
+	}
+	table, ok := g.Server.items.FindByLN(enums.ObjectTypeScriptTable, ln).(*objects.GXDLMSScriptTable)
+	if !ok {
+		if schedule.Target == nil {
+			return fmt.Errorf("script table %s not found", ln)
+		}
+		table = schedule.Target
+	}
+	for pos := range table.Scripts {
+		script := &table.Scripts[pos]
+		if script.Id != schedule.ExecutedScriptSelector {
+			continue
+		}
+		if g.OnExecute != nil {
+			return g.OnExecute(table, script)
+		}
+		for _, it := range script.Actions {
+			if it.Target == nil {
+				continue
+			}
+			if it.Type == enums.ScriptActionTypeExecute && it.Index == 1 && it.Target.Base().ObjectType() == enums.ObjectTypePushSetup {
+				push, ok := g.Server.items.FindByLN(enums.ObjectTypePushSetup, it.Target.Base().LogicalName()).(*objects.GXDLMSPushSetup)
+				if !ok {
+					return fmt.Errorf("push setup %s not found", it.Target.Base().LogicalName())
+				}
+				if err := g.Trigger(push); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := objects.ExecuteScript(g.Server.settings, &objects.GXDLMSScript{Id: script.Id, Actions: []objects.GXDLMSScriptAction{it}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("script %d not found from script table %s", schedule.ExecutedScriptSelector, ln)
+}
+
+// send generates the push frames and sends them.
+func (g *GXDLMSPushScheduler) send(push *objects.GXDLMSPushSetup, state *pushState, now time.Time) error {
+	messages, err := g.Server.GeneratePushSetupMessages(&now, push)
+	if err != nil {
+		delete(g.pending, push)
+		return err
+	}
+	if g.OnPush != nil {
+		err = g.OnPush(push, messages)
+	}
+	if err == nil {
+		delete(g.pending, push)
+		return nil
+	}
+	retry := state.retry + 1
+	if retry > int(push.NumberOfRetries) {
+		delete(g.pending, push)
+		if g.OnError != nil {
+			g.OnError(push, err)
+		}
+		return nil
+	}
+	return g.schedule(push, now.Add(repetitionDelay(push, retry)), retry, false)
+}
+
+// schedule resolves the time when the push is sent at the earliest at the given time.
+// Randomisation delay is added if the push is started or it's moved to the next communication window.
+func (g *GXDLMSPushScheduler) schedule(push *objects.GXDLMSPushSetup, from time.Time, retry int, randomise bool) error {
+	windows := push.CommunicationWindow
+	if len(windows) == 0 && g.AutoConnect != nil {
+		switch g.AutoConnect.Mode {
+		case enums.AutoConnectModeAutoDiallingAllowedCallingWindow,
+			enums.AutoConnectModeRegularAutoDiallingAllowedCallingWindow,
+			enums.AutoConnectModeConnectWithCallingWindow:
+			windows = g.AutoConnect.CallingWindow
+		}
+	}
+	delay := time.Duration(push.RandomisationStartInterval) * time.Second
+	list := pushWindows(windows, delay, from, 1)
+	if len(list) == 0 {
+		delete(g.pending, push)
+		return fmt.Errorf("%w: communication window of %s is not found", dlmserrors.ErrInvalidPushSetup, push.LogicalName())
+	}
+	w := list[0]
+	due := w.Earliest
+	if randomise || w.Earliest.After(from) {
+		due = due.Add(g.random(w.Latest.Sub(w.Earliest)))
+	}
+	g.pending[push] = &pushState{due: due, end: w.End, retry: retry}
+	return nil
+}
+
+// repetitionDelay returns the delay before the push is repeated.
+//
+// Parameters:
+//
+//	push: Push setup.
+//	retry: Number of the repetition starting from one.
+func repetitionDelay(push *objects.GXDLMSPushSetup, retry int) time.Duration {
+	if push.Version < 2 {
+		return time.Duration(push.RepetitionDelay) * time.Second
+	}
+	d := &push.RepetitionDelay2
+	// Delay is min * (exponent * 0.01) ^ (retry - 1). Zero exponent keeps the delay constant.
+	value := float64(d.Min)
+	if d.Exponent != 0 {
+		value *= math.Pow(float64(d.Exponent)*0.01, float64(retry-1))
+	}
+	if d.Max != 0 && value > float64(d.Max) {
+		value = float64(d.Max)
+	}
+	return time.Duration(value * float64(time.Second))
+}
+
+// GenerateDataNotificationMessages generates the data notification messages.
+//
+// Parameters:
+//
+//	time: Send time. Time is not sent if it is nil.
+//	data: Notification body.
+//
+// Returns:
+//
+//	Data notification messages.
+func (g *GXDLMSServer) GenerateDataNotificationMessages(time *time.Time, data *types.GXByteBuffer) ([][]byte, error) {
+	if !g.initialized {
+		return nil, dlmserrors.ErrServerNotInitialized
+	}
+	// Data notification is encoded in the same way with short and logical name referencing.
+	p := NewGXDLMSLNParameters(g.settings, 0, enums.CommandDataNotification, 0, nil, data, 0xff, enums.CommandNone)
+	if time != nil {
+		p.time = types.NewGXDateTimeFromTime(*time)
+	}
+	return getLnMessages(p)
+}
+
+// GeneratePushSetupMessages generates the data notification messages of the push object list.
+//
+// Parameters:
+//
+//	time: Send time. Time is not sent if it is nil.
+//	push: Push setup object.
+//
+// Returns:
+//
+//	Data notification messages.
+func (g *GXDLMSServer) GeneratePushSetupMessages(time *time.Time, push *objects.GXDLMSPushSetup) ([][]byte, error) {
+	if push == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	data := types.NewGXByteBuffer()
+	if err := data.SetUint8(uint8(enums.DataTypeStructure)); err != nil {
+		return nil, err
+	}
+	if err := types.SetObjectCount(len(push.PushObjectList), data); err != nil {
+		return nil, err
+	}
+	for _, it := range push.PushObjectList {
+		if it.Value.AttributeIndex != 0 {
+			if err := g.appendPushValue(it.Key, it.Value.AttributeIndex, data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// All attributes of the object are sent.
+		if err := data.SetUint8(uint8(enums.DataTypeStructure)); err != nil {
+			return nil, err
+		}
+		if err := types.SetObjectCount(it.Key.GetAttributeCount(), data); err != nil {
+			return nil, err
+		}
+		for index := 1; index <= it.Key.GetAttributeCount(); index++ {
+			if err := g.appendPushValue(it.Key, index, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.GenerateDataNotificationMessages(time, data)
+}
+
+// appendPushValue adds the attribute value to the push data.
+func (g *GXDLMSServer) appendPushValue(target objects.IGXDLMSBase, index int, data *types.GXByteBuffer) error {
+	e := internal.NewValueEventArgs2(g, target, uint8(index))
+	value, err := target.GetValue(g.settings, e)
+	if err != nil {
+		return err
+	}
+	return appendData(g.settings, target, uint8(index), data, value)
+}
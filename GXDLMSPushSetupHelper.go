@@ -0,0 +1,384 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Gurux/gxcommon-go"
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// maxScheduleSearchDays is the number of days that are searched when the next matching date time is resolved.
+// Leap day can be used as a wildcard date and it's repeated only every fourth year.
+const maxScheduleSearchDays = 8 * 366
+
+// GXDLMSPushWindow describes when the push can be sent.
+type GXDLMSPushWindow struct {
+	// Start time of the communication window.
+	Start time.Time
+
+	// End time of the communication window. Zero if the push setup doesn't define communication windows.
+	End time.Time
+
+	// Earliest time when the push is started.
+	Earliest time.Time
+
+	// Latest time when the push is started when the randomisation start interval is added.
+	// Latest time never exceeds the end of the communication window.
+	Latest time.Time
+}
+
+// GXDLMSPushProtection describes how the pushed data is protected.
+type GXDLMSPushProtection struct {
+	// Pushed data is authenticated and encrypted.
+	Ciphered bool
+
+	// Pushed data is digitally signed.
+	Signed bool
+
+	// System title of the meter.
+	OriginatorSystemTitle []byte
+
+	// System title of the push receiver. Optional.
+	RecipientSystemTitle []byte
+
+	// Key that is used to protect the pushed data.
+	KeyInfo objects.GXDLMSDataProtectionKey
+
+	// Push operation method. Push setup version 2 is required if retry on missing confirmation is used.
+	OperationMethod enums.PushOperationMethod
+
+	// Confirmation parameters. Push setup version 2 is required. Optional.
+	Confirmation *objects.GXPushConfirmationParameter
+}
+
+// GXDLMSPushSetupHelper validates the push setup against the object model,
+// resolves the push times and plans the writes that enable the protected pushes.
+//
+// Communication windows are handled in the meter local time.
+// The location of the given time is used to build the window times.
+type GXDLMSPushSetupHelper struct {
+	// DLMS client.
+	Client *GXDLMSClient
+
+	// Object model of the meter, example the association view.
+	Objects objects.GXDLMSObjectCollection
+}
+
+// NewGXDLMSPushSetupHelper creates a new push setup helper.
+//
+// Parameters:
+//
+//	client: DLMS client.
+//	items: Object model of the meter.
+func NewGXDLMSPushSetupHelper(client *GXDLMSClient, items objects.GXDLMSObjectCollection) *GXDLMSPushSetupHelper {
+	return &GXDLMSPushSetupHelper{Client: client, Objects: items}
+}
+
+// Validate checks that the pushed objects and attributes exist in the object model and they can be read.
+// If the attribute index of the push object is zero, the whole object is pushed and only the object is checked.
+//
+// Parameters:
+//
+//	push: Push setup.
+//
+// Returns:
+//
+//	Push objects that can't be pushed.
+func (g *GXDLMSPushSetupHelper) Validate(push *objects.GXDLMSPushSetup) ([]*GXDLMSAttributeError, error) {
+	if g.Client == nil || push == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	var ret []*GXDLMSAttributeError
+	for _, it := range push.PushObjectList {
+		if it.Key == nil {
+			return nil, gxcommon.ErrInvalidArgument
+		}
+		index := it.Value.AttributeIndex
+		target := g.Objects.FindByLN(it.Key.Base().ObjectType(), it.Key.Base().LogicalName())
+		switch {
+		case target == nil:
+			ret = append(ret, &GXDLMSAttributeError{Target: it.Key, Index: index, ErrorCode: enums.ErrorCodeUndefinedObject})
+		case index < 0 || index > target.GetAttributeCount():
+			ret = append(ret, &GXDLMSAttributeError{Target: target, Index: index, Err: dlmserrors.ErrInvalidAttributeIndex})
+		case index != 0 && !g.Client.CanRead(target, index):
+			ret = append(ret, &GXDLMSAttributeError{Target: target, Index: index, ErrorCode: enums.ErrorCodeReadWriteDenied})
+		}
+	}
+	// Port reference is used to send the push.
+	if push.PortReference != nil && push.PortReference.Base().LogicalName() != "" &&
+		g.Objects.FindByLN(push.PortReference.Base().ObjectType(), push.PortReference.Base().LogicalName()) == nil {
+		ret = append(ret, &GXDLMSAttributeError{Target: push.PortReference, ErrorCode: enums.ErrorCodeUndefinedObject})
+	}
+	return ret, nil
+}
+
+// NextPushTimes returns the next communication windows when the push can be sent.
+// If the push setup doesn't define communication windows, the push can be sent immediately
+// and only one window is returned.
+//
+// Parameters:
+//
+//	push: Push setup.
+//	from: Time in meter local time.
+//	count: Maximum amount of returned windows.
+//
+// Returns:
+//
+//	Communication windows in the order they are started.
+func (g *GXDLMSPushSetupHelper) NextPushTimes(push *objects.GXDLMSPushSetup, from time.Time, count int) ([]GXDLMSPushWindow, error) {
+	if push == nil || count < 1 {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	delay := time.Duration(push.RandomisationStartInterval) * time.Second
+	return pushWindows(push.CommunicationWindow, delay, from, count), nil
+}
+
+// ProtectionPlan returns the writes that enable the ciphered or signed pushes.
+// Push protection parameters are available from push setup version 1.
+//
+// Parameters:
+//
+//	push: Push setup read from the meter.
+//	protection: Protection of the pushed data.
+//
+// Returns:
+//
+//	Configuration plan.
+func (g *GXDLMSPushSetupHelper) ProtectionPlan(push *objects.GXDLMSPushSetup, protection *GXDLMSPushProtection) (*GXDLMSConfigurationPlan, error) {
+	if g.Client == nil || push == nil || protection == nil {
+		return nil, gxcommon.ErrInvalidArgument
+	}
+	if !protection.Ciphered && !protection.Signed {
+		return nil, fmt.Errorf("%w: protection type is not set", dlmserrors.ErrInvalidPushSetup)
+	}
+	if push.Version < 1 {
+		return nil, fmt.Errorf("%w: push protection is not supported in version %d", dlmserrors.ErrInvalidPushSetup, push.Version)
+	}
+	if push.Version < 2 && (protection.OperationMethod != enums.PushOperationMethodUnconfirmedFailure || protection.Confirmation != nil) {
+		return nil, fmt.Errorf("%w: push operation method is not supported in version %d", dlmserrors.ErrInvalidPushSetup, push.Version)
+	}
+	if len(protection.OriginatorSystemTitle) != 8 {
+		return nil, fmt.Errorf("%w: invalid originator system title", dlmserrors.ErrInvalidPushSetup)
+	}
+	if len(protection.RecipientSystemTitle) != 0 && len(protection.RecipientSystemTitle) != 8 {
+		return nil, fmt.Errorf("%w: invalid recipient system title", dlmserrors.ErrInvalidPushSetup)
+	}
+	var parameters []objects.GXPushProtectionParameters
+	add := func(protectionType enums.ProtectionType) {
+		parameters = append(parameters, objects.GXPushProtectionParameters{
+			ProtectionType:        protectionType,
+			OriginatorSystemTitle: protection.OriginatorSystemTitle,
+			RecipientSystemTitle:  protection.RecipientSystemTitle,
+			KeyInfo:               protection.KeyInfo,
+		})
+	}
+	if protection.Ciphered {
+		add(enums.ProtectionTypeAuthenticationEncryption)
+	}
+	if protection.Signed {
+		add(enums.ProtectionTypeDigitalSignature)
+	}
+	desired := *push
+	desired.PushProtectionParameters = parameters
+	if push.Version > 1 {
+		desired.PushOperationMethod = protection.OperationMethod
+		if protection.Confirmation != nil {
+			desired.ConfirmationParameters = *protection.Confirmation
+		}
+	}
+	planner := NewGXDLMSConfigurationPlanner(g.Client, nil)
+	return planner.Plan(objects.GXDLMSObjectCollection{&desired}, objects.GXDLMSObjectCollection{push})
+}
+
+// pushWindows returns the next communication windows when the push can be sent.
+// If there are no communication windows, the push can be sent immediately.
+func pushWindows(communicationWindow []types.GXKeyValuePair[types.GXDateTime, types.GXDateTime], delay time.Duration, from time.Time, count int) []GXDLMSPushWindow {
+	if len(communicationWindow) == 0 {
+		return []GXDLMSPushWindow{{Start: from, Earliest: from, Latest: from.Add(delay)}}
+	}
+	var ret []GXDLMSPushWindow
+	cursor := from
+	for len(ret) < count {
+		var windows []GXDLMSPushWindow
+		for pos := range communicationWindow {
+			it := &communicationWindow[pos]
+			if start, end, ok := nextWindow(&it.Key, &it.Value, cursor); ok {
+				windows = append(windows, newPushWindow(start, end, cursor, delay))
+			}
+		}
+		if len(windows) == 0 {
+			break
+		}
+		sort.Slice(windows, func(i, j int) bool {
+			return windows[i].Earliest.Before(windows[j].Earliest)
+		})
+		ret = append(ret, windows[0])
+		cursor = windows[0].End
+	}
+	return ret
+}
+
+// newPushWindow returns the push window when the push can be sent at the earliest at the given time.
+func newPushWindow(start time.Time, end time.Time, from time.Time, delay time.Duration) GXDLMSPushWindow {
+	ret := GXDLMSPushWindow{Start: start, End: end, Earliest: start}
+	if from.After(start) {
+		ret.Earliest = from
+	}
+	ret.Latest = ret.Earliest.Add(delay)
+	if ret.Latest.After(end) {
+		ret.Latest = end
+	}
+	return ret
+}
+
+// nextWindow returns the communication window that is open at the given time or is opened next.
+func nextWindow(start *types.GXDateTime, end *types.GXDateTime, from time.Time) (time.Time, time.Time, bool) {
+	if s, ok := findDateTime(start, from, false); ok {
+		if e, ok := findDateTime(end, s.Add(time.Second), true); ok && e.After(from) {
+			return s, e, true
+		}
+	}
+	s, ok := findDateTime(start, from, true)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	e, ok := findDateTime(end, s.Add(time.Second), true)
+	return s, e, ok
+}
+
+// findDateTime returns the first time that matches the date time at or after the given time.
+// If forward is false, the last time that matches at or before the given time is returned.
+// Skipped date and time fields are handled as wildcards. Skipped seconds are handled as zero.
+func findDateTime(value *types.GXDateTime, from time.Time, forward bool) (time.Time, bool) {
+	step := 1
+	if !forward {
+		step = -1
+	}
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for i := 0; i < maxScheduleSearchDays; i++ {
+		if (value.Skip & enums.DateTimeSkipsYear) == 0 {
+			if (forward && day.Year() > value.Value.Year()) || (!forward && day.Year() < value.Value.Year()) {
+				break
+			}
+		}
+		if dateMatches(value, day) {
+			times := timesOfDay(value, day)
+			if forward {
+				for _, t := range times {
+					if !t.Before(from) {
+						return t, true
+					}
+				}
+			} else {
+				for pos := len(times) - 1; pos >= 0; pos-- {
+					if !times[pos].After(from) {
+						return times[pos], true
+					}
+				}
+			}
+		}
+		day = day.AddDate(0, 0, step)
+	}
+	return time.Time{}, false
+}
+
+// dateMatches returns true if the date part of the date time matches the given day.
+func dateMatches(value *types.GXDateTime, day time.Time) bool {
+	if (value.Skip&enums.DateTimeSkipsYear) == 0 && value.Value.Year() != day.Year() {
+		return false
+	}
+	if (value.Skip&enums.DateTimeSkipsMonth) == 0 && value.Value.Month() != day.Month() {
+		return false
+	}
+	lastDay := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	switch {
+	case (value.Extra & enums.DateTimeExtraInfoLastDay) != 0:
+		if day.Day() != lastDay {
+			return false
+		}
+	case (value.Extra & enums.DateTimeExtraInfoLastDay2) != 0:
+		if day.Day() != lastDay-1 {
+			return false
+		}
+	case (value.Skip&enums.DateTimeSkipsDay) == 0 && value.Value.Day() != day.Day():
+		return false
+	}
+	if (value.Skip&enums.DateTimeSkipsDayOfWeek) == 0 && value.DayOfWeek > 0 && value.DayOfWeek < 8 {
+		dayOfWeek := int(day.Weekday())
+		if dayOfWeek == 0 {
+			dayOfWeek = 7
+		}
+		if value.DayOfWeek != dayOfWeek {
+			return false
+		}
+	}
+	return true
+}
+
+// timesOfDay returns the times of the given day that match the time part of the date time in ascending order.
+func timesOfDay(value *types.GXDateTime, day time.Time) []time.Time {
+	hours := []int{value.Value.Hour()}
+	if (value.Skip & enums.DateTimeSkipsHour) != 0 {
+		hours = make([]int, 24)
+		for pos := range hours {
+			hours[pos] = pos
+		}
+	}
+	minutes := []int{value.Value.Minute()}
+	if (value.Skip & enums.DateTimeSkipsMinute) != 0 {
+		minutes = make([]int, 60)
+		for pos := range minutes {
+			minutes[pos] = pos
+		}
+	}
+	second := value.Value.Second()
+	if (value.Skip & enums.DateTimeSkipsSecond) != 0 {
+		second = 0
+	}
+	ret := make([]time.Time, 0, len(hours)*len(minutes))
+	for _, h := range hours {
+		for _, m := range minutes {
+			ret = append(ret, time.Date(day.Year(), day.Month(), day.Day(), h, m, second, 0, day.Location()))
+		}
+	}
+	return ret
+}
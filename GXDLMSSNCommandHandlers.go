@@ -52,8 +52,8 @@ func handleRead(settings *settings.GXDLMSSettings,
 	server *GXDLMSServer,
 	type_ uint8,
 	data *types.GXByteBuffer,
-	list []*internal.ValueEventArgs,
-	reads []*internal.ValueEventArgs,
+	list *[]*internal.ValueEventArgs,
+	reads *[]*internal.ValueEventArgs,
 	replyData *types.GXByteBuffer,
 	xml *settings.GXDLMSTranslatorStructure,
 	cipheredCommand enums.Command) error {
@@ -88,7 +88,11 @@ func handleRead(settings *settings.GXDLMSSettings,
 		return nil
 	}
 	info := FindServerSNObject(server, sn)
-	e := internal.NewValueEventArgs2(server, info.Item, info.Index)
+	var target any
+	if info.Item != nil {
+		target = info.Item
+	}
+	e := internal.NewValueEventArgs2(server, target, info.Index)
 	e.Action = info.IsAction
 	if type_ == uint8(constants.VariableAccessSpecificationParameterisedAccess) {
 		e.Selector, err = data.Uint8()
@@ -103,7 +107,7 @@ func handleRead(settings *settings.GXDLMSSettings,
 	}
 	// Return error if connection is not established.
 	if (settings.Connected&enums.ConnectionStateDlms) == 0 && cipheredCommand == enums.CommandNone &&
-		(!e.Action || e.Target.(objects.IGXDLMSBase).Base().ShortName != -1536 || e.Index != 8) {
+		(!e.Action || info.Item == nil || info.Item.Base().ShortName != associationShortName || e.Index != 8) {
 		replyData.Add(GenerateConfirmedServiceError(enums.ConfirmedServiceErrorInitiateError, enums.ServiceErrorService, uint8(enums.ServiceUnsupported)))
 		return nil
 	}
@@ -113,13 +117,15 @@ func handleRead(settings *settings.GXDLMSSettings,
 			return err
 		}
 	}
-	list = append(list, e)
-	if !e.Action && server.NotifyGetAttributeAccess(e) == 0 {
+	*list = append(*list, e)
+	if info.Item == nil {
+		e.Error = enums.ErrorCodeUndefinedObject
+	} else if !e.Action && server.NotifyGetAttributeAccess(e)&int(enums.AccessModeRead) == 0 {
 		e.Error = enums.ErrorCodeReadWriteDenied
 	} else if e.Action && (server.NotifyGetMethodAccess(e)&int(enums.MethodAccessModeAccess)) == 0 {
 		e.Error = enums.ErrorCodeReadWriteDenied
 	} else {
-		reads = append(reads, e)
+		*reads = append(*reads, e)
 	}
 	return nil
 }
@@ -310,7 +316,7 @@ func handleReadDataBlockAccess(settings *settings.GXDLMSSettings,
 func FindServerSNObject(server *GXDLMSServer, sn int16) gxSNInfo {
 	i := FindSNObject(server.Items().(objects.GXDLMSObjectCollection), sn)
 	if i.Item == nil {
-		i.Item = server.NotifyFindObject(enums.ObjectTypeNone, int(sn), "").(objects.IGXDLMSBase)
+		i.Item, _ = server.NotifyFindObject(enums.ObjectTypeNone, int(sn), "").(objects.IGXDLMSBase)
 	}
 	return i
 }
@@ -350,7 +356,7 @@ func getReadData(settings *settings.GXDLMSSettings,
 	for _, e := range list {
 		if e.Handled {
 			value = e.Value
-		} else {
+		} else if e.Error == 0 {
 			// If action.
 			if e.Action {
 				value, err = e.Target.(objects.IGXDLMSBase).Invoke(settings, e)
@@ -435,7 +441,7 @@ func FindSNObject(items objects.GXDLMSObjectCollection, sn int16) gxSNInfo {
 			} else {
 				//If method is accessed.
 				getActionInfo(it.Base().ObjectType(), &offset, &count)
-				if sn < tmp+int16(offset)+(8*int16(count)) {
+				if sn >= tmp+int16(offset) && sn < tmp+int16(offset)+(8*int16(count)) {
 					i.Item = it
 					i.IsAction = true
 					i.Index = uint8((sn-tmp-int16(offset))/8) + 1
@@ -466,6 +472,7 @@ func handleReadRequest(settings *settings.GXDLMSSettings, server *GXDLMSServer,
 	bb := types.GXByteBuffer{}
 	list := []*internal.ValueEventArgs{}
 	reads := []*internal.ValueEventArgs{}
+	actions := []*internal.ValueEventArgs{}
 	// If get next frame.
 	if xml == nil && data.Size() == 0 {
 		if replyData.Available() != 0 {
@@ -488,8 +495,8 @@ func handleReadRequest(settings *settings.GXDLMSSettings, server *GXDLMSServer,
 			if err != nil {
 				return err
 			}
-			if type_ == uint8(constants.VariableAccessSpecificationBlockNumberAccess) || type_ == uint8(constants.VariableAccessSpecificationParameterisedAccess) {
-				err = handleRead(settings, server, type_, data, list, reads, replyData, xml, cipheredCommand)
+			if type_ == uint8(constants.VariableAccessSpecificationVariableName) || type_ == uint8(constants.VariableAccessSpecificationParameterisedAccess) {
+				err = handleRead(settings, server, type_, data, &list, &reads, replyData, xml, cipheredCommand)
 				if err != nil {
 					return err
 				}
@@ -514,9 +521,22 @@ func handleReadRequest(settings *settings.GXDLMSSettings, server *GXDLMSServer,
 				return err
 			}
 		}
+		// Actions are invoked with the read request and they are notified as actions.
+		tmp := reads
+		reads = nil
+		for _, it := range tmp {
+			if it.Action {
+				actions = append(actions, it)
+			} else {
+				reads = append(reads, it)
+			}
+		}
 		if len(reads) != 0 {
 			server.NotifyRead(reads)
 		}
+		if len(actions) != 0 {
+			server.NotifyPreAction(actions)
+		}
 	}
 	if xml != nil {
 		xml.AppendEndTag(int(enums.CommandReadRequest), true)
@@ -529,13 +549,13 @@ func handleReadRequest(settings *settings.GXDLMSSettings, server *GXDLMSServer,
 	if len(reads) != 0 {
 		server.NotifyPostRead(reads)
 	}
+	if len(actions) != 0 {
+		server.NotifyPostAction(actions)
+	}
 	p := NewGXDLMSSNParameters(settings, enums.CommandReadResponse, len(list), byte(requestType), nil, &bb)
 	err = getSNPdu(p, replyData)
 	if server.transaction == nil && (bb.Available() != 0 || settings.Count != settings.Index) {
-		for _, it := range list {
-			reads = append(reads, it)
-		}
-		server.transaction = newGXDLMSLongTransaction(reads, enums.CommandReadRequest, &bb)
+		server.transaction = newGXDLMSLongTransaction(list, enums.CommandReadRequest, &bb)
 	} else if server.transaction != nil {
 		err = replyData.SetByteBuffer(&bb)
 	}
@@ -655,7 +675,7 @@ func handleWriteRequest(conf *settings.GXDLMSSettings,
 			if target.IsAction {
 				am := server.NotifyGetMethodAccess(e)
 				// If action is denied.
-				if (am & int(enums.MethodAccessModeAccess)) != 0 {
+				if (am & int(enums.MethodAccessModeAccess)) == 0 {
 					access = false
 				}
 			} else {
@@ -670,10 +690,12 @@ func handleWriteRequest(conf *settings.GXDLMSSettings,
 					}
 				}
 			}
-			am := server.NotifyGetAttributeAccess(e)
-			// If write is denied.
-			if (am & int(enums.AccessModeWrite)) == 0 {
-				access = false
+			if !target.IsAction {
+				am := server.NotifyGetAttributeAccess(e)
+				// If write is denied.
+				if (am & int(enums.AccessModeWrite)) == 0 {
+					access = false
+				}
 			}
 			if access {
 				if target.IsAction {
@@ -716,6 +738,12 @@ func handleWriteRequest(conf *settings.GXDLMSSettings,
 					} else if !e.Handled {
 						target.Item.SetValue(conf, e)
 						server.NotifyPostWrite([]*internal.ValueEventArgs{e})
+						if e.Error != 0 {
+							err = results.SetUint8At(pos, uint8(e.Error))
+							if err != nil {
+								return err
+							}
+						}
 					}
 				}
 			} else {
@@ -739,32 +767,36 @@ func handleWriteRequest(conf *settings.GXDLMSSettings,
 // Parameters:
 //
 //	settings: DLMS settings.
+//
+// Returns:
+//
+//	Updated objects and attribute indexes.
 func handleInformationReport(settings *settings.GXDLMSSettings,
-	reply *GXReplyData,
-	list []*types.GXKeyValuePair[objects.IGXDLMSBase, int]) error {
+	reply *GXReplyData) ([]*types.GXKeyValuePair[objects.IGXDLMSBase, int], error) {
+	var list []*types.GXKeyValuePair[objects.IGXDLMSBase, int]
 	reply.Time = time.Time{}
-	len, err := reply.Data.Uint8()
+	length, err := reply.Data.Uint8()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// If date time is given.
 	var tmp []byte
-	if len != 0 {
-		tmp = make([]byte, len)
+	if length != 0 {
+		tmp = make([]byte, length)
 		err = reply.Data.Get(tmp)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		ret, err := internal.ChangeTypeFromByteArray(settings, tmp, enums.DataTypeDateTime)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		reply.Time = ret.(types.GXDateTime).Value
 	}
 	var type_ uint8
 	count, err := types.GetObjectCount(reply.Data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if reply.xml != nil {
 		reply.xml.AppendStartTag(int(enums.CommandInformationReport), "", "", true)
@@ -782,23 +814,22 @@ func handleInformationReport(settings *settings.GXDLMSSettings,
 	for pos := 0; pos != count; pos++ {
 		type_, err = reply.Data.Uint8()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if type_ == uint8(constants.VariableAccessSpecificationVariableName) {
 			sn, err := reply.Data.Uint16()
 			if err != nil {
-				return err
+				return nil, err
 			}
 			if reply.xml != nil {
 				reply.xml.AppendLineFromTag(int(enums.CommandWriteRequest)<<8|int(constants.VariableAccessSpecificationVariableName), "Value",
 					reply.xml.IntegerToHex(sn, 4, false))
 			} else {
 				info := FindSNObject(settings.Objects.(objects.GXDLMSObjectCollection), int16(sn))
-				if info.Item != nil {
-					list = append(list, types.NewGXKeyValuePair[objects.IGXDLMSBase, int](info.Item, int(info.Index)))
-				} else {
+				if info.Item == nil {
 					log.Println(fmt.Sprintf("Unknown object : %v.", sn))
 				}
+				list = append(list, types.NewGXKeyValuePair[objects.IGXDLMSBase, int](info.Item, int(info.Index)))
 			}
 		}
 	}
@@ -808,7 +839,7 @@ func handleInformationReport(settings *settings.GXDLMSSettings,
 	}
 	count, err = types.GetObjectCount(reply.Data)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	di := internal.GXDataInfo{}
 	di.Xml = reply.xml
@@ -817,20 +848,27 @@ func handleInformationReport(settings *settings.GXDLMSSettings,
 		if reply.xml != nil {
 			_, err = internal.GetData(settings, reply.Data, &di)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		} else {
-			v := internal.NewValueEventArgs(settings, list[pos].Key, uint8(list[pos].Value))
-			v.Value, err = internal.GetData(settings, reply.Data, &di)
+			value, err := internal.GetData(settings, reply.Data, &di)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			// Values of the unknown objects are skipped.
+			if pos < len(list) && list[pos].Key != nil {
+				v := internal.NewValueEventArgs(settings, list[pos].Key, uint8(list[pos].Value))
+				v.Value = value
+				err = list[pos].Key.SetValue(settings, v)
+				if err != nil {
+					return nil, err
+				}
 			}
-			list[pos].Key.SetValue(settings, v)
 		}
 	}
 	if reply.xml != nil {
 		reply.xml.AppendEndTag(int(internal.TranslatorTagsListOfData), true)
 		reply.xml.AppendEndTag(int(enums.CommandInformationReport), true)
 	}
-	return nil
+	return list, nil
 }
@@ -68,6 +68,9 @@ type GXDLMSSNParameters struct {
 
 	// Block index.
 	BlockIndex uint16
+
+	// Plain PDU before ciphering. This is used for tracing.
+	pdu []byte
 }
 
 // NewGXDLMSSNParameters builds a parameter structure used when creating
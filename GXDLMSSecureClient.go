@@ -37,6 +37,7 @@
 import (
 	"errors"
 
+	"github.com/Gurux/gxdlms-go/dlmserrors"
 	"github.com/Gurux/gxdlms-go/enums"
 	"github.com/Gurux/gxdlms-go/internal"
 	"github.com/Gurux/gxdlms-go/settings"
@@ -141,6 +142,83 @@ func (g *GXDLMSSecureClient) Decrypt(kek []byte, input []byte) ([]byte, error) {
 	return internal.Decrypt(kek, input)
 }
 
+// LoadKeyStore configures the keys and certificates from the key store.
+// Keys that are not found from the key store are not changed.
+//
+// Parameters:
+//
+//	store: Key store.
+//	clientSystemTitle: Client system title.
+//	serverSystemTitle: Server system title. Symmetric keys and the server certificate are searched with it.
+func (g *GXDLMSSecureClient) LoadKeyStore(store types.GXIKeyStore, clientSystemTitle []byte, serverSystemTitle []byte) error {
+	cipher := g.GXDLMSClient.settings.Cipher
+	if err := cipher.SetSystemTitle(clientSystemTitle); err != nil {
+		return err
+	}
+	for _, it := range enums.AllGlobalKeyType() {
+		key, err := store.Key(serverSystemTitle, it)
+		if errors.Is(err, dlmserrors.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		switch it {
+		case enums.GlobalKeyTypeUnicastEncryption:
+			err = cipher.SetBlockCipherKey(key)
+		case enums.GlobalKeyTypeBroadcastEncryption:
+			err = cipher.SetBroadcastBlockCipherKey(key)
+		case enums.GlobalKeyTypeAuthentication:
+			err = cipher.SetAuthenticationKey(key)
+		case enums.GlobalKeyTypeKek:
+			g.GXDLMSClient.settings.Kek = key
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, it := range []enums.CertificateType{enums.CertificateTypeDigitalSignature, enums.CertificateTypeKeyAgreement, enums.CertificateTypeTLS} {
+		key, err := store.PrivateKey(clientSystemTitle, it)
+		if errors.Is(err, dlmserrors.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		pub := key.PublicKey()
+		if pub == nil {
+			if pub, err = types.PublicKeyFromECDSAPrivate(key.PrivateKey()); err != nil {
+				return err
+			}
+		}
+		kp := types.NewGXKeyValuePair(pub, key.PrivateKey())
+		switch it {
+		case enums.CertificateTypeDigitalSignature:
+			err = cipher.SetSigningKeyPair(kp)
+		case enums.CertificateTypeKeyAgreement:
+			err = cipher.SetKeyAgreementKeyPair(kp)
+		case enums.CertificateTypeTLS:
+			err = cipher.SetTLSKeyPair(kp)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	cert, err := store.Certificate(clientSystemTitle, enums.CertificateTypeDigitalSignature)
+	if err == nil {
+		g.GXDLMSClient.settings.ClientPublicKeyCertificate = cert
+	} else if !errors.Is(err, dlmserrors.ErrKeyNotFound) {
+		return err
+	}
+	cert, err = store.Certificate(serverSystemTitle, enums.CertificateTypeDigitalSignature)
+	if err == nil {
+		g.GXDLMSClient.settings.ServerPublicKeyCertificate = cert
+	} else if !errors.Is(err, dlmserrors.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
 func NewGXDLMSSecureClient(useLogicalNameReferencing bool, clientAddress int, serverAddress int, authentication enums.Authentication,
 	password []byte, interfaceType enums.InterfaceType) (*GXDLMSSecureClient, error) {
 	ret := &GXDLMSSecureClient{}
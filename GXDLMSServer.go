@@ -1,10 +1,5 @@
 ﻿package dlms
 
-import (
-	"github.com/Gurux/gxdlms-go/enums"
-	"github.com/Gurux/gxdlms-go/internal"
-)
-
 //
 // --------------------------------------------------------------------------
 //  Gurux Ltd
@@ -39,8 +34,97 @@ import (
 // Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
 //---------------------------------------------------------------------------
 
+import (
+	"bytes"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/internal/constants"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/secure"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+const (
+	// firstShortName is the short name of the first COSEM object.
+	firstShortName = 0xA0
+	// associationShortName is the short name of the Association SN object (0xFA00).
+	associationShortName = -1536
+)
+
+// GXDLMSServer implements the server side of the DLMS/COSEM protocol using
+// short name referencing. It can be used to emulate legacy SN meters.
 type GXDLMSServer struct {
+	settings    *settings.GXDLMSSettings
 	transaction *gxDLMSLongTransaction
+
+	// Served COSEM objects.
+	items objects.GXDLMSObjectCollection
+
+	// Received bytes that are not handled yet.
+	received *types.GXByteBuffer
+
+	// Received request.
+	info *GXReplyData
+
+	// Is server initialized.
+	initialized bool
+
+	// Storage where the object state is persisted.
+	storage IGXDLMSStorage
+
+	// Part of the reply that is not sent yet when the reply is split to several HDLC frames.
+	hdlcReply *types.GXByteBuffer
+
+	// Configured HDLC frame sizes. Negotiated frame sizes are never bigger than these.
+	maxInfoTX uint16
+	maxInfoRX uint16
+}
+
+// NewGXDLMSServer creates a new short name referencing server.
+// HDLC, WRAPPER and PDU interface types are supported.
+// HDLC window size is always one.
+//
+// Parameters:
+//
+//	interfaceType: Used interface type.
+//
+// Returns:
+//
+//	Created server.
+func NewGXDLMSServer(interfaceType enums.InterfaceType) *GXDLMSServer {
+	ret := &GXDLMSServer{
+		received: types.NewGXByteBuffer(),
+		info:     NewGXReplyData(),
+	}
+	ret.settings = settings.NewGXDLMSSettingsWithParams(true, false, interfaceType, ret.items)
+	ret.settings.Cipher = &secure.GXCiphering{}
+	return ret
+}
+
+// Settings returns the DLMS settings of the server.
+func (g *GXDLMSServer) Settings() *settings.GXDLMSSettings {
+	return g.settings
+}
+
+// Objects returns the COSEM objects that the server serves.
+// Objects are added before the server is initialized.
+func (g *GXDLMSServer) Objects() *objects.GXDLMSObjectCollection {
+	return &g.items
+}
+
+// Storage returns the storage where the object state is persisted.
+func (g *GXDLMSServer) Storage() IGXDLMSStorage {
+	return g.storage
+}
+
+// SetStorage sets the storage where the object state is persisted.
+// Storage is set before the server is initialized.
+func (g *GXDLMSServer) SetStorage(value IGXDLMSStorage) {
+	g.storage = value
 }
 
 func getServerTransaction(server internal.IGXDLMSServer) *gxDLMSLongTransaction {
@@ -50,41 +134,104 @@ func getServerTransaction(server internal.IGXDLMSServer) *gxDLMSLongTransaction
 	return nil
 }
 
+// NotifyGetMethodAccess returns the method access of the target object.
 func (g *GXDLMSServer) NotifyGetMethodAccess(args *internal.ValueEventArgs) int {
-	return 0
+	if target, ok := args.Target.(objects.IGXDLMSBase); ok {
+		return int(target.Base().GetMethodAccess(int(args.Index)))
+	}
+	return int(enums.MethodAccessModeNoAccess)
 }
 
+// NotifyGetAttributeAccess returns the attribute access of the target object.
 func (g *GXDLMSServer) NotifyGetAttributeAccess(args *internal.ValueEventArgs) int {
-	return 0
+	if target, ok := args.Target.(objects.IGXDLMSBase); ok {
+		// Logical name can always be read.
+		if args.Index == 1 {
+			return int(enums.AccessModeRead)
+		}
+		return int(target.Base().GetAccess(int(args.Index)))
+	}
+	return int(enums.AccessModeNoAccess)
 }
 
-func (g *GXDLMSServer) NotifyRead(args []*internal.ValueEventArgs) int {
-	return 0
+func (g *GXDLMSServer) NotifyRead(args []*internal.ValueEventArgs) {
 }
 
-func (g *GXDLMSServer) NotifyPostRead(args []*internal.ValueEventArgs) int {
-	return 0
+func (g *GXDLMSServer) NotifyPostRead(args []*internal.ValueEventArgs) {
 }
 
-func (g *GXDLMSServer) NotifyPostWrite(args []*internal.ValueEventArgs) int {
-	return 0
+// NotifyPostWrite saves the object state after the value is written.
+func (g *GXDLMSServer) NotifyPostWrite(args []*internal.ValueEventArgs) {
+	if g.storage == nil {
+		return
+	}
+	for _, e := range args {
+		if target, ok := e.Target.(objects.IGXDLMSBase); ok && e.Error == enums.ErrorCodeOk {
+			if err := g.storage.Save(&g.items, target); err != nil {
+				e.Error = enums.ErrorCodeHardwareFault
+			}
+		}
+	}
 }
-func (g *GXDLMSServer) NotifyWrite(args []*internal.ValueEventArgs) int {
-	return 0
+
+func (g *GXDLMSServer) NotifyWrite(args []*internal.ValueEventArgs) {
 }
+
+// NotifyFindObject is called when the object is not found from the served objects.
 func (g *GXDLMSServer) NotifyFindObject(objectType enums.ObjectType, sn int, ln string) interface{} {
 	return nil
 }
+
+// Items returns the served COSEM objects.
 func (g *GXDLMSServer) Items() interface{} {
-	return nil
+	return g.items
+}
+
+func (g *GXDLMSServer) NotifyPreAction(args []*internal.ValueEventArgs) {
 }
 
-func (g *GXDLMSServer) NotifyPreAction(args []*internal.ValueEventArgs) int {
-	return 0
+// NotifyPostAction saves the object state after the action is invoked.
+// Profile generic reset and capture update only the stored buffer.
+func (g *GXDLMSServer) NotifyPostAction(args []*internal.ValueEventArgs) {
+	if g.storage == nil {
+		return
+	}
+	for _, e := range args {
+		target, ok := e.Target.(objects.IGXDLMSBase)
+		if !ok || e.Error != enums.ErrorCodeOk {
+			continue
+		}
+		var err error
+		if pg, ok := target.(*objects.GXDLMSProfileGeneric); ok && e.Index == 1 {
+			err = g.storage.ResetBuffer(pg)
+		} else if ok && e.Index == 2 {
+			if len(pg.Buffer) != 0 {
+				err = g.storage.Capture(pg, pg.Buffer[len(pg.Buffer)-1])
+			}
+		} else {
+			err = g.storage.Save(&g.items, target)
+		}
+		if err != nil {
+			e.Error = enums.ErrorCodeHardwareFault
+		}
+	}
 }
 
-func (g *GXDLMSServer) NotifyPostAction(args []*internal.ValueEventArgs) int {
-	return 0
+// Capture captures a new row to the buffer of the profile generic and stores it.
+// Simulators call this when the capture period elapses.
+//
+// Parameters:
+//
+//	target: Profile generic.
+func (g *GXDLMSServer) Capture(target *objects.GXDLMSProfileGeneric) error {
+	row, err := target.CaptureRow()
+	if err != nil {
+		return err
+	}
+	if g.storage != nil {
+		return g.storage.Capture(target, row)
+	}
+	return nil
 }
 
 func (g *GXDLMSServer) NotifyConnected(connectionInfo *GXDLMSConnectionEventArgs) {
@@ -94,10 +241,442 @@ func (g *GXDLMSServer) NotifyInvalidConnection(connectionInfo *GXDLMSConnectionE
 }
 
 func (g *GXDLMSServer) Transaction() interface{} {
+	if g.transaction == nil {
+		return nil
+	}
 	return g.transaction
 }
+
 func (g *GXDLMSServer) SetTransaction(value interface{}) {
-	g.transaction = value.(*gxDLMSLongTransaction)
+	g.transaction, _ = value.(*gxDLMSLongTransaction)
+}
+
+// UpdateShortNames assigns short names to the objects following the Blue Book layout.
+// Attributes of the object are at eight byte offsets from the base name and
+// methods start from the class specific offset. Association SN object is always at 0xFA00.
+//
+// Parameters:
+//
+//	force: Are short names assigned also for the objects that already have a short name.
+func (g *GXDLMSServer) UpdateShortNames(force bool) {
+	var offset, count int
+	sn := firstShortName
+	for _, it := range g.items {
+		if it.Base().ObjectType() == enums.ObjectTypeAssociationShortName {
+			it.Base().ShortName = associationShortName
+			continue
+		}
+		if !force && it.Base().ShortName != 0 {
+			continue
+		}
+		it.Base().ShortName = int16(sn)
+		getActionInfo(it.Base().ObjectType(), &offset, &count)
+		if count != 0 {
+			sn += offset + (8 * count)
+		} else {
+			sn += 8 * it.GetAttributeCount()
+		}
+	}
+}
+
+// Initialize assigns the short names and updates the object list of the Association SN object.
+// Association SN object is added if it doesn't exist.
+// Stored object state is loaded if the storage is set.
+// Initialize must be called after the objects are added and before the requests are handled.
+func (g *GXDLMSServer) Initialize() error {
+	var association *objects.GXDLMSAssociationShortName
+	for _, it := range g.items {
+		if a, ok := it.(*objects.GXDLMSAssociationShortName); ok && association == nil {
+			association = a
+		}
+	}
+	if association == nil {
+		var err error
+		association, err = objects.NewGXDLMSAssociationShortName("0.0.40.0.0.255", associationShortName)
+		if err != nil {
+			return err
+		}
+		// Access rights are available from version 2.
+		association.Version = 2
+		g.items.Add(association)
+	}
+	g.UpdateShortNames(false)
+	if len(association.ObjectList) == 0 {
+		for _, it := range g.items {
+			association.ObjectList.Add(it)
+		}
+	}
+	if g.storage != nil {
+		if err := g.storage.Load(&g.items); err != nil {
+			return err
+		}
+	}
+	switch g.settings.InterfaceType {
+	case enums.InterfaceTypeHDLC, enums.InterfaceTypeHdlcWithModeE:
+		g.maxInfoTX = g.settings.Hdlc.MaxInfoTX()
+		g.maxInfoRX = g.settings.Hdlc.MaxInfoRX()
+	case enums.InterfaceTypeWRAPPER, enums.InterfaceTypePDU:
+	default:
+		return dlmserrors.ErrInvalidInterfaceType
+	}
+	g.settings.Objects = g.items
+	g.initialized = true
+	g.Reset()
+	return nil
+}
+
+// association returns the Association SN object of the server.
+func (g *GXDLMSServer) association() *objects.GXDLMSAssociationShortName {
+	for _, it := range g.items {
+		if a, ok := it.(*objects.GXDLMSAssociationShortName); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+// Reset resets the connection state. It is called when the client disconnects.
+func (g *GXDLMSServer) Reset() {
+	g.transaction = nil
+	g.hdlcReply = nil
+	g.received.Clear()
+	g.info.Clear()
+	g.settings.Connected = enums.ConnectionStateNone
+	g.settings.Count = 0
+	g.settings.Index = 0
+	g.settings.ResetBlockIndex()
+	g.settings.ResetFrameSequence()
+	g.settings.ClientAddress = 0
+	g.settings.Authentication = enums.AuthenticationNone
+	g.settings.Password = nil
+	g.clearChallenges()
+}
+
+// clearChallenges removes the challenges of the previous association
+// so they can't be used to complete the authentication again.
+func (g *GXDLMSServer) clearChallenges() {
+	g.settings.SetStoCChallenge(nil)
+	g.settings.SetCtoSChallenge(nil)
+}
+
+// HandleRequest handles the received bytes and returns the reply that is sent to the client.
+// Nil is returned if the whole frame is not received yet or if the request is not replied.
+//
+// Parameters:
+//
+//	data: Received bytes.
+//
+// Returns:
+//
+//	Reply to the client.
+func (g *GXDLMSServer) HandleRequest(data []byte) ([]byte, error) {
+	if !g.initialized {
+		return nil, dlmserrors.ErrServerNotInitialized
+	}
+	if err := g.received.Set(data); err != nil {
+		return nil, err
+	}
+	start := g.received.Position()
+	_, err := getData(g.settings, g.received, g.info, nil)
+	if g.settings.Trace != nil && g.received.Position() > start {
+		frame, _ := g.received.SubArray(start, g.received.Position()-start)
+		traceReceived(g.settings, frame, g.info, nil)
+	}
+	if err != nil {
+		g.received.Clear()
+		g.info.Clear()
+		return nil, err
+	}
+	if !g.info.IsComplete() {
+		return nil, nil
+	}
+	g.received.Clear()
+	if useHdlc(g.settings.InterfaceType) {
+		if ret, handled, err := g.handleHdlc(); handled {
+			return ret, err
+		}
+	}
+	defer g.info.Clear()
+	// Wait until all frames of the PDU are received.
+	if g.info.IsMoreData() {
+		return nil, nil
+	}
+	reply := types.NewGXByteBuffer()
+	cmd := g.info.Command()
+	switch cmd {
+	case enums.CommandAarq:
+		err = g.handleAarqRequest(g.info.Data, reply)
+		cmd = enums.CommandAare
+	case enums.CommandReadRequest:
+		err = handleReadRequest(g.settings, g, g.info.Data, reply, nil, enums.CommandNone)
+		cmd = enums.CommandReadResponse
+	case enums.CommandWriteRequest:
+		err = handleWriteRequest(g.settings, g, g.info.Data, reply, nil, enums.CommandNone)
+		cmd = enums.CommandWriteResponse
+	case enums.CommandUnconfirmedWriteRequest:
+		// Unconfirmed write is not replied.
+		err = handleWriteRequest(g.settings, g, g.info.Data, types.NewGXByteBuffer(), nil, enums.CommandNone)
+	case enums.CommandReleaseRequest:
+		err = g.handleReleaseRequest(reply)
+		cmd = enums.CommandReleaseResponse
+	default:
+		err = reply.Set(GenerateConfirmedServiceError(enums.ConfirmedServiceErrorRead,
+			enums.ServiceErrorService, uint8(enums.ServiceUnsupported)))
+		cmd = enums.CommandConfirmedServiceError
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Count and index are used with the long responses.
+	if g.transaction == nil {
+		g.settings.Count = 0
+		g.settings.Index = 0
+	}
+	if reply.Size() == 0 {
+		return nil, nil
+	}
+	return g.frame(cmd, reply)
+}
+
+// frame adds the interface specific frame to the reply.
+func (g *GXDLMSServer) frame(cmd enums.Command, reply *types.GXByteBuffer) ([]byte, error) {
+	// Read and write responses contain the LLC bytes already.
+	if useHdlc(g.settings.InterfaceType) && !bytes.HasPrefix(reply.Array(), internal.LLCReplyBytes) {
+		if err := addLLCBytes(g.settings, reply); err != nil {
+			return nil, err
+		}
+	}
+	pdu := tracedPdu(g.settings, reply, nil)
+	var ret []byte
+	var err error
+	switch g.settings.InterfaceType {
+	case enums.InterfaceTypeWRAPPER:
+		ret, err = getWrapperFrame(g.settings, cmd, reply)
+	case enums.InterfaceTypeHDLC, enums.InterfaceTypeHdlcWithModeE:
+		ret, err = getHdlcFrame(g.settings, g.settings.NextSend(true), reply, true)
+		if err == nil && reply.Available() != 0 {
+			g.hdlcReply = reply
+		}
+	case enums.InterfaceTypePDU:
+		ret = reply.Array()
+	default:
+		return nil, dlmserrors.ErrInvalidInterfaceType
+	}
+	if err != nil {
+		return nil, err
+	}
+	traceSent(g.settings, [][]byte{ret}, pdu)
+	return ret, nil
+}
+
+// handleHdlc handles the HDLC frames that don't contain a complete request.
+// Returns true if the frame is handled and the request is not processed.
+func (g *GXDLMSServer) handleHdlc() ([]byte, bool, error) {
+	switch g.info.Command() {
+	case enums.CommandSnrm:
+		ret, err := g.handleSnrmRequest()
+		return ret, true, err
+	case enums.CommandDisconnectRequest:
+		defer g.info.Clear()
+		if (g.settings.Connected & enums.ConnectionStateHdlc) == 0 {
+			ret, err := g.hdlcFrame(uint8(enums.CommandDisconnectMode), nil)
+			return ret, true, err
+		}
+		ret, err := g.hdlcFrame(uint8(enums.CommandUa), nil)
+		client := g.settings.ClientAddress
+		g.Reset()
+		// Client address is needed if the client connects again without SNRM.
+		g.settings.ClientAddress = client
+		return ret, true, err
+	}
+	if (g.settings.Connected & enums.ConnectionStateHdlc) == 0 {
+		// Client must send SNRM before the I-frames.
+		g.info.Clear()
+		ret, err := g.hdlcFrame(uint8(enums.CommandDisconnectMode), nil)
+		return ret, true, err
+	}
+	// Client sends the request in several frames.
+	if (g.info.GetMoreData() & enums.RequestTypesFrame) != 0 {
+		ret, err := g.hdlcFrame(g.settings.ReceiverReady(), nil)
+		return ret, true, err
+	}
+	// Client asks the next frame of the reply.
+	if g.info.Command() == enums.CommandNone && g.info.Data.Size() == 0 {
+		g.info.Clear()
+		if g.hdlcReply == nil {
+			ret, err := g.hdlcFrame(g.settings.ReceiverReady(), nil)
+			return ret, true, err
+		}
+		reply := g.hdlcReply
+		ret, err := g.hdlcFrame(g.settings.NextSend(false), reply)
+		if reply.Available() == 0 {
+			g.hdlcReply = nil
+		}
+		return ret, true, err
+	}
+	g.hdlcReply = nil
+	return nil, false, nil
+}
+
+// hdlcFrame generates the HDLC frame.
+func (g *GXDLMSServer) hdlcFrame(frame uint8, data *types.GXByteBuffer) ([]byte, error) {
+	ret, err := getHdlcFrame(g.settings, frame, data, true)
+	if err != nil {
+		return nil, err
+	}
+	traceSent(g.settings, [][]byte{ret}, nil)
+	return ret, nil
+}
+
+// handleSnrmRequest negotiates the HDLC parameters and generates the UA response.
+// Server uses the smaller of the proposed and the configured frame size.
+func (g *GXDLMSServer) handleSnrmRequest() ([]byte, error) {
+	hdlc := g.settings.Hdlc
+	err := parseSnrmUaResponse(g.info.Data, g.settings)
+	client := g.settings.ClientAddress
+	g.Reset()
+	if err != nil {
+		return nil, err
+	}
+	g.settings.ClientAddress = client
+	hdlc.SetMaxInfoTX(min(hdlc.MaxInfoTX(), g.maxInfoTX))
+	hdlc.SetMaxInfoRX(min(hdlc.MaxInfoRX(), g.maxInfoRX))
+	hdlc.SetWindowSizeTX(1)
+	hdlc.SetWindowSizeRX(1)
+	data := types.NewGXByteBufferWithCapacity(25)
+	if err = data.Set([]byte{0x81, 0x80, 0}); err != nil {
+		return nil, err
+	}
+	for _, it := range []types.GXKeyValuePair[internal.HDLCInfo, uint16]{
+		*types.NewGXKeyValuePair(internal.HDLCInfoMaxInfoTX, hdlc.MaxInfoTX()),
+		*types.NewGXKeyValuePair(internal.HDLCInfoMaxInfoRX, hdlc.MaxInfoRX()),
+	} {
+		if err = data.SetUint8(uint8(it.Key)); err != nil {
+			return nil, err
+		}
+		if err = appendHdlcParameter(data, it.Value); err != nil {
+			return nil, err
+		}
+	}
+	for _, it := range []internal.HDLCInfo{internal.HDLCInfoWindowSizeTX, internal.HDLCInfoWindowSizeRX} {
+		if err = data.Set([]byte{uint8(it), 4}); err != nil {
+			return nil, err
+		}
+		if err = data.SetUint32(1); err != nil {
+			return nil, err
+		}
+	}
+	if err = data.SetUint8At(2, uint8(data.Size()-3)); err != nil {
+		return nil, err
+	}
+	g.settings.Connected = enums.ConnectionStateHdlc
+	return g.hdlcFrame(uint8(enums.CommandUa), data)
+}
+
+// handleAarqRequest parses the AARQ request and generates the AARE response.
+func (g *GXDLMSServer) handleAarqRequest(data *types.GXByteBuffer, reply *types.GXByteBuffer) error {
+	g.transaction = nil
+	g.settings.Connected &= ^enums.ConnectionStateDlms
+	g.settings.ResetBlockIndex()
+	g.clearChallenges()
+	ret, err := parsePDU(g.settings, g.settings.Cipher, data, nil)
+	if err != nil {
+		return err
+	}
+	result := enums.AssociationResultAccepted
+	var diagnostic any = enums.SourceDiagnosticNone
+	switch v := ret.(type) {
+	case enums.ApplicationContextName:
+		result = enums.AssociationResultPermanentRejected
+		diagnostic = enums.SourceDiagnosticApplicationContextNameNotSupported
+	case enums.AcseServiceProvider:
+		if v != enums.AcseServiceProviderNone {
+			result = enums.AssociationResultPermanentRejected
+			diagnostic = v
+		}
+	case enums.ExceptionServiceError:
+		result = enums.AssociationResultPermanentRejected
+		diagnostic = enums.SourceDiagnosticNoReasonGiven
+	}
+	if result == enums.AssociationResultAccepted {
+		association := g.association()
+		switch {
+		case association != nil && association.Authentication != g.settings.Authentication:
+			// Client must use the authentication mechanism that the association requires.
+			result = enums.AssociationResultPermanentRejected
+			diagnostic = enums.SourceDiagnosticAuthenticationMechanismNameNotRecognized
+		case g.settings.Authentication == enums.AuthenticationNone:
+			g.settings.Connected |= enums.ConnectionStateDlms
+		case g.settings.Authentication == enums.AuthenticationLow:
+			if association == nil || !bytes.Equal(association.Secret, g.settings.Password) {
+				result = enums.AssociationResultPermanentRejected
+				diagnostic = enums.SourceDiagnosticAuthenticationFailure
+			} else {
+				g.settings.Connected |= enums.ConnectionStateDlms
+			}
+		default:
+			// High level authentication is completed with the reply_to_HLS_authentication method.
+			g.settings.SetStoCChallenge(settings.GenerateChallenge(g.settings.Authentication, g.settings.ChallengeSize()))
+			diagnostic = enums.SourceDiagnosticAuthenticationRequired
+		}
+	}
+	return generateAARE(g.settings, reply, result, diagnostic, g.settings.Cipher, nil, nil)
+}
+
+// handleReleaseRequest closes the association and generates the release response.
+func (g *GXDLMSServer) handleReleaseRequest(reply *types.GXByteBuffer) error {
+	g.transaction = nil
+	g.settings.Connected &= ^enums.ConnectionStateDlms
+	g.clearChallenges()
+	// Release response with reason normal.
+	return reply.Set([]byte{uint8(enums.CommandReleaseResponse), 3,
+		uint8(constants.BerTypeContext), 1, 0})
+}
+
+// GenerateInformationReport generates the information report messages of the given attributes.
+//
+// Parameters:
+//
+//	time: Send time. Time is not sent if it is nil.
+//	list: Objects and attribute indexes to send.
+//
+// Returns:
+//
+//	Information report messages.
+func (g *GXDLMSServer) GenerateInformationReport(time *time.Time,
+	list []*types.GXKeyValuePair[objects.IGXDLMSBase, int]) ([][]byte, error) {
+	if !g.initialized {
+		return nil, dlmserrors.ErrServerNotInitialized
+	}
+	descriptor := types.NewGXByteBuffer()
+	data := types.NewGXByteBuffer()
+	if err := types.SetObjectCount(len(list), data); err != nil {
+		return nil, err
+	}
+	for _, it := range list {
+		err := descriptor.SetUint8(uint8(constants.VariableAccessSpecificationVariableName))
+		if err != nil {
+			return nil, err
+		}
+		err = descriptor.SetUint16(uint16(it.Key.Base().ShortName) + uint16(8*(it.Value-1)))
+		if err != nil {
+			return nil, err
+		}
+		e := internal.NewValueEventArgs2(g, it.Key, uint8(it.Value))
+		value, err := it.Key.GetValue(g.settings, e)
+		if err != nil {
+			return nil, err
+		}
+		err = appendData(g.settings, it.Key, uint8(it.Value), data, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p := NewGXDLMSSNParameters(g.settings, enums.CommandInformationReport, len(list), 0xFF, descriptor, data)
+	if time != nil {
+		p.Time = types.NewGXDateTimeFromTime(*time)
+	}
+	return getSnMessages(p)
 }
 
 // GenerateConfirmedServiceError returns the generate confirmed service error.
@@ -0,0 +1,108 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXDLMSSlogTrace writes the traced frames to the structured logger of the standard library.
+type GXDLMSSlogTrace struct {
+	// Logger where the frames are written.
+	Logger *slog.Logger
+
+	// Level of the log records.
+	Level slog.Level
+
+	// Is the PDU written also as XML.
+	Xml bool
+
+	translator *GXDLMSTranslator
+}
+
+// NewGXDLMSSlogTrace creates a new slog trace.
+//
+// Parameters:
+//
+//	logger: Logger where the frames are written. Default logger is used if nil.
+//
+// Returns:
+//
+//	Created trace.
+func NewGXDLMSSlogTrace(logger *slog.Logger) *GXDLMSSlogTrace {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &GXDLMSSlogTrace{Logger: logger, Level: slog.LevelDebug}
+}
+
+// Trace writes the frame to the logger.
+//
+// Parameters:
+//
+//	e: Trace arguments.
+func (g *GXDLMSSlogTrace) Trace(e *settings.GXDLMSTraceEventArgs) {
+	ctx := context.Background()
+	if !g.Logger.Enabled(ctx, g.Level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("direction", e.Direction.String()),
+		slog.Bool("server", e.Server),
+		slog.String("interface", e.InterfaceType.String()),
+		slog.String("frame", types.ToHex(e.Frame, true)),
+	}
+	if e.Pdu != nil {
+		attrs = append(attrs,
+			slog.String("command", e.Command.String()),
+			slog.Uint64("invokeId", uint64(e.InvokeID)),
+			slog.String("pdu", types.ToHex(e.Pdu, true)))
+		if g.Xml {
+			if g.translator == nil {
+				g.translator = NewGXDLMSTranslator(enums.TranslatorOutputTypeSimpleXML)
+			}
+			// Ciphered PDUs and PDUs that the translator doesn't support are written only as hex.
+			if xml, err := g.translator.PduToXml(e.Pdu); err == nil && xml != "" {
+				attrs = append(attrs, slog.String("xml", xml))
+			}
+		}
+	}
+	g.Logger.LogAttrs(ctx, g.Level, "DLMS frame", attrs...)
+}
@@ -0,0 +1,74 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"github.com/Gurux/gxdlms-go/objects"
+)
+
+// IGXDLMSStorage persists the state of the objects that the server serves.
+// The server loads the state when it is initialized, saves it after SET and ACTION
+// and appends captured profile generic rows so a restarted server resumes from the same state.
+type IGXDLMSStorage interface {
+	// Load restores the stored state to the objects.
+	//
+	// Parameters:
+	//
+	//	items: Served objects.
+	Load(items *objects.GXDLMSObjectCollection) error
+
+	// Save stores the state of the objects after the target is written or invoked.
+	//
+	// Parameters:
+	//
+	//	items: Served objects.
+	//	target: Changed object.
+	Save(items *objects.GXDLMSObjectCollection, target objects.IGXDLMSBase) error
+
+	// Capture stores a new row of the profile generic buffer.
+	//
+	// Parameters:
+	//
+	//	target: Profile generic.
+	//	row: Captured row.
+	Capture(target *objects.GXDLMSProfileGeneric, row []any) error
+
+	// ResetBuffer clears the stored profile generic buffer.
+	//
+	// Parameters:
+	//
+	//	target: Profile generic.
+	ResetBuffer(target *objects.GXDLMSProfileGeneric) error
+}
@@ -0,0 +1,252 @@
+﻿package dlms
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/internal/constants"
+	"github.com/Gurux/gxdlms-go/objects"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// tracedSecretAttributes are the attributes where the written value is a secret.
+var tracedSecretAttributes = map[enums.ObjectType][]int{
+	// Secret.
+	enums.ObjectTypeAssociationLogicalName: {7},
+}
+
+// tracedSecretMethods are the methods where the parameter is a secret.
+var tracedSecretMethods = map[enums.ObjectType][]int{
+	// Change HLS secret.
+	enums.ObjectTypeAssociationLogicalName: {2},
+	// Change LLS secret and change HLS secret.
+	enums.ObjectTypeAssociationShortName: {5, 6},
+	// Key transfer and key agreement.
+	enums.ObjectTypeSecuritySetup: {2, 3},
+	// Set encryption key and transfer key.
+	enums.ObjectTypeMBusClient: {7, 8},
+}
+
+// tracedPdu returns the PDU that is traced.
+// Plain PDU is returned if the PDU is ciphered.
+func tracedPdu(conf *settings.GXDLMSSettings, reply *types.GXByteBuffer, plain []byte) []byte {
+	if conf.Trace == nil {
+		return nil
+	}
+	if plain != nil {
+		return plain
+	}
+	pdu := reply.Array()
+	if useHdlc(conf.InterfaceType) && len(pdu) >= len(internal.LLCSendBytes) {
+		pdu = pdu[len(internal.LLCSendBytes):]
+	}
+	return bytes.Clone(pdu)
+}
+
+// traceSent notifies the trace of the sent frames of the PDU.
+//
+// Parameters:
+//
+//	conf: DLMS settings.
+//	frames: Sent frames.
+//	pdu: Plain PDU.
+func traceSent(conf *settings.GXDLMSSettings, frames [][]byte, pdu []byte) {
+	if conf.Trace == nil {
+		return
+	}
+	for pos, it := range frames {
+		e := newTraceEventArgs(conf, enums.TraceDirectionSent, it, pdu)
+		if pos != 0 {
+			e.Pdu = nil
+		}
+		conf.Trace.Trace(e)
+	}
+}
+
+// traceReceived notifies the trace of the received frame.
+// PDU is added when the whole PDU is received.
+//
+// Parameters:
+//
+//	conf: DLMS settings.
+//	frame: Received frame.
+//	data: Received data.
+//	notify: Received notification.
+func traceReceived(conf *settings.GXDLMSSettings, frame []byte, data *GXReplyData, notify *GXReplyData) {
+	if conf.Trace == nil || len(frame) == 0 {
+		return
+	}
+	target := data
+	if notify != nil && notify.pdu != nil {
+		target = notify
+	}
+	var pdu []byte
+	if target.pdu != nil && (target.moreData&enums.RequestTypesFrame) == 0 {
+		pdu = target.pdu
+		target.pdu = nil
+	}
+	conf.Trace.Trace(newTraceEventArgs(conf, enums.TraceDirectionReceived, frame, pdu))
+}
+
+// newTraceEventArgs creates the trace arguments and masks the known secrets.
+func newTraceEventArgs(conf *settings.GXDLMSSettings, direction enums.TraceDirection, frame []byte, pdu []byte) *settings.GXDLMSTraceEventArgs {
+	e := &settings.GXDLMSTraceEventArgs{
+		Direction:     direction,
+		Server:        conf.IsServer(),
+		InterfaceType: conf.InterfaceType,
+	}
+	values := requestSecrets(conf, pdu)
+	e.Frame = maskSecrets(conf, frame, values)
+	e.Pdu = maskSecrets(conf, pdu, values)
+	if len(pdu) != 0 {
+		e.Command = enums.Command(pdu[0])
+		e.InvokeID = pduInvokeID(pdu)
+	}
+	return e
+}
+
+// pduInvokeID returns the invoke ID of the PDU.
+func pduInvokeID(pdu []byte) uint32 {
+	switch enums.Command(pdu[0]) {
+	case enums.CommandGetRequest, enums.CommandSetRequest, enums.CommandMethodRequest,
+		enums.CommandGetResponse, enums.CommandSetResponse, enums.CommandMethodResponse:
+		if len(pdu) > 2 {
+			return uint32(pdu[2] & 0xF)
+		}
+	case enums.CommandDataNotification, enums.CommandAccessRequest, enums.CommandAccessResponse:
+		if len(pdu) > 4 {
+			return binary.BigEndian.Uint32(pdu[1:5]) & 0xFFFFFF
+		}
+	}
+	return 0
+}
+
+// maskSecrets returns a copy of the data where the password, the keys,
+// the calling authentication value of the AARQ and the given values are replaced with '*'.
+func maskSecrets(conf *settings.GXDLMSSettings, data []byte, values [][]byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+	ret := bytes.Clone(data)
+	// Calling authentication value: 0xAC, length, 0x80, length, value.
+	for pos := 0; pos+4 <= len(ret); pos++ {
+		if ret[pos] == 0xAC && ret[pos+2] == 0x80 && int(ret[pos+1]) == int(ret[pos+3])+2 &&
+			pos+4+int(ret[pos+3]) <= len(ret) {
+			mask(ret[pos+4 : pos+4+int(ret[pos+3])])
+		}
+	}
+	secrets := [][]byte{conf.Password, conf.Kek}
+	if conf.Cipher != nil {
+		secrets = append(secrets, conf.Cipher.BlockCipherKey(), conf.Cipher.BroadcastBlockCipherKey(),
+			conf.Cipher.AuthenticationKey(), conf.Cipher.DedicatedKey())
+	}
+	secrets = append(secrets, values...)
+	for _, it := range secrets {
+		if len(it) == 0 {
+			continue
+		}
+		for pos := 0; pos < len(ret); {
+			index := bytes.Index(ret[pos:], it)
+			if index == -1 {
+				break
+			}
+			mask(ret[pos+index : pos+index+len(it)])
+			pos += index + len(it)
+		}
+	}
+	return ret
+}
+
+// requestSecrets returns the values of the SET and ACTION requests that are
+// written to the secret attributes or given to the methods that handle secrets.
+// Only the first block of the request is known, because the later blocks don't tell the target.
+func requestSecrets(conf *settings.GXDLMSSettings, pdu []byte) [][]byte {
+	switch {
+	case len(pdu) > 13 && enums.Command(pdu[0]) == enums.CommandSetRequest &&
+		(pdu[1] == byte(constants.SetRequestTypeNormal) || pdu[1] == byte(constants.SetRequestTypeFirstDataBlock)):
+		// Class ID, logical name and attribute index are followed by the access selection.
+		if isTracedSecret(enums.ObjectType(binary.BigEndian.Uint16(pdu[3:])), int(pdu[11]), false) {
+			return [][]byte{pdu[13:]}
+		}
+	case len(pdu) > 13 && enums.Command(pdu[0]) == enums.CommandMethodRequest &&
+		(pdu[1] == byte(constants.ActionRequestTypeNormal) || pdu[1] == byte(constants.ActionRequestTypeWithFirstBlock)):
+		// Class ID, logical name and method index are followed by the parameter flag.
+		if isTracedSecret(enums.ObjectType(binary.BigEndian.Uint16(pdu[3:])), int(pdu[11]), true) {
+			return [][]byte{pdu[13:]}
+		}
+	case len(pdu) > 6 && pdu[1] == 1 &&
+		((enums.Command(pdu[0]) == enums.CommandWriteRequest && pdu[2] == byte(constants.VariableAccessSpecificationVariableName)) ||
+			(enums.Command(pdu[0]) == enums.CommandReadRequest && pdu[2] == byte(constants.VariableAccessSpecificationParameterisedAccess))):
+		// Short name is followed by the count of the written values or the selector of the action parameters.
+		var items objects.GXDLMSObjectCollection
+		switch v := conf.Objects.(type) {
+		case objects.GXDLMSObjectCollection:
+			items = v
+		case *objects.GXDLMSObjectCollection:
+			items = *v
+		}
+		info := FindSNObject(items, int16(binary.BigEndian.Uint16(pdu[3:])))
+		if info.Item != nil && isTracedSecret(info.Item.Base().ObjectType(), int(info.Index), info.IsAction) {
+			return [][]byte{pdu[6:]}
+		}
+	}
+	return nil
+}
+
+// isTracedSecret returns true if the attribute or the method handles secrets.
+func isTracedSecret(objectType enums.ObjectType, index int, method bool) bool {
+	list := tracedSecretAttributes[objectType]
+	if method {
+		list = tracedSecretMethods[objectType]
+	}
+	for _, it := range list {
+		if it == index {
+			return true
+		}
+	}
+	return false
+}
+
+// mask replaces the bytes with '*'.
+func mask(value []byte) {
+	for pos := range value {
+		value[pos] = '*'
+	}
+}
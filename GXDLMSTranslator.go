@@ -40,6 +40,7 @@ import (
 	"strings"
 
 	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
 	"github.com/Gurux/gxdlms-go/settings"
 	"github.com/Gurux/gxdlms-go/types"
 )
@@ -128,6 +129,17 @@ type GXDLMSTranslator struct {
 	authenticationKey []byte
 }
 
+// NewGXDLMSTranslator creates a new translator.
+//
+// Parameters:
+//
+//	outputType: Translator output type.
+func NewGXDLMSTranslator(outputType enums.TranslatorOutputType) *GXDLMSTranslator {
+	ret := &GXDLMSTranslator{outputType: outputType, Comments: true}
+	ret.updateTags()
+	return ret
+}
+
 func ErrorCodeToString(type_ enums.TranslatorOutputType, value enums.ErrorCode) (string, error) {
 	if type_ == enums.TranslatorOutputTypeStandardXML {
 		return standardErrorCodeToString(value)
@@ -351,3 +363,71 @@ func (g *GXDLMSTranslator) UpdateAddress(settings *settings.GXDLMSSettings, msg
 		msg.TargetAddress = settings.ServerAddress
 	}
 }
+
+// updateTags updates the tags for the used output type.
+func (g *GXDLMSTranslator) updateTags() {
+	g.tags = make(map[int]string)
+	g.tagsByName = make(map[string]int)
+	g.GetTags(g.outputType, g.tags, g.tagsByName)
+}
+
+// DataToXml converts DLMS data to XML.
+//
+// Parameters:
+//
+//	data: DLMS data including the data type.
+//
+// Returns:
+//
+//	Data as XML.
+func (g *GXDLMSTranslator) DataToXml(data []byte) (string, error) {
+	if g.tags == nil {
+		g.updateTags()
+	}
+	xml := settings.NewGXDLMSTranslatorStructure(g.outputType, g.OmitXmlNameSpace, g.Hex, g.ShowStringAsHex, g.Comments, g.tags)
+	info := &internal.GXDataInfo{Xml: xml}
+	s := settings.NewGXDLMSSettings(nil)
+	_, err := internal.GetData(s, types.NewGXByteBufferWithData(data), info)
+	if err != nil {
+		return "", err
+	}
+	if !info.Complete {
+		return "", errors.New("not enough data to parse")
+	}
+	return xml.String(), nil
+}
+
+// PduToXml converts the PDU to XML.
+// Short name commands are parsed using short name referencing.
+//
+// Parameters:
+//
+//	pdu: Plain PDU.
+//
+// Returns:
+//
+//	PDU as XML.
+func (g *GXDLMSTranslator) PduToXml(pdu []byte) (string, error) {
+	if len(pdu) == 0 {
+		return "", errors.New("not enough data to parse")
+	}
+	if g.tags == nil {
+		g.updateTags()
+	}
+	ln := true
+	switch enums.Command(pdu[0]) {
+	case enums.CommandReadRequest, enums.CommandReadResponse, enums.CommandWriteRequest,
+		enums.CommandWriteResponse, enums.CommandInformationReport, enums.CommandUnconfirmedWriteRequest:
+		ln = false
+	}
+	data := NewGXReplyData()
+	data.xml = settings.NewGXDLMSTranslatorStructure(g.outputType, g.OmitXmlNameSpace, g.Hex, g.ShowStringAsHex, g.Comments, g.tags)
+	if err := data.Data.Set(pdu); err != nil {
+		return "", err
+	}
+	s := settings.NewGXDLMSSettingsWithParams(false, ln, enums.InterfaceTypePDU, nil)
+	if err := GetPdu(s, data); err != nil {
+		return "", err
+	}
+	return data.xml.String(), nil
+}
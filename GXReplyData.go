@@ -35,14 +35,26 @@ package dlms
 //---------------------------------------------------------------------------
 
 import (
-	"fmt"
 	"time"
 
+	"github.com/Gurux/gxdlms-go/dlmserrors"
 	"github.com/Gurux/gxdlms-go/enums"
 	"github.com/Gurux/gxdlms-go/settings"
 	"github.com/Gurux/gxdlms-go/types"
 )
 
+// requestTarget is the object and the attribute or the method that the request accesses.
+type requestTarget struct {
+	// Object type.
+	objectType enums.ObjectType
+	// Logical name.
+	logicalName string
+	// Attribute or method index.
+	index int
+	// Is index a method index.
+	method bool
+}
+
 // GXReplyData contains information from received reply data.
 type GXReplyData struct {
 	// Xml settings. This is used only on xml parser.
@@ -87,6 +99,9 @@ type GXReplyData struct {
 	// invokeId is the received invoke ID.
 	invokeId uint32
 
+	// pdu is the last received plain PDU. This is used for tracing.
+	pdu []byte
+
 	// systemTitle is the system title of the received PDU.
 	// System title is set when ciphered notify packet is received.
 	systemTitle []byte
@@ -94,6 +109,10 @@ type GXReplyData struct {
 	// Error is the received error code.
 	Error int
 
+	// ItemErrors are the errors of the list items that failed to read.
+	// Key is the index of the item in the read list. Failed items are not added to the Value.
+	ItemErrors map[int]enums.ErrorCode
+
 	// EmptyResponses is true if there are empty frames or blocks.
 	EmptyResponses enums.RequestTypes
 
@@ -106,6 +125,9 @@ type GXReplyData struct {
 	// Time is the data notification date time.
 	Time time.Time
 
+	// target is the object and the attribute or the method that the request accessed.
+	target requestTarget
+
 	// BlockNumber is the GBT block number.
 	BlockNumber uint16
 
@@ -118,6 +140,12 @@ type GXReplyData struct {
 	// gbtWindowSize is the GBT Window size.
 	gbtWindowSize byte
 
+	// gbtLost indicates if received GBT blocks are lost and peer must send them again.
+	gbtLost bool
+
+	// gbtRetransmit indicates if peer has not received all the sent GBT blocks.
+	gbtRetransmit bool
+
 	// hdlcStreaming indicates if HDLC streaming is in progress.
 	hdlcStreaming bool
 
@@ -265,6 +293,12 @@ func (r *GXReplyData) SetGbtWindowSize(value byte) {
 	r.gbtWindowSize = value
 }
 
+// IsRetransmitRequested returns true if peer has not received all the sent GBT blocks.
+// Lost blocks are sent again with GXDLMSClient.GbtRetransmit.
+func (r *GXReplyData) IsRetransmitRequested() bool {
+	return r.gbtRetransmit
+}
+
 // HdlcStreaming returns true if HDLC streaming is in progress.
 func (r *GXReplyData) HdlcStreaming() bool {
 	return r.hdlcStreaming
@@ -291,6 +325,7 @@ func (r *GXReplyData) Clear() {
 	r.Data.SetCapacity(0)
 	r.isComplete = false
 	r.Error = 0
+	r.ItemErrors = nil
 	r.TotalCount = 0
 	r.Value = nil
 	r.ReadPosition = 0
@@ -299,10 +334,16 @@ func (r *GXReplyData) Clear() {
 	r.CipherIndex = 0
 	r.Time = time.Time{}
 	r.gbtWindowSize = 0
+	r.BlockNumber = 0
+	r.BlockNumberAck = 0
+	r.gbtLost = false
+	r.gbtRetransmit = false
 	if r.xml != nil {
 		r.xml.SetXmlLength(0)
 	}
 	r.invokeId = 0
+	r.pdu = nil
+	r.target = requestTarget{}
 }
 
 // IsMoreData returns true if more data is available.
@@ -331,7 +372,17 @@ func (r *GXReplyData) setMoreData(value enums.RequestTypes) {
 
 // GetErrorMessage returns the error message description.
 func (r *GXReplyData) GetErrorMessage() string {
-	return getErrorDescription(enums.ErrorCode(r.Error))
+	return dlmserrors.ErrorCodeDescription(enums.ErrorCode(r.Error))
+}
+
+// Err returns the data access error reported by the meter or nil if the reply succeeded.
+// Error tells the object and the attribute or the method of the request.
+func (r *GXReplyData) Err() error {
+	if r.Error == 0 {
+		return nil
+	}
+	return dlmserrors.NewGXDLMSAccessError(enums.ErrorCode(r.Error), r.target.objectType, r.target.logicalName,
+		r.target.index, r.target.method)
 }
 
 // Count returns the count of read elements.
@@ -353,48 +404,3 @@ func (r *GXReplyData) String() string {
 	}
 	return types.ToHexWithRange(r.Data.Array(), true, 0, r.Data.Size())
 }
-
-func getErrorDescription(errorCode enums.ErrorCode) string {
-	switch errorCode {
-	case enums.ErrorCodeOk:
-		return ""
-	case enums.ErrorCodeRejected:
-		return "Rejected"
-	case enums.ErrorCodeUnacceptableFrame:
-		return "Unacceptable Frame"
-	case enums.ErrorCodeDisconnectMode:
-		return "Disconnect Mode"
-	case enums.ErrorCodeHardwareFault:
-		return "Hardware Fault"
-	case enums.ErrorCodeTemporaryFailure:
-		return "Temporary Failure"
-	case enums.ErrorCodeReadWriteDenied:
-		return "Read Write Denied"
-	case enums.ErrorCodeUndefinedObject:
-		return "Undefined Object"
-	case enums.ErrorCodeInconsistentClass:
-		return "Inconsistent Class"
-	case enums.ErrorCodeUnavailableObject:
-		return "Unavailable Object"
-	case enums.ErrorCodeUnmatchedType:
-		return "Unmatched Type"
-	case enums.ErrorCodeAccessViolated:
-		return "Access Violated"
-	case enums.ErrorCodeDataBlockUnavailable:
-		return "Data Block Unavailable"
-	case enums.ErrorCodeLongGetOrReadAborted:
-		return "Long Get Or Read Aborted"
-	case enums.ErrorCodeNoLongGetOrReadInProgress:
-		return "No Long Get Or Read In Progress"
-	case enums.ErrorCodeLongSetOrWriteAborted:
-		return "Long Set Or Write Aborted"
-	case enums.ErrorCodeNoLongSetOrWriteInProgress:
-		return "No Long Set Or Write In Progress"
-	case enums.ErrorCodeDataBlockNumberInvalid:
-		return "Data Block Number Invalid"
-	case enums.ErrorCodeOtherReason:
-		return "Other Reason"
-	default:
-		return fmt.Sprintf("Unknown Error (%d)", errorCode)
-	}
-}
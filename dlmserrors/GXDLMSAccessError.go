@@ -0,0 +1,165 @@
+package dlmserrors
+
+// --------------------------------------------------------------------------
+//
+//	Gurux Ltd
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//
+//	$Date$
+//	$Author$
+//
+// # Copyright (c) Gurux Ltd
+//
+// ---------------------------------------------------------------------------
+//
+//	DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+// ---------------------------------------------------------------------------
+
+import (
+	"fmt"
+
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// GXDLMSAccessError implements the data access error that the meter returns
+// when an attribute can't be read or written or a method can't be invoked.
+type GXDLMSAccessError struct {
+	// Data access result returned by the meter.
+	ErrorCode enums.ErrorCode
+	// Object type of the failed object.
+	ObjectType enums.ObjectType
+	// Logical name of the failed object.
+	LogicalName string
+	// Attribute or method index.
+	Index int
+	// Is index a method index.
+	Method bool
+}
+
+// NewGXDLMSAccessError creates a new instance of GXDLMSAccessError.
+//
+// Parameters:
+//
+//	errorCode: Data access result returned by the meter.
+//	objectType: Object type of the failed object.
+//	logicalName: Logical name of the failed object.
+//	index: Attribute or method index.
+//	method: Is index a method index.
+func NewGXDLMSAccessError(
+	errorCode enums.ErrorCode,
+	objectType enums.ObjectType,
+	logicalName string,
+	index int,
+	method bool,
+) *GXDLMSAccessError {
+	return &GXDLMSAccessError{
+		ErrorCode:   errorCode,
+		ObjectType:  objectType,
+		LogicalName: logicalName,
+		Index:       index,
+		Method:      method,
+	}
+}
+
+// Error implements the error interface.
+func (e *GXDLMSAccessError) Error() string {
+	if e.LogicalName == "" {
+		return ErrorCodeDescription(e.ErrorCode)
+	}
+	target := "attribute"
+	if e.Method {
+		target = "method"
+	}
+	return fmt.Sprintf("%s %s %s %d: %s", e.ObjectType, e.LogicalName, target, e.Index, ErrorCodeDescription(e.ErrorCode))
+}
+
+// Is reports whether the error belongs to the given error category.
+func (e *GXDLMSAccessError) Is(target error) bool {
+	switch target {
+	case ErrTemporaryFailure:
+		return e.ErrorCode == enums.ErrorCodeTemporaryFailure ||
+			e.ErrorCode == enums.ErrorCodeReceiveNotReady ||
+			e.ErrorCode == enums.ErrorCodeLongGetOrReadAborted ||
+			e.ErrorCode == enums.ErrorCodeLongSetOrWriteAborted
+	case ErrAccessDenied:
+		return e.ErrorCode == enums.ErrorCodeReadWriteDenied ||
+			e.ErrorCode == enums.ErrorCodeAccessViolated
+	case ErrUndefinedObject:
+		return e.ErrorCode == enums.ErrorCodeUndefinedObject ||
+			e.ErrorCode == enums.ErrorCodeUnavailableObject ||
+			e.ErrorCode == enums.ErrorCodeInconsistentClass
+	}
+	return false
+}
+
+// ErrorCodeDescription returns the description of the data access result.
+//
+// Parameters:
+//
+//	errorCode: Data access result.
+//
+// Returns:
+//
+//	Error as plain text.
+func ErrorCodeDescription(errorCode enums.ErrorCode) string {
+	switch errorCode {
+	case enums.ErrorCodeOk:
+		return ""
+	case enums.ErrorCodeRejected:
+		return "Rejected"
+	case enums.ErrorCodeUnacceptableFrame:
+		return "Unacceptable Frame"
+	case enums.ErrorCodeDisconnectMode:
+		return "Disconnect Mode"
+	case enums.ErrorCodeHardwareFault:
+		return "Hardware Fault"
+	case enums.ErrorCodeTemporaryFailure:
+		return "Temporary Failure"
+	case enums.ErrorCodeReadWriteDenied:
+		return "Read Write Denied"
+	case enums.ErrorCodeUndefinedObject:
+		return "Undefined Object"
+	case enums.ErrorCodeInconsistentClass:
+		return "Inconsistent Class"
+	case enums.ErrorCodeUnavailableObject:
+		return "Unavailable Object"
+	case enums.ErrorCodeUnmatchedType:
+		return "Unmatched Type"
+	case enums.ErrorCodeAccessViolated:
+		return "Access Violated"
+	case enums.ErrorCodeDataBlockUnavailable:
+		return "Data Block Unavailable"
+	case enums.ErrorCodeLongGetOrReadAborted:
+		return "Long Get Or Read Aborted"
+	case enums.ErrorCodeNoLongGetOrReadInProgress:
+		return "No Long Get Or Read In Progress"
+	case enums.ErrorCodeLongSetOrWriteAborted:
+		return "Long Set Or Write Aborted"
+	case enums.ErrorCodeNoLongSetOrWriteInProgress:
+		return "No Long Set Or Write In Progress"
+	case enums.ErrorCodeDataBlockNumberInvalid:
+		return "Data Block Number Invalid"
+	case enums.ErrorCodeOtherReason:
+		return "Other Reason"
+	default:
+		return fmt.Sprintf("Unknown Error (%d)", errorCode)
+	}
+}
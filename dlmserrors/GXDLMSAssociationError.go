@@ -0,0 +1,82 @@
+package dlmserrors
+
+// --------------------------------------------------------------------------
+//
+//	Gurux Ltd
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//
+//	$Date$
+//	$Author$
+//
+// # Copyright (c) Gurux Ltd
+//
+// ---------------------------------------------------------------------------
+//
+//	DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+// ---------------------------------------------------------------------------
+
+import (
+	"fmt"
+
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// GXDLMSAssociationError implements the error that is returned when the meter rejects the association.
+type GXDLMSAssociationError struct {
+	// Association result.
+	Result enums.AssociationResult
+	// Diagnostic is enums.SourceDiagnostic or enums.AcseServiceProvider.
+	Diagnostic any
+	// Additional information.
+	Message string
+}
+
+// NewGXDLMSAssociationError creates a new instance of GXDLMSAssociationError.
+//
+// Parameters:
+//
+//	result: Association result.
+//	diagnostic: Source diagnostic or ACSE service provider.
+func NewGXDLMSAssociationError(result enums.AssociationResult, diagnostic any) *GXDLMSAssociationError {
+	return &GXDLMSAssociationError{Result: result, Diagnostic: diagnostic}
+}
+
+// Error implements the error interface.
+func (e *GXDLMSAssociationError) Error() string {
+	return fmt.Sprintf("association rejected: %v %v%s", e.Result, e.Diagnostic, e.Message)
+}
+
+// Is reports whether the error belongs to the given error category.
+func (e *GXDLMSAssociationError) Is(target error) bool {
+	switch target {
+	case ErrTemporaryFailure:
+		return e.Result == enums.AssociationResultTransientRejected
+	case ErrSecurity:
+		switch e.Diagnostic {
+		case enums.SourceDiagnosticAuthenticationMechanismNameNotRecognized,
+			enums.SourceDiagnosticAuthenticationMechanismNameReguired,
+			enums.SourceDiagnosticAuthenticationFailure,
+			enums.SourceDiagnosticAuthenticationRequired:
+			return true
+		}
+	}
+	return false
+}
@@ -72,6 +72,31 @@ func (e *GXDLMSConfirmedServiceError) Error() string {
 	)
 }
 
+// Is reports whether the error belongs to the given error category.
+func (e *GXDLMSConfirmedServiceError) Is(target error) bool {
+	switch target {
+	case ErrTemporaryFailure:
+		return e.ServiceError == enums.ServiceErrorHardwareResource ||
+			(e.ServiceError == enums.ServiceErrorApplicationReference &&
+				enums.ApplicationReference(e.ServiceErrorValue) == enums.ApplicationReferenceTimeElapsed) ||
+			(e.ServiceError == enums.ServiceErrorVdeStateError &&
+				enums.VdeStateError(e.ServiceErrorValue) == enums.VdeStateErrorLoadingDataSet)
+	case ErrAccessDenied:
+		return e.ServiceError == enums.ServiceErrorAccess &&
+			(enums.Access(e.ServiceErrorValue) == enums.AccessScopeOfAccessViolated ||
+				enums.Access(e.ServiceErrorValue) == enums.AccessObjectAccessInvalid)
+	case ErrUndefinedObject:
+		return (e.ServiceError == enums.ServiceErrorDefinition &&
+			enums.Definition(e.ServiceErrorValue) != enums.DefinitionOther) ||
+			(e.ServiceError == enums.ServiceErrorAccess &&
+				enums.Access(e.ServiceErrorValue) == enums.AccessObjectUnavailable)
+	case ErrSecurity:
+		return e.ServiceError == enums.ServiceErrorApplicationReference &&
+			enums.ApplicationReference(e.ServiceErrorValue) == enums.ApplicationReferenceDecipheringError
+	}
+	return false
+}
+
 func getConfirmedServiceError(stateError enums.ConfirmedServiceError) string {
 	switch stateError {
 	case enums.ConfirmedServiceErrorInitiateError:
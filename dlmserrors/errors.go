@@ -35,6 +35,7 @@ package dlmserrors
 
 import (
 	"errors"
+	"fmt"
 )
 
 // ErrInvalidGloCommand is returned when an invalid global ciphering command is received.
@@ -60,3 +61,45 @@ var ErrInvalidAttributeIndex = errors.New("invalid attribute index")
 
 // ErrDataTooShort is returned when a message cannot be parsed because it does not contain enough data.
 var ErrDataTooShort = errors.New("data too short")
+
+// ErrKeyNotFound is returned when a key or certificate is not found from the key store.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrInvalidCalendar is returned when the activity calendar tables are invalid.
+var ErrInvalidCalendar = errors.New("invalid activity calendar")
+
+// ErrInvalidToken is returned when the STS token can't be decoded or validated.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrInvalidDaylightSaving is returned when the daylight saving begin or end of the clock can't be resolved.
+var ErrInvalidDaylightSaving = errors.New("invalid daylight saving")
+
+// ErrServerNotInitialized is returned when the server is used before it is initialized.
+var ErrServerNotInitialized = errors.New("server is not initialized")
+
+// ErrInvalidInterfaceType is returned when the interface type is not supported.
+var ErrInvalidInterfaceType = errors.New("invalid interface type")
+
+// ErrTemporaryFailure is matched by errors.Is when the meter reports a temporary failure and the request can be retried.
+var ErrTemporaryFailure = errors.New("temporary failure")
+
+// ErrAccessDenied is matched by errors.Is when the meter denies the access to the object.
+var ErrAccessDenied = errors.New("access denied")
+
+// ErrUndefinedObject is matched by errors.Is when the object or attribute is not available in the meter.
+var ErrUndefinedObject = errors.New("undefined object")
+
+// ErrSecurity is matched by errors.Is when ciphering, authentication or the invocation counter fails.
+var ErrSecurity = errors.New("security error")
+
+// ErrInvalidAuthenticationTag is returned when the authentication tag of the ciphered PDU is invalid.
+var ErrInvalidAuthenticationTag = fmt.Errorf("%w: invalid authentication tag", ErrSecurity)
+
+// ErrInvalidSecurity is returned when the received PDU doesn't use the required security level.
+var ErrInvalidSecurity = fmt.Errorf("%w: invalid security level", ErrSecurity)
+
+// ErrUnknownPushSender is returned when the push message is received from a meter that is not registered.
+var ErrUnknownPushSender = errors.New("unknown push sender")
+
+// ErrInvalidPushSetup is returned when the push setup can't be used with the requested settings.
+var ErrInvalidPushSetup = errors.New("invalid push setup")
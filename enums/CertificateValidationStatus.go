@@ -0,0 +1,147 @@
+﻿package enums
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+)
+
+// CertificateValidationStatus describes the result of the X.509 certificate validation.
+type CertificateValidationStatus int
+
+const (
+	// CertificateValidationStatusValid defines that the certificate is valid.
+	CertificateValidationStatusValid CertificateValidationStatus = iota
+	// CertificateValidationStatusUntrusted defines that the certificate path doesn't end to the trusted certificate.
+	CertificateValidationStatusUntrusted
+	// CertificateValidationStatusInvalidSignature defines that the signature of the certificate is invalid.
+	CertificateValidationStatusInvalidSignature
+	// CertificateValidationStatusNotYetValid defines that the validity period of the certificate has not started.
+	CertificateValidationStatusNotYetValid
+	// CertificateValidationStatusExpired defines that the validity period of the certificate has ended.
+	CertificateValidationStatusExpired
+	// CertificateValidationStatusInvalidKeyUsage defines that the key usage doesn't match to the certificate type.
+	CertificateValidationStatusInvalidKeyUsage
+	// CertificateValidationStatusInvalidIssuer defines that the issuer is not allowed to sign certificates.
+	CertificateValidationStatusInvalidIssuer
+	// CertificateValidationStatusSystemTitleMismatch defines that the subject doesn't match to the system title.
+	CertificateValidationStatusSystemTitleMismatch
+	// CertificateValidationStatusRevoked defines that the certificate is revoked.
+	CertificateValidationStatusRevoked
+	// CertificateValidationStatusStaleCrl defines that the certificate revocation list of the issuer is out of date.
+	CertificateValidationStatusStaleCrl
+)
+
+// CertificateValidationStatusParse converts the given string into a CertificateValidationStatus value.
+//
+// It returns the corresponding CertificateValidationStatus constant if the string matches
+// a known level name, or an error if the input is invalid.
+func CertificateValidationStatusParse(value string) (CertificateValidationStatus, error) {
+	var ret CertificateValidationStatus
+	var err error
+	switch {
+	case strings.EqualFold(value, "Valid"):
+		ret = CertificateValidationStatusValid
+	case strings.EqualFold(value, "Untrusted"):
+		ret = CertificateValidationStatusUntrusted
+	case strings.EqualFold(value, "InvalidSignature"):
+		ret = CertificateValidationStatusInvalidSignature
+	case strings.EqualFold(value, "NotYetValid"):
+		ret = CertificateValidationStatusNotYetValid
+	case strings.EqualFold(value, "Expired"):
+		ret = CertificateValidationStatusExpired
+	case strings.EqualFold(value, "InvalidKeyUsage"):
+		ret = CertificateValidationStatusInvalidKeyUsage
+	case strings.EqualFold(value, "InvalidIssuer"):
+		ret = CertificateValidationStatusInvalidIssuer
+	case strings.EqualFold(value, "SystemTitleMismatch"):
+		ret = CertificateValidationStatusSystemTitleMismatch
+	case strings.EqualFold(value, "Revoked"):
+		ret = CertificateValidationStatusRevoked
+	case strings.EqualFold(value, "StaleCrl"):
+		ret = CertificateValidationStatusStaleCrl
+	default:
+		err = fmt.Errorf("%w: %q", gxcommon.ErrUnknownEnum, value)
+	}
+	return ret, err
+}
+
+// String returns the canonical name of the CertificateValidationStatus.
+// It satisfies fmt.Stringer.
+func (g CertificateValidationStatus) String() string {
+	var ret string
+	switch g {
+	case CertificateValidationStatusValid:
+		ret = "Valid"
+	case CertificateValidationStatusUntrusted:
+		ret = "Untrusted"
+	case CertificateValidationStatusInvalidSignature:
+		ret = "InvalidSignature"
+	case CertificateValidationStatusNotYetValid:
+		ret = "NotYetValid"
+	case CertificateValidationStatusExpired:
+		ret = "Expired"
+	case CertificateValidationStatusInvalidKeyUsage:
+		ret = "InvalidKeyUsage"
+	case CertificateValidationStatusInvalidIssuer:
+		ret = "InvalidIssuer"
+	case CertificateValidationStatusSystemTitleMismatch:
+		ret = "SystemTitleMismatch"
+	case CertificateValidationStatusRevoked:
+		ret = "Revoked"
+	case CertificateValidationStatusStaleCrl:
+		ret = "StaleCrl"
+	}
+	return ret
+}
+
+// AllCertificateValidationStatus returns a slice containing all defined CertificateValidationStatus values.
+func AllCertificateValidationStatus() []CertificateValidationStatus {
+	return []CertificateValidationStatus{
+		CertificateValidationStatusValid,
+		CertificateValidationStatusUntrusted,
+		CertificateValidationStatusInvalidSignature,
+		CertificateValidationStatusNotYetValid,
+		CertificateValidationStatusExpired,
+		CertificateValidationStatusInvalidKeyUsage,
+		CertificateValidationStatusInvalidIssuer,
+		CertificateValidationStatusSystemTitleMismatch,
+		CertificateValidationStatusRevoked,
+		CertificateValidationStatusStaleCrl,
+	}
+}
@@ -0,0 +1,112 @@
+﻿package enums
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+)
+
+// ClockSyncDecision enumerates how the meter clock is synchronised.
+type ClockSyncDecision int
+
+const (
+	// ClockSyncDecisionNone defines that the clock drift is within the tolerance.
+	ClockSyncDecisionNone ClockSyncDecision = iota
+	// ClockSyncDecisionShift defines that the clock is shifted with shift_time method.
+	ClockSyncDecisionShift
+	// ClockSyncDecisionPreset defines that the clock is set with preset_adjusting_time and adjust_to_preset_time methods.
+	ClockSyncDecisionPreset
+	// ClockSyncDecisionWrite defines that the clock time attribute is written.
+	ClockSyncDecisionWrite
+	// ClockSyncDecisionDeferred defines that the correction would cross the capture period boundary and it's done later.
+	ClockSyncDecisionDeferred
+)
+
+// ClockSyncDecisionParse converts the given string into a ClockSyncDecision value.
+//
+// It returns the corresponding ClockSyncDecision constant if the string matches
+// a known level name, or an error if the input is invalid.
+func ClockSyncDecisionParse(value string) (ClockSyncDecision, error) {
+	var ret ClockSyncDecision
+	var err error
+	switch {
+	case strings.EqualFold(value, "None"):
+		ret = ClockSyncDecisionNone
+	case strings.EqualFold(value, "Shift"):
+		ret = ClockSyncDecisionShift
+	case strings.EqualFold(value, "Preset"):
+		ret = ClockSyncDecisionPreset
+	case strings.EqualFold(value, "Write"):
+		ret = ClockSyncDecisionWrite
+	case strings.EqualFold(value, "Deferred"):
+		ret = ClockSyncDecisionDeferred
+	default:
+		err = fmt.Errorf("%w: %q", gxcommon.ErrUnknownEnum, value)
+	}
+	return ret, err
+}
+
+// String returns the canonical name of the ClockSyncDecision.
+// It satisfies fmt.Stringer.
+func (g ClockSyncDecision) String() string {
+	var ret string
+	switch g {
+	case ClockSyncDecisionNone:
+		ret = "None"
+	case ClockSyncDecisionShift:
+		ret = "Shift"
+	case ClockSyncDecisionPreset:
+		ret = "Preset"
+	case ClockSyncDecisionWrite:
+		ret = "Write"
+	case ClockSyncDecisionDeferred:
+		ret = "Deferred"
+	}
+	return ret
+}
+
+// AllClockSyncDecision returns a slice containing all defined ClockSyncDecision values.
+func AllClockSyncDecision() []ClockSyncDecision {
+	return []ClockSyncDecision{
+		ClockSyncDecisionNone,
+		ClockSyncDecisionShift,
+		ClockSyncDecisionPreset,
+		ClockSyncDecisionWrite,
+		ClockSyncDecisionDeferred,
+	}
+}
@@ -141,6 +141,8 @@ const (
 	CommandGeneralCiphering = 0xDD
 	// CommandGeneralSigning defines that the general signing.
 	CommandGeneralSigning = 0xDF
+	// CommandUnconfirmedWriteRequest defines that the unconfirmed write request.
+	CommandUnconfirmedWriteRequest = 0x16
 	// CommandInformationReport defines that the information Report request.
 	CommandInformationReport = 0x18
 	// CommandEventNotification defines that the event Notification request.
@@ -297,6 +299,8 @@ func CommandParse(value string) (Command, error) {
 		ret = CommandGeneralCiphering
 	case strings.EqualFold(value, "GeneralSigning"):
 		ret = CommandGeneralSigning
+	case strings.EqualFold(value, "UnconfirmedWriteRequest"):
+		ret = CommandUnconfirmedWriteRequest
 	case strings.EqualFold(value, "InformationReport"):
 		ret = CommandInformationReport
 	case strings.EqualFold(value, "EventNotification"):
@@ -454,6 +458,8 @@ func (g Command) String() string {
 		ret = "GeneralCiphering"
 	case CommandGeneralSigning:
 		ret = "GeneralSigning"
+	case CommandUnconfirmedWriteRequest:
+		ret = "UnconfirmedWriteRequest"
 	case CommandInformationReport:
 		ret = "InformationReport"
 	case CommandEventNotification:
@@ -559,6 +565,7 @@ func AllCommand() []Command {
 		CommandGeneralDedCiphering,
 		CommandGeneralCiphering,
 		CommandGeneralSigning,
+		CommandUnconfirmedWriteRequest,
 		CommandInformationReport,
 		CommandEventNotification,
 		CommandDedInitiateRequest,
@@ -0,0 +1,105 @@
+﻿package enums
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+)
+
+// MBusFunction enumerates the function field of the M-Bus data information field.
+type MBusFunction int
+
+const (
+	// MBusFunctionInstantaneous defines that the value is instantaneous value.
+	MBusFunctionInstantaneous MBusFunction = iota
+	// MBusFunctionMaximum defines that the value is maximum value.
+	MBusFunctionMaximum
+	// MBusFunctionMinimum defines that the value is minimum value.
+	MBusFunctionMinimum
+	// MBusFunctionValueDuringError defines that the value is measured during error state.
+	MBusFunctionValueDuringError
+)
+
+// MBusFunctionParse converts the given string into a MBusFunction value.
+//
+// It returns the corresponding MBusFunction constant if the string matches
+// a known level name, or an error if the input is invalid.
+func MBusFunctionParse(value string) (MBusFunction, error) {
+	var ret MBusFunction
+	var err error
+	switch {
+	case strings.EqualFold(value, "Instantaneous"):
+		ret = MBusFunctionInstantaneous
+	case strings.EqualFold(value, "Maximum"):
+		ret = MBusFunctionMaximum
+	case strings.EqualFold(value, "Minimum"):
+		ret = MBusFunctionMinimum
+	case strings.EqualFold(value, "ValueDuringError"):
+		ret = MBusFunctionValueDuringError
+	default:
+		err = fmt.Errorf("%w: %q", gxcommon.ErrUnknownEnum, value)
+	}
+	return ret, err
+}
+
+// String returns the canonical name of the MBusFunction.
+// It satisfies fmt.Stringer.
+func (g MBusFunction) String() string {
+	var ret string
+	switch g {
+	case MBusFunctionInstantaneous:
+		ret = "Instantaneous"
+	case MBusFunctionMaximum:
+		ret = "Maximum"
+	case MBusFunctionMinimum:
+		ret = "Minimum"
+	case MBusFunctionValueDuringError:
+		ret = "ValueDuringError"
+	}
+	return ret
+}
+
+// AllMBusFunction returns a slice containing all defined MBusFunction values.
+func AllMBusFunction() []MBusFunction {
+	return []MBusFunction{
+		MBusFunctionInstantaneous,
+		MBusFunctionMaximum,
+		MBusFunctionMinimum,
+		MBusFunctionValueDuringError,
+	}
+}
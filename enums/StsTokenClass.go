@@ -0,0 +1,105 @@
+﻿package enums
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+)
+
+// StsTokenClass enumerates the token classes of IEC 62055-41.
+type StsTokenClass int
+
+const (
+	// StsTokenClassCreditTransfer defines that the token transfers credit to the meter.
+	StsTokenClassCreditTransfer StsTokenClass = iota
+	// StsTokenClassNonMeterSpecificManagement defines that the token is a non-meter specific management token.
+	StsTokenClassNonMeterSpecificManagement
+	// StsTokenClassMeterSpecificManagement defines that the token is a meter specific management token.
+	StsTokenClassMeterSpecificManagement
+	// StsTokenClassReserved defines that the token class is reserved.
+	StsTokenClassReserved
+)
+
+// StsTokenClassParse converts the given string into a StsTokenClass value.
+//
+// It returns the corresponding StsTokenClass constant if the string matches
+// a known level name, or an error if the input is invalid.
+func StsTokenClassParse(value string) (StsTokenClass, error) {
+	var ret StsTokenClass
+	var err error
+	switch {
+	case strings.EqualFold(value, "CreditTransfer"):
+		ret = StsTokenClassCreditTransfer
+	case strings.EqualFold(value, "NonMeterSpecificManagement"):
+		ret = StsTokenClassNonMeterSpecificManagement
+	case strings.EqualFold(value, "MeterSpecificManagement"):
+		ret = StsTokenClassMeterSpecificManagement
+	case strings.EqualFold(value, "Reserved"):
+		ret = StsTokenClassReserved
+	default:
+		err = fmt.Errorf("%w: %q", gxcommon.ErrUnknownEnum, value)
+	}
+	return ret, err
+}
+
+// String returns the canonical name of the StsTokenClass.
+// It satisfies fmt.Stringer.
+func (g StsTokenClass) String() string {
+	var ret string
+	switch g {
+	case StsTokenClassCreditTransfer:
+		ret = "CreditTransfer"
+	case StsTokenClassNonMeterSpecificManagement:
+		ret = "NonMeterSpecificManagement"
+	case StsTokenClassMeterSpecificManagement:
+		ret = "MeterSpecificManagement"
+	case StsTokenClassReserved:
+		ret = "Reserved"
+	}
+	return ret
+}
+
+// AllStsTokenClass returns a slice containing all defined StsTokenClass values.
+func AllStsTokenClass() []StsTokenClass {
+	return []StsTokenClass{
+		StsTokenClassCreditTransfer,
+		StsTokenClassNonMeterSpecificManagement,
+		StsTokenClassMeterSpecificManagement,
+		StsTokenClassReserved,
+	}
+}
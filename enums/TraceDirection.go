@@ -0,0 +1,91 @@
+﻿package enums
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxcommon-go"
+)
+
+// TraceDirection enumerates the direction of the traced frame.
+type TraceDirection int
+
+const (
+	// TraceDirectionSent defines that the frame is sent.
+	TraceDirectionSent TraceDirection = iota
+	// TraceDirectionReceived defines that the frame is received.
+	TraceDirectionReceived
+)
+
+// TraceDirectionParse converts the given string into a TraceDirection value.
+//
+// It returns the corresponding TraceDirection constant if the string matches
+// a known level name, or an error if the input is invalid.
+func TraceDirectionParse(value string) (TraceDirection, error) {
+	var ret TraceDirection
+	var err error
+	switch {
+	case strings.EqualFold(value, "Sent"):
+		ret = TraceDirectionSent
+	case strings.EqualFold(value, "Received"):
+		ret = TraceDirectionReceived
+	default:
+		err = fmt.Errorf("%w: %q", gxcommon.ErrUnknownEnum, value)
+	}
+	return ret, err
+}
+
+// String returns the canonical name of the TraceDirection.
+// It satisfies fmt.Stringer.
+func (g TraceDirection) String() string {
+	var ret string
+	switch g {
+	case TraceDirectionSent:
+		ret = "Sent"
+	case TraceDirectionReceived:
+		ret = "Received"
+	}
+	return ret
+}
+
+// AllTraceDirection returns a slice containing all defined TraceDirection values.
+func AllTraceDirection() []TraceDirection {
+	return []TraceDirection{
+		TraceDirectionSent,
+		TraceDirectionReceived,
+	}
+}
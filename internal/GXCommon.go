@@ -166,6 +166,27 @@ func GetDLMSDataType(dt reflect.Type) (enums.DataType, error) {
 	if dt == reflect.TypeOf(types.GXByteBuffer{}) {
 		return enums.DataTypeOctetString, nil
 	}
+	if dt == reflect.TypeOf(types.GXCompactArray{}) || dt == reflect.TypeOf(&types.GXCompactArray{}) {
+		return enums.DataTypeCompactArray, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaInt8{}) {
+		return enums.DataTypeDeltaInt8, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaInt16{}) {
+		return enums.DataTypeDeltaInt16, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaInt32{}) {
+		return enums.DataTypeDeltaInt32, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaUInt8{}) {
+		return enums.DataTypeDeltaUint8, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaUInt16{}) {
+		return enums.DataTypeDeltaUint16, nil
+	}
+	if dt == reflect.TypeOf(types.GXDeltaUInt32{}) {
+		return enums.DataTypeDeltaUint32, nil
+	}
 	return enums.DataTypeNone, fmt.Errorf("unknown DLMS data type for %v", dt)
 }
 
@@ -282,6 +303,8 @@ func GetData(settings *settings.GXDLMSSettings, data *types.GXByteBuffer, info *
 		value = types.GXDeltaUInt16{Value: getUint16(data, info)}
 	case enums.DataTypeDeltaUint32:
 		value = types.GXDeltaUInt32{Value: getUint32(data, info)}
+	case enums.DataTypeCompactArray:
+		value, err = getCompactArray(settings, data, info, startIndex)
 	default:
 		err = fmt.Errorf("unsupported DLMS data type: %v", info.Type)
 	}
@@ -975,9 +998,25 @@ func SetData(s any, buff *types.GXByteBuffer, dt enums.DataType, value any) erro
 	if s != nil {
 		conf = s.(*settings.GXDLMSSettings)
 	}
+	// If array or structure is already encoded, data type is not added.
+	if dt == enums.DataTypeArray || dt == enums.DataTypeStructure {
+		switch v := value.(type) {
+		case []byte:
+			return buff.Set(v)
+		case *types.GXByteBuffer:
+			return buff.Set(v.Array())
+		case types.GXByteBuffer:
+			return buff.Set(v.Array())
+		}
+	}
 	if err := buff.SetUint8(uint8(dt)); err != nil {
 		return err
 	}
+	return setDataValue(conf, buff, dt, value)
+}
+
+// setDataValue writes the value without the data type tag.
+func setDataValue(conf *settings.GXDLMSSettings, buff *types.GXByteBuffer, dt enums.DataType, value any) error {
 	switch dt {
 	case enums.DataTypeNone:
 		return nil
@@ -1040,6 +1079,15 @@ func SetData(s any, buff *types.GXByteBuffer, dt enums.DataType, value any) erro
 		}
 	case enums.DataTypeOctetString:
 		var b []byte
+		// Date and time pointers are handled as values.
+		switch v := value.(type) {
+		case *types.GXDate:
+			value = *v
+		case *types.GXTime:
+			value = *v
+		case *types.GXDateTime:
+			value = *v
+		}
 		switch v := value.(type) {
 		case types.GXDate:
 			//Add size
@@ -1074,8 +1122,23 @@ func SetData(s any, buff *types.GXByteBuffer, dt enums.DataType, value any) erro
 		return buff.Set(b)
 	case enums.DataTypeBitString:
 		return setBitString(buff, value, true)
-	case enums.DataTypeDate, enums.DataTypeTime, enums.DataTypeDateTime:
-		return fmt.Errorf("%v serialization is not implemented", dt)
+	case enums.DataTypeBcd:
+		return setBcd(buff, value)
+	case enums.DataTypeDeltaInt8, enums.DataTypeDeltaInt16, enums.DataTypeDeltaInt32,
+		enums.DataTypeDeltaUint8, enums.DataTypeDeltaUint16, enums.DataTypeDeltaUint32:
+		v, err := deltaToInt64(value)
+		if err != nil {
+			return err
+		}
+		return setDelta(buff, dt, v)
+	case enums.DataTypeCompactArray:
+		return setCompactArray(conf, buff, value)
+	case enums.DataTypeDate:
+		return setDate(conf, buff, value)
+	case enums.DataTypeTime:
+		return setTime(conf, buff, value)
+	case enums.DataTypeDateTime:
+		return setDateTime(conf, buff, value)
 	default:
 		return fmt.Errorf("unsupported DLMS data type: %v", dt)
 	}
@@ -0,0 +1,465 @@
+package internal
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// errCompactArrayIncomplete is returned when compact array is not received completely.
+var errCompactArrayIncomplete = errors.New("compact array is not complete")
+
+// compactArrayColumns holds the last value of each delta column.
+//
+// Delta values are accumulated against the value in the same column of the previous element.
+type compactArrayColumns struct {
+	previous map[int]int64
+	column   int
+}
+
+// accumulate returns the absolute value of the delta and stores it as the last column value.
+func (g *compactArrayColumns) accumulate(delta int64) int64 {
+	if prev, ok := g.previous[g.column]; ok {
+		delta += prev
+	}
+	g.previous[g.column] = delta
+	return delta
+}
+
+// difference returns the difference to the previous column value and stores the value.
+func (g *compactArrayColumns) difference(value int64) int64 {
+	ret := value
+	if prev, ok := g.previous[g.column]; ok {
+		ret -= prev
+	}
+	g.previous[g.column] = value
+	return ret
+}
+
+// isDeltaType returns true if the data type is delta type.
+func isDeltaType(dt enums.DataType) bool {
+	switch dt {
+	case enums.DataTypeDeltaInt8, enums.DataTypeDeltaInt16, enums.DataTypeDeltaInt32,
+		enums.DataTypeDeltaUint8, enums.DataTypeDeltaUint16, enums.DataTypeDeltaUint32:
+		return true
+	}
+	return false
+}
+
+// deltaToInt64 converts delta or integer value to int64.
+func deltaToInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case types.GXDeltaInt8:
+		return int64(v.Value), nil
+	case types.GXDeltaInt16:
+		return int64(v.Value), nil
+	case types.GXDeltaInt32:
+		return int64(v.Value), nil
+	case types.GXDeltaUInt8:
+		return int64(v.Value), nil
+	case types.GXDeltaUInt16:
+		return int64(v.Value), nil
+	case types.GXDeltaUInt32:
+		return int64(v.Value), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("delta value %d is out of range", v)
+		}
+		return int64(v), nil
+	case uint:
+		return int64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid delta value: %T", value)
+	}
+}
+
+// setDelta writes the delta value without the data type tag.
+func setDelta(buff *types.GXByteBuffer, dt enums.DataType, value int64) error {
+	var min, max int64
+	switch dt {
+	case enums.DataTypeDeltaInt8:
+		min, max = math.MinInt8, math.MaxInt8
+	case enums.DataTypeDeltaInt16:
+		min, max = math.MinInt16, math.MaxInt16
+	case enums.DataTypeDeltaInt32:
+		min, max = math.MinInt32, math.MaxInt32
+	case enums.DataTypeDeltaUint8:
+		max = math.MaxUint8
+	case enums.DataTypeDeltaUint16:
+		max = math.MaxUint16
+	case enums.DataTypeDeltaUint32:
+		max = math.MaxUint32
+	default:
+		return fmt.Errorf("invalid delta type: %v", dt)
+	}
+	if value < min || value > max {
+		return fmt.Errorf("%v value %d is out of range", dt, value)
+	}
+	switch GetDataTypeSize(dt) {
+	case 1:
+		return buff.SetUint8(uint8(value))
+	case 2:
+		return buff.SetUint16(uint16(value))
+	default:
+		return buff.SetUint32(uint32(value))
+	}
+}
+
+// getCompactDataType reads the type description of the compact array.
+func getCompactDataType(buff *types.GXByteBuffer, xml *settings.GXDLMSTranslatorStructure) (types.GXCompactDataType, error) {
+	var ret types.GXCompactDataType
+	ch, err := buff.Uint8()
+	if err != nil {
+		return ret, errCompactArrayIncomplete
+	}
+	ret.Type = enums.DataType(ch)
+	switch ret.Type {
+	case enums.DataTypeStructure:
+		cnt, err := types.GetObjectCount(buff)
+		if err != nil {
+			return ret, errCompactArrayIncomplete
+		}
+		if cnt == 0 {
+			return ret, errors.New("invalid compact array type description. Structure is empty")
+		}
+		if xml != nil {
+			xml.AppendStartTag(settings.DataTypeOffset+int(ret.Type), "Qty", xml.IntegerToHex(cnt, 2, false), true)
+		}
+		for pos := 0; pos != cnt; pos++ {
+			it, err := getCompactDataType(buff, xml)
+			if err != nil {
+				return ret, err
+			}
+			ret.Elements = append(ret.Elements, it)
+		}
+		if xml != nil {
+			xml.AppendEndTag(settings.DataTypeOffset+int(ret.Type), true)
+		}
+	case enums.DataTypeArray:
+		cnt, err := buff.Uint16()
+		if err != nil {
+			return ret, errCompactArrayIncomplete
+		}
+		if cnt == 0 {
+			return ret, errors.New("invalid compact array type description. Array is empty")
+		}
+		ret.Count = int(cnt)
+		if xml != nil {
+			xml.AppendStartTag(settings.DataTypeOffset+int(ret.Type), "Qty", xml.IntegerToHex(cnt, 4, false), true)
+		}
+		it, err := getCompactDataType(buff, xml)
+		if err != nil {
+			return ret, err
+		}
+		ret.Elements = []types.GXCompactDataType{it}
+		if xml != nil {
+			xml.AppendEndTag(settings.DataTypeOffset+int(ret.Type), true)
+		}
+	case enums.DataTypeNone, enums.DataTypeCompactArray:
+		return ret, fmt.Errorf("invalid compact array type: %v", ret.Type)
+	default:
+		if xml != nil {
+			xml.AppendStringLine("<" + xml.GetDataType(ret.Type) + " />")
+		}
+	}
+	return ret, nil
+}
+
+// setCompactDataType writes the type description of the compact array.
+func setCompactDataType(buff *types.GXByteBuffer, dt types.GXCompactDataType) error {
+	if err := buff.SetUint8(uint8(dt.Type)); err != nil {
+		return err
+	}
+	switch dt.Type {
+	case enums.DataTypeStructure:
+		if len(dt.Elements) == 0 {
+			return fmt.Errorf("compact array structure is empty")
+		}
+		if err := types.SetObjectCount(len(dt.Elements), buff); err != nil {
+			return err
+		}
+		for _, it := range dt.Elements {
+			if err := setCompactDataType(buff, it); err != nil {
+				return err
+			}
+		}
+	case enums.DataTypeArray:
+		if len(dt.Elements) != 1 {
+			return fmt.Errorf("compact array element type is missing")
+		}
+		if dt.Count == 0 {
+			return fmt.Errorf("compact array array is empty")
+		}
+		if err := buff.SetUint16(uint16(dt.Count)); err != nil {
+			return err
+		}
+		return setCompactDataType(buff, dt.Elements[0])
+	case enums.DataTypeNone, enums.DataTypeCompactArray:
+		return fmt.Errorf("invalid compact array type: %v", dt.Type)
+	}
+	return nil
+}
+
+// getCompactValue reads one element of the compact array contents.
+func getCompactValue(opts *settings.GXDLMSSettings, buff *types.GXByteBuffer, xml *settings.GXDLMSTranslatorStructure,
+	dt types.GXCompactDataType, columns *compactArrayColumns) (any, error) {
+	switch dt.Type {
+	case enums.DataTypeStructure, enums.DataTypeArray:
+		cnt := len(dt.Elements)
+		if dt.Type == enums.DataTypeArray {
+			cnt = dt.Count
+		}
+		if xml != nil {
+			xml.AppendStartTag(settings.DataTypeOffset+int(dt.Type), "Qty", xml.IntegerToHex(cnt, 2, false), true)
+		}
+		items := make([]any, 0, cnt)
+		for pos := 0; pos != cnt; pos++ {
+			it := dt.Elements[0]
+			if dt.Type == enums.DataTypeStructure {
+				it = dt.Elements[pos]
+			}
+			value, err := getCompactValue(opts, buff, xml, it, columns)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		if xml != nil {
+			xml.AppendEndTag(settings.DataTypeOffset+int(dt.Type), true)
+		}
+		if dt.Type == enums.DataTypeArray {
+			return types.GXArray(items), nil
+		}
+		return types.GXStructure(items), nil
+	}
+	info := &GXDataInfo{Type: dt.Type, Xml: xml, Complete: true}
+	var value any
+	var err error
+	// Variable length values are always sent with the length.
+	switch dt.Type {
+	case enums.DataTypeOctetString:
+		value = getOctetString(opts, buff, info, false)
+	case enums.DataTypeString:
+		value = getString(buff, info, false)
+	case enums.DataTypeStringUTF8:
+		value = getUtf8String(buff, info, false)
+	default:
+		value, err = GetData(opts, buff, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !info.Complete {
+		return nil, errCompactArrayIncomplete
+	}
+	if isDeltaType(dt.Type) {
+		delta, err := deltaToInt64(value)
+		if err != nil {
+			return nil, err
+		}
+		delta = columns.accumulate(delta)
+		switch dt.Type {
+		case enums.DataTypeDeltaUint8, enums.DataTypeDeltaUint16, enums.DataTypeDeltaUint32:
+			value = uint64(delta)
+		default:
+			value = delta
+		}
+	}
+	columns.column++
+	return value, nil
+}
+
+// setCompactValue writes one element of the compact array contents.
+func setCompactValue(conf *settings.GXDLMSSettings, buff *types.GXByteBuffer,
+	dt types.GXCompactDataType, value any, columns *compactArrayColumns) error {
+	switch dt.Type {
+	case enums.DataTypeStructure, enums.DataTypeArray:
+		var items []any
+		switch v := value.(type) {
+		case []any:
+			items = v
+		case types.GXArray:
+			items = []any(v)
+		case types.GXStructure:
+			items = []any(v)
+		default:
+			return fmt.Errorf("invalid value for %v: %T", dt.Type, value)
+		}
+		cnt := len(dt.Elements)
+		if dt.Type == enums.DataTypeArray {
+			cnt = dt.Count
+		}
+		if len(items) != cnt {
+			return fmt.Errorf("invalid compact array element count. Expected %d, got %d", cnt, len(items))
+		}
+		for pos, it := range items {
+			t := dt.Elements[0]
+			if dt.Type == enums.DataTypeStructure {
+				t = dt.Elements[pos]
+			}
+			if err := setCompactValue(conf, buff, t, it, columns); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if isDeltaType(dt.Type) {
+		var v int64
+		if v, err = deltaToInt64(value); err == nil {
+			err = setDelta(buff, dt.Type, columns.difference(v))
+		}
+	} else {
+		err = setDataValue(conf, buff, dt.Type, value)
+	}
+	columns.column++
+	return err
+}
+
+// getCompactArray retrieves a compact array from DLMS data.
+//
+// Array is returned as GXCompactArray that holds the type description and the elements.
+// Structure elements are returned as structures.
+// Delta values are accumulated and returned as int64 or uint64.
+func getCompactArray(opts *settings.GXDLMSSettings, buff *types.GXByteBuffer, info *GXDataInfo, index int) (any, error) {
+	xml := info.Xml
+	if xml != nil {
+		xml.AppendStartTag(settings.DataTypeOffset+int(enums.DataTypeCompactArray), "", "", true)
+		xml.AppendStartTag(int(TranslatorTagsContentsDescription), "", "", true)
+	}
+	dt, err := getCompactDataType(buff, xml)
+	if err == nil {
+		var size int
+		if size, err = types.GetObjectCount(buff); err != nil || buff.Available() < size {
+			err = errCompactArrayIncomplete
+		} else {
+			if xml != nil {
+				xml.AppendEndTag(int(TranslatorTagsContentsDescription), true)
+				xml.AppendStartTag(int(TranslatorTagsArrayContents), "", "", true)
+				if xml.OutputType() == enums.TranslatorOutputTypeStandardXML {
+					xml.AppendString(ToHex(buff.Array(), false, buff.Position(), size))
+					// Values are shown only as hex in standard XML.
+					xml = nil
+				}
+			}
+			var value any
+			var arr types.GXArray
+			end := buff.Position() + size
+			columns := &compactArrayColumns{previous: map[int]int64{}}
+			for buff.Position() < end {
+				columns.column = 0
+				pos := buff.Position()
+				if value, err = getCompactValue(opts, buff, xml, dt, columns); err != nil {
+					break
+				}
+				// Element must always consume data or the array never ends.
+				if buff.Position() == pos {
+					err = fmt.Errorf("invalid compact array contents")
+					break
+				}
+				arr = append(arr, value)
+			}
+			if err == nil && buff.Position() != end {
+				err = fmt.Errorf("invalid compact array contents")
+			}
+			if err == nil {
+				if info.Xml != nil {
+					info.Xml.AppendEndTag(int(TranslatorTagsArrayContents), true)
+					info.Xml.AppendEndTag(settings.DataTypeOffset+int(enums.DataTypeCompactArray), true)
+				}
+				return types.GXCompactArray{Description: dt, Values: arr}, nil
+			}
+		}
+	}
+	if errors.Is(err, errCompactArrayIncomplete) {
+		if info.Xml != nil {
+			info.Xml.AppendComment("Error: Not enough data.")
+		}
+		buff.SetPosition(index)
+		info.Complete = false
+		return nil, nil
+	}
+	return nil, err
+}
+
+// setCompactArray writes the compact array without the data type tag.
+func setCompactArray(conf *settings.GXDLMSSettings, buff *types.GXByteBuffer, value any) error {
+	var arr types.GXCompactArray
+	switch v := value.(type) {
+	case types.GXCompactArray:
+		arr = v
+	case *types.GXCompactArray:
+		arr = *v
+	default:
+		return fmt.Errorf("invalid compact array value: %T", value)
+	}
+	if err := setCompactDataType(buff, arr.Description); err != nil {
+		return err
+	}
+	contents := types.NewGXByteBuffer()
+	columns := &compactArrayColumns{previous: map[int]int64{}}
+	for _, it := range arr.Values {
+		columns.column = 0
+		if err := setCompactValue(conf, contents, arr.Description, it, columns); err != nil {
+			return err
+		}
+	}
+	if err := types.SetObjectCount(contents.Size(), buff); err != nil {
+		return err
+	}
+	return buff.Set(contents.Array())
+}
@@ -0,0 +1,357 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXActivityCalendarLimits defines how many entries the meter supports.
+// Zero value means that the amount is not limited.
+type GXActivityCalendarLimits struct {
+	// Maximum amount of season profiles.
+	Seasons int
+	// Maximum amount of week profiles.
+	Weeks int
+	// Maximum amount of day profiles.
+	Days int
+	// Maximum amount of actions in one day profile.
+	Actions int
+}
+
+// GXActivityCalendarBuilder builds and validates activity calendar tables
+// before they are written to the meter.
+//
+// Names are given as strings. If the Saudi Arabia standard is used,
+// names are octet strings and they are given as hex strings.
+type GXActivityCalendarBuilder struct {
+	// Calendar name.
+	Name string
+	// Used standard.
+	Standard enums.Standard
+	// Meter limits.
+	Limits GXActivityCalendarLimits
+
+	seasons []GXDLMSSeasonProfile
+	weeks   []GXDLMSWeekProfile
+	days    []GXDLMSDayProfile
+	errs    []error
+}
+
+// NewGXActivityCalendarBuilder creates a new activity calendar builder.
+func NewGXActivityCalendarBuilder(name string, standard enums.Standard) *GXActivityCalendarBuilder {
+	return &GXActivityCalendarBuilder{Name: name, Standard: standard}
+}
+
+// name converts the name to the byte array.
+func (g *GXActivityCalendarBuilder) name(value string) []byte {
+	if g.Standard != enums.StandardSaudiArabia {
+		return []byte(value)
+	}
+	ret, err := hex.DecodeString(strings.ReplaceAll(value, " ", ""))
+	if err != nil {
+		g.errs = append(g.errs, fmt.Errorf("%w: name %q is not a hex string", dlmserrors.ErrInvalidCalendar, value))
+		return nil
+	}
+	return ret
+}
+
+// AddSeason adds a season that starts every year at the beginning of the given day.
+func (g *GXActivityCalendarBuilder) AddSeason(name string, month time.Month, day int, weekName string) *GXActivityCalendarBuilder {
+	start := types.GXDateTime{Value: time.Date(2000, month, day, 0, 0, 0, 0, time.Local)}
+	start.Skip = enums.DateTimeSkipsYear | enums.DateTimeSkipsDayOfWeek | enums.DateTimeSkipsMs | enums.DateTimeSkipsDeviation
+	return g.AddSeasonAt(name, start, weekName)
+}
+
+// AddSeasonAt adds a season with the given start time.
+func (g *GXActivityCalendarBuilder) AddSeasonAt(name string, start types.GXDateTime, weekName string) *GXActivityCalendarBuilder {
+	g.seasons = append(g.seasons, GXDLMSSeasonProfile{Name: g.name(name), Start: start, WeekName: g.name(weekName)})
+	return g
+}
+
+// AddWeek adds a week profile. Day IDs are given from Monday to Sunday.
+func (g *GXActivityCalendarBuilder) AddWeek(name string, days [7]uint8) *GXActivityCalendarBuilder {
+	g.weeks = append(g.weeks, GXDLMSWeekProfile{Name: g.name(name),
+		Monday: days[0], Tuesday: days[1], Wednesday: days[2], Thursday: days[3],
+		Friday: days[4], Saturday: days[5], Sunday: days[6]})
+	return g
+}
+
+// AddDay adds a day profile without actions.
+func (g *GXActivityCalendarBuilder) AddDay(dayID uint8) *GXActivityCalendarBuilder {
+	g.days = append(g.days, GXDLMSDayProfile{DayID: dayID})
+	return g
+}
+
+// AddAction adds an action to the day profile.
+//
+// Parameters:
+//
+//	dayID: Day ID where the action is added.
+//	hour: Start hour.
+//	minute: Start minute.
+//	scriptLogicalName: Logical name of the script table.
+//	scriptSelector: Executed script ID.
+func (g *GXActivityCalendarBuilder) AddAction(dayID uint8, hour int, minute int, scriptLogicalName string, scriptSelector uint16) *GXActivityCalendarBuilder {
+	for pos := range g.days {
+		if g.days[pos].DayID == dayID {
+			start, _ := types.NewGXTime(hour, minute, 0, 0)
+			start.Skip |= enums.DateTimeSkipsMs | enums.DateTimeSkipsDeviation
+			g.days[pos].DaySchedules = append(g.days[pos].DaySchedules, GXDLMSDayProfileAction{StartTime: *start,
+				ScriptLogicalName: scriptLogicalName, ScriptSelector: scriptSelector})
+			return g
+		}
+	}
+	g.errs = append(g.errs, fmt.Errorf("%w: day profile %d is not added", dlmserrors.ErrInvalidCalendar, dayID))
+	return g
+}
+
+// Validate validates the calendar.
+// All found errors are returned.
+func (g *GXActivityCalendarBuilder) Validate() error {
+	errs := append([]error{}, g.errs...)
+	if g.Standard == enums.StandardSaudiArabia {
+		if _, err := hex.DecodeString(strings.ReplaceAll(g.Name, " ", "")); err != nil {
+			errs = append(errs, fmt.Errorf("%w: calendar name %q is not a hex string", dlmserrors.ErrInvalidCalendar, g.Name))
+		}
+	}
+	if err := ValidateActivityCalendar(g.seasons, g.weeks, g.days, g.Limits); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Build validates the calendar and returns the season, week and day profile tables.
+func (g *GXActivityCalendarBuilder) Build() ([]GXDLMSSeasonProfile, []GXDLMSWeekProfile, []GXDLMSDayProfile, error) {
+	if err := g.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	days := make([]GXDLMSDayProfile, len(g.days))
+	for pos, it := range g.days {
+		days[pos] = GXDLMSDayProfile{DayID: it.DayID, DaySchedules: append([]GXDLMSDayProfileAction{}, it.DaySchedules...)}
+	}
+	return append([]GXDLMSSeasonProfile{}, g.seasons...), append([]GXDLMSWeekProfile{}, g.weeks...), days, nil
+}
+
+// calendarName returns the calendar name as it's stored to the activity calendar.
+func (g *GXActivityCalendarBuilder) calendarName() string {
+	if g.Standard == enums.StandardSaudiArabia {
+		return strings.ReplaceAll(g.Name, " ", "")
+	}
+	return g.Name
+}
+
+// ApplyPassive validates the calendar and updates the passive calendar of the target.
+// Passive calendar is activated with ActivatePassiveCalendar.
+func (g *GXActivityCalendarBuilder) ApplyPassive(target *GXDLMSActivityCalendar) error {
+	seasons, weeks, days, err := g.Build()
+	if err != nil {
+		return err
+	}
+	target.CalendarNamePassive = g.calendarName()
+	target.SeasonProfilePassive = seasons
+	target.WeekProfileTablePassive = weeks
+	target.DayProfileTablePassive = days
+	return nil
+}
+
+// ApplyActive validates the calendar and updates the active calendar of the target.
+func (g *GXActivityCalendarBuilder) ApplyActive(target *GXDLMSActivityCalendar) error {
+	seasons, weeks, days, err := g.Build()
+	if err != nil {
+		return err
+	}
+	target.CalendarNameActive = g.calendarName()
+	target.SeasonProfileActive = seasons
+	target.WeekProfileTableActive = weeks
+	target.DayProfileTableActive = days
+	return nil
+}
+
+// ValidateActivityCalendar validates the season, week and day profile tables.
+//
+// Seasons must be in start order and the start times can't overlap.
+// Year is the only date field that can be a wildcard and it must be a wildcard
+// in all seasons or in none of them. Week profiles must reference existing day profiles
+// and day actions must be in start order. All found errors are returned.
+func ValidateActivityCalendar(seasons []GXDLMSSeasonProfile, weeks []GXDLMSWeekProfile, days []GXDLMSDayProfile, limits GXActivityCalendarLimits) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s", dlmserrors.ErrInvalidCalendar, fmt.Sprintf(format, args...)))
+	}
+	if limits.Seasons != 0 && len(seasons) > limits.Seasons {
+		add("%d season profiles, meter supports %d", len(seasons), limits.Seasons)
+	}
+	if limits.Weeks != 0 && len(weeks) > limits.Weeks {
+		add("%d week profiles, meter supports %d", len(weeks), limits.Weeks)
+	}
+	if limits.Days != 0 && len(days) > limits.Days {
+		add("%d day profiles, meter supports %d", len(days), limits.Days)
+	}
+	// Day profiles.
+	dayIDs := map[uint8]bool{}
+	for _, day := range days {
+		if dayIDs[day.DayID] {
+			add("day profile %d is defined more than once", day.DayID)
+		}
+		dayIDs[day.DayID] = true
+		if len(day.DaySchedules) == 0 {
+			add("day profile %d has no actions", day.DayID)
+		}
+		if limits.Actions != 0 && len(day.DaySchedules) > limits.Actions {
+			add("day profile %d has %d actions, meter supports %d", day.DayID, len(day.DaySchedules), limits.Actions)
+		}
+		previous := -1
+		for _, action := range day.DaySchedules {
+			t := &action.StartTime
+			if (t.Skip & (enums.DateTimeSkipsHour | enums.DateTimeSkipsMinute)) != 0 {
+				add("day profile %d action start time hour and minute must be specified", day.DayID)
+			}
+			if err := ValidateLogicalName(action.ScriptLogicalName); err != nil {
+				add("day profile %d script logical name %q is invalid", day.DayID, action.ScriptLogicalName)
+			}
+			start := 3600*skipValue(t.Skip, enums.DateTimeSkipsHour, t.Value.Hour()) +
+				60*skipValue(t.Skip, enums.DateTimeSkipsMinute, t.Value.Minute()) +
+				skipValue(t.Skip, enums.DateTimeSkipsSecond, t.Value.Second())
+			if start <= previous {
+				add("day profile %d actions are not in start order", day.DayID)
+			}
+			previous = start
+		}
+	}
+	// Week profiles.
+	for _, week := range weeks {
+		if len(week.Name) == 0 {
+			add("week profile name is empty")
+		}
+		for _, id := range []uint8{week.Monday, week.Tuesday, week.Wednesday, week.Thursday, week.Friday, week.Saturday, week.Sunday} {
+			if !dayIDs[id] {
+				add("week profile %s references missing day profile %d", calendarNameString(week.Name), id)
+			}
+		}
+	}
+	for pos := range weeks {
+		for _, it := range weeks[:pos] {
+			if bytes.Equal(it.Name, weeks[pos].Name) {
+				add("week profile %s is defined more than once", calendarNameString(it.Name))
+				break
+			}
+		}
+	}
+	// Season profiles.
+	var previous *GXDLMSSeasonProfile
+	for pos := range seasons {
+		season := &seasons[pos]
+		name := calendarNameString(season.Name)
+		if len(season.Name) == 0 {
+			add("season profile name is empty")
+		}
+		s := &season.Start
+		if (s.Skip & (enums.DateTimeSkipsMonth | enums.DateTimeSkipsDay)) != 0 {
+			add("season profile %s start month and day must be specified", name)
+		}
+		if (s.Skip & (enums.DateTimeSkipsHour | enums.DateTimeSkipsMinute)) != 0 {
+			add("season profile %s start hour and minute must be specified", name)
+		}
+		if s.Extra != enums.DateTimeExtraInfoNone {
+			add("season profile %s start can't use last day of month or DST wildcards", name)
+		}
+		found := false
+		for _, week := range weeks {
+			if bytes.Equal(week.Name, season.WeekName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("season profile %s references missing week profile %s", name, calendarNameString(season.WeekName))
+		}
+		if previous != nil {
+			if (previous.Start.Skip & enums.DateTimeSkipsYear) != (s.Skip & enums.DateTimeSkipsYear) {
+				add("season profile %s mixes yearly and fixed year start times", name)
+			} else if c := compareSeasonStart(previous, season); c == 0 {
+				add("season profiles %s and %s overlap", calendarNameString(previous.Name), name)
+			} else if c > 0 {
+				add("season profile %s is not in start order", name)
+			}
+		}
+		for _, it := range seasons[:pos] {
+			if bytes.Equal(it.Name, season.Name) {
+				add("season profile %s is defined more than once", name)
+				break
+			}
+		}
+		previous = season
+	}
+	return errors.Join(errs...)
+}
+
+// compareSeasonStart compares the start times of the seasons.
+func compareSeasonStart(a *GXDLMSSeasonProfile, b *GXDLMSSeasonProfile) int {
+	key := func(s *types.GXDateTime) []int {
+		return []int{skipValue(s.Skip, enums.DateTimeSkipsYear, s.Value.Year()),
+			skipValue(s.Skip, enums.DateTimeSkipsMonth, int(s.Value.Month())),
+			skipValue(s.Skip, enums.DateTimeSkipsDay, s.Value.Day()),
+			skipValue(s.Skip, enums.DateTimeSkipsHour, s.Value.Hour()),
+			skipValue(s.Skip, enums.DateTimeSkipsMinute, s.Value.Minute()),
+			skipValue(s.Skip, enums.DateTimeSkipsSecond, s.Value.Second())}
+	}
+	ka := key(&a.Start)
+	kb := key(&b.Start)
+	for pos := range ka {
+		if ka[pos] != kb[pos] {
+			return ka[pos] - kb[pos]
+		}
+	}
+	return 0
+}
+
+// calendarNameString returns the name as a string.
+// Hex is used if the name is not an ASCII string.
+func calendarNameString(value []byte) string {
+	if types.IsAsciiString(value) {
+		return string(value)
+	}
+	return types.ToHex(value, false)
+}
@@ -153,13 +153,10 @@ func (g *GXDLMSActivityCalendar) GetMethodCount() int {
 	return 1
 }
 
+// isSec returns true if the calendar names are handled as octet strings
+// as defined in the Saudi Arabia standard.
 func (g *GXDLMSActivityCalendar) isSec(settings *settings.GXDLMSSettings) bool {
-	/* TODO:
-	if c, ok := g.Parent.parent.(GXDLMSClient); ok {
-		return c.Standard() == enums.StandardSaudiArabia
-	}
-	*/
-	return false
+	return settings != nil && settings.Standard == enums.StandardSaudiArabia
 }
 
 // GetSeasonProfile returns the get season profile bytes.
@@ -36,6 +36,7 @@
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/Gurux/gxdlms-go/dlmserrors"
 	"github.com/Gurux/gxdlms-go/enums"
@@ -52,6 +53,10 @@ type GXDLMSAssociationShortName struct {
 	// Secret used in Authentication
 	Secret []byte
 
+	// Authentication mechanism that the association requires.
+	// Server rejects the AARQ that uses a different mechanism.
+	Authentication enums.Authentication
+
 	// List of available objects in short name referencing.
 	ObjectList GXDLMSObjectCollection
 
@@ -174,11 +179,11 @@ func (g *GXDLMSAssociationShortName) getAccessRights(settings *settings.GXDLMSSe
 		if err != nil {
 			return err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeInt8, e.Index)
+		err = internal.SetData(settings, data, enums.DataTypeInt8, int8(e.Index))
 		if err != nil {
 			return err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeEnum, m)
+		err = internal.SetData(settings, data, enums.DataTypeEnum, uint8(m))
 		if err != nil {
 			return err
 		}
@@ -208,11 +213,11 @@ func (g *GXDLMSAssociationShortName) getAccessRights(settings *settings.GXDLMSSe
 		if err != nil {
 			return err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeInt8, e.Index)
+		err = internal.SetData(settings, data, enums.DataTypeInt8, int8(e.Index))
 		if err != nil {
 			return err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeEnum, m)
+		err = internal.SetData(settings, data, enums.DataTypeEnum, uint8(m))
 		if err != nil {
 			return err
 		}
@@ -254,7 +259,7 @@ func (g *GXDLMSAssociationShortName) getObjects(settings *settings.GXDLMSSetting
 			if err != nil {
 				return nil, err
 			}
-			err = internal.SetData(settings, data, enums.DataTypeUint16, it.Base().ObjectType)
+			err = internal.SetData(settings, data, enums.DataTypeUint16, uint16(it.Base().ObjectType()))
 			if err != nil {
 				return nil, err
 			}
@@ -346,23 +351,53 @@ func (g *GXDLMSAssociationShortName) GetValue(settings *settings.GXDLMSSettings,
 	return nil, nil
 }
 
-func (g *GXDLMSAssociationShortName) updateAccessRights(buff []any) error {
+func (g *GXDLMSAssociationShortName) updateAccessRights(buff types.GXArray) error {
 	for _, it := range buff {
-		access := it.([]any)
-		sn := access[0].(uint16)
-		obj := g.ObjectList.FindBySN(sn)
-		if obj != nil {
-			for _, it2 := range access[1].([]any) {
-				attributeAccess := it2.([]any)
-				id := attributeAccess[0].(int)
-				mode := attributeAccess[1].(uint8)
-				obj.Base().SetAccess(id, enums.AccessMode(mode))
+		access, ok := it.(types.GXStructure)
+		if !ok || len(access) != 3 {
+			return errors.New("Invalid structure format.")
+		}
+		sn, err := toInt16(access[0])
+		if err != nil {
+			return err
+		}
+		obj := g.ObjectList.FindBySN(uint16(sn))
+		if obj == nil {
+			continue
+		}
+		attributes, _ := access[1].(types.GXArray)
+		for _, it2 := range attributes {
+			attributeAccess, ok := it2.(types.GXStructure)
+			if !ok || len(attributeAccess) != 2 {
+				return errors.New("Invalid structure format.")
+			}
+			id, err := toInt8(attributeAccess[0])
+			if err != nil {
+				return err
 			}
-			for _, it2 := range access[2].([]any) {
-				methodAccess := it2.([]any)
-				id := methodAccess[0].(int)
-				mode := methodAccess[1].(uint8)
-				obj.Base().SetMethodAccess(id, enums.MethodAccessMode(mode))
+			mode, err := toEnum(attributeAccess[1])
+			if err != nil {
+				return err
+			}
+			obj.Base().SetAccess(int(id), enums.AccessMode(mode))
+		}
+		methods, _ := access[2].(types.GXArray)
+		for _, it2 := range methods {
+			methodAccess, ok := it2.(types.GXStructure)
+			if !ok || len(methodAccess) != 2 {
+				return errors.New("Invalid structure format.")
+			}
+			id, err := toInt8(methodAccess[0])
+			if err != nil {
+				return err
+			}
+			mode, err := toEnum(methodAccess[1])
+			if err != nil {
+				return err
+			}
+			err = obj.Base().SetMethodAccess(int(id), enums.MethodAccessMode(mode))
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -389,22 +424,35 @@ func (g *GXDLMSAssociationShortName) SetValue(settings *settings.GXDLMSSettings,
 	case 2:
 		g.ObjectList.Clear()
 		if e.Value != nil {
-			for _, it := range e.Value.([]any) {
-				item := it.([]any)
-				sn := item[0].(int16)
-				type_ := (item[1]).(enums.ObjectType)
-				version := item[2].(uint8)
-				ln, err := helpers.ToLogicalName(item[3].([]byte))
+			for _, it := range e.Value.(types.GXArray) {
+				item, ok := it.(types.GXStructure)
+				if !ok || len(item) != 4 {
+					e.Error = enums.ErrorCodeReadWriteDenied
+					return errors.New("Invalid structure format.")
+				}
+				sn, err := toInt16(item[0])
+				if err != nil {
+					return err
+				}
+				type_, err := toUint16(item[1])
+				if err != nil {
+					return err
+				}
+				version, err := toUint8(item[2])
+				if err != nil {
+					return err
+				}
+				ln, err := helpers.ToLogicalName(item[3])
 				if err != nil {
 					e.Error = enums.ErrorCodeReadWriteDenied
 					return err
 				}
 				var obj IGXDLMSBase
-				if settings.Objects != nil {
-					obj = settings.Objects.(*GXDLMSObjectCollection).FindBySN(uint16(sn))
+				if settings != nil && settings.Objects != nil {
+					obj = getObjectCollection(settings.Objects).FindBySN(uint16(sn))
 				}
 				if obj == nil {
-					obj, err = CreateObject(type_, ln, sn)
+					obj, err = CreateObject(enums.ObjectType(type_), ln, sn)
 					if err != nil {
 						e.Error = enums.ErrorCodeReadWriteDenied
 						return err
@@ -427,7 +475,7 @@ func (g *GXDLMSAssociationShortName) SetValue(settings *settings.GXDLMSSettings,
 				}
 			}
 		} else {
-			err = g.updateAccessRights((e.Value).([]any))
+			err = g.updateAccessRights(e.Value.(types.GXArray))
 		}
 	case 4:
 		g.SecuritySetupReference, err = helpers.ToLogicalName(e.Value)
@@ -482,12 +530,23 @@ func (g *GXDLMSAssociationShortName) Invoke(conf *settings.GXDLMSSettings,
 	// Check reply_to_HLS_authentication
 	var err error
 	if e.Index == 8 {
+		// Reply is accepted only when the HLS AARQ of this association is waiting for it.
+		if conf.Authentication <= enums.AuthenticationLow || conf.Authentication != g.Authentication ||
+			(conf.Connected&enums.ConnectionStateDlms) != 0 || len(conf.StoCChallenge()) == 0 {
+			e.Error = enums.ErrorCodeReadWriteDenied
+			return nil, nil
+		}
+		clientChallenge, ok := e.Parameters.([]byte)
+		if !ok {
+			e.Error = enums.ErrorCodeReadWriteDenied
+			return nil, nil
+		}
 		var ic uint32
 		var secret []byte
 		switch conf.Authentication {
 		case enums.AuthenticationHighGMAC:
 			secret = conf.SourceSystemTitle()
-			bb := types.NewGXByteBufferWithData(e.Parameters.([]byte))
+			bb := types.NewGXByteBufferWithData(clientChallenge)
 			_, err := bb.Uint8()
 			if err != nil {
 				return nil, err
@@ -526,8 +585,7 @@ func (g *GXDLMSAssociationShortName) Invoke(conf *settings.GXDLMSSettings,
 		if err != nil {
 			return nil, err
 		}
-		clientChallenge := e.Parameters.([]byte)
-		if !bytes.Equal(serverChallenge, clientChallenge) {
+		if serverChallenge != nil && bytes.Equal(serverChallenge, clientChallenge) {
 			if conf.Authentication == enums.AuthenticationHighGMAC {
 				secret = conf.Cipher.SystemTitle()
 				ic = conf.Cipher.InvocationCounter()
@@ -610,7 +668,7 @@ func (g *GXDLMSAssociationShortName) GetDataType(index int) (enums.DataType, err
 // NewGXDLMSAssociationShortName creates a new association short name object instance.
 //
 // The function validates `ln` before creating the object.
-//`ln` is the Logical Name and `sn` is the Short Name of the object.
+// `ln` is the Logical Name and `sn` is the Short Name of the object.
 func NewGXDLMSAssociationShortName(ln string, sn int16) (*GXDLMSAssociationShortName, error) {
 	err := ValidateLogicalName(ln)
 	if err != nil {
@@ -122,7 +122,25 @@ func (g *GXDLMSCharge) Base() *GXDLMSObject {
 //	settings: DLMS settings.
 //	e: Invoke parameters.
 func (g *GXDLMSCharge) Invoke(settings *settings.GXDLMSSettings, e *internal.ValueEventArgs) ([]byte, error) {
-	e.Error = enums.ErrorCodeReadWriteDenied
+	switch e.Index {
+	case 2:
+		g.UnitChargeActive = g.UnitChargePassive
+		g.UnitChargeActivationTime = types.GXDateTime{}
+	case 4:
+		if v, ok := e.Parameters.(int32); ok {
+			g.TotalAmountRemaining += v
+		} else {
+			e.Error = enums.ErrorCodeReadWriteDenied
+		}
+	case 5:
+		if v, ok := e.Parameters.(int32); ok {
+			g.TotalAmountRemaining = v
+		} else {
+			e.Error = enums.ErrorCodeReadWriteDenied
+		}
+	default:
+		e.Error = enums.ErrorCodeReadWriteDenied
+	}
 	return nil, nil
 }
 
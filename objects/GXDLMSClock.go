@@ -396,12 +396,12 @@ func (g *GXDLMSClock) GetValues() []any {
 
 // AdjustToQuarter returns the sets the meter's time to the nearest (+/-) quarter of an hour value (*:00, *:15, *:30, *:45).
 func (g *GXDLMSClock) AdjustToQuarter(client IGXDLMSClient) ([][]byte, error) {
-	return client.Method(g, 1, 0, enums.DataTypeInt8)
+	return client.Method(g, 1, int8(0), enums.DataTypeInt8)
 }
 
 // AdjustToMeasuringPeriod returns the sets the meter's time to the nearest (+/-) starting point of a measuring period.
 func (g *GXDLMSClock) AdjustToMeasuringPeriod(client IGXDLMSClient) ([][]byte, error) {
-	return client.Method(g, 2, 0, enums.DataTypeInt8)
+	return client.Method(g, 2, int8(0), enums.DataTypeInt8)
 }
 
 // AdjustToMinute returns the sets the meter's time to the nearest minute.
@@ -409,14 +409,14 @@ func (g *GXDLMSClock) AdjustToMeasuringPeriod(client IGXDLMSClient) ([][]byte, e
 // If second_counter higher 30 s, so second_counter is set to 0, and
 // minute_counter and all depending clock values are incremented if necessary.
 func (g *GXDLMSClock) AdjustToMinute(client IGXDLMSClient) ([][]byte, error) {
-	return client.Method(g, 3, 0, enums.DataTypeInt8)
+	return client.Method(g, 3, int8(0), enums.DataTypeInt8)
 }
 
 // AdjustToPresetTime returns the this Method is used in conjunction with the preset_adjusting_time
 // Method. If the meter's time lies between validity_interval_start and
 // validity_interval_end, then time is set to preset_time.
 func (g *GXDLMSClock) AdjustToPresetTime(client IGXDLMSClient) ([][]byte, error) {
-	return client.Method(g, 4, 0, enums.DataTypeInt8)
+	return client.Method(g, 4, int8(0), enums.DataTypeInt8)
 }
 
 // PresetAdjustingTime returns the presets the time to a new value (preset_time) and defines a validity_interval within which the new time can be activated.
@@ -431,27 +431,37 @@ func (g *GXDLMSClock) PresetAdjustingTime(client IGXDLMSClient, presetTime *time
 	if err != nil {
 		return nil, err
 	}
-	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, presetTime)
+	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, presetDateTime(presetTime))
 	if err != nil {
 		return nil, err
 	}
-	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, validityIntervalStart)
+	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, presetDateTime(validityIntervalStart))
 	if err != nil {
 		return nil, err
 	}
-	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, validityIntervalEnd)
+	err = internal.SetData(client.Settings(), &buff, enums.DataTypeOctetString, presetDateTime(validityIntervalEnd))
 	if err != nil {
 		return nil, err
 	}
 	return client.Method(g, 5, buff.Array(), enums.DataTypeArray)
 }
 
+// presetDateTime returns the preset time as date-time. All the fields are skipped if the time is not given.
+func presetDateTime(value *time.Time) types.GXDateTime {
+	if value == nil {
+		return types.GXDateTime{Skip: enums.DateTimeSkipsYear | enums.DateTimeSkipsMonth | enums.DateTimeSkipsDay |
+			enums.DateTimeSkipsDayOfWeek | enums.DateTimeSkipsHour | enums.DateTimeSkipsMinute |
+			enums.DateTimeSkipsSecond | enums.DateTimeSkipsMs | enums.DateTimeSkipsDeviation}
+	}
+	return types.GXDateTime{Value: *value}
+}
+
 // ShiftTime returns the shifts the time by n (-900 &lt;= n &lt;= 900) s.
 func (g *GXDLMSClock) ShiftTime(client IGXDLMSClient, time int) ([][]byte, error) {
 	if time < -900 || time > 900 {
 		return nil, errors.New("Invalid shift time.")
 	}
-	return client.Method(g, 6, time, enums.DataTypeInt16)
+	return client.Method(g, 6, int16(time), enums.DataTypeInt16)
 }
 
 // GetDataType returns the device data type of selected attribute index.
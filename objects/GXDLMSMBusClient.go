@@ -80,6 +80,10 @@ type GXDLMSMBusClient struct {
 	Configuration uint16
 
 	EncryptionKeyStatus enums.MBusEncryptionKeyStatus
+
+	// Encryption key of the M-Bus slave device.
+	// This is not a COSEM attribute. It's used to decrypt the data of the slave device.
+	EncryptionKey []byte
 }
 
 // Base returns the base GXDLMSObject of the object.
@@ -675,6 +679,7 @@ func (g *GXDLMSMBusClient) SendData(client IGXDLMSClient, data []GXMBusClientDat
 //
 //	Generated DLMS data.
 func (g *GXDLMSMBusClient) SetEncryptionKey(client IGXDLMSClient, encryptionKey []byte) ([][]uint8, error) {
+	g.EncryptionKey = encryptionKey
 	bb := types.NewGXByteBuffer()
 	err := bb.SetUint8(enums.DataTypeOctetString)
 	if err != nil {
@@ -114,7 +114,9 @@ func (g *GXDLMSObject) Name() any {
 func (g *GXDLMSObject) GetAttribute(index int) *manufacturersettings.GXDLMSAttributeSettings {
 	att := g.Attributes.Find(index)
 	if att == nil {
-		att = &manufacturersettings.GXDLMSAttributeSettings{Index: index}
+		// Default access is the same as when the attribute settings are not available.
+		att = &manufacturersettings.GXDLMSAttributeSettings{Index: index,
+			Access: enums.AccessModeReadWrite, Access3: enums.AccessMode3Read}
 		g.Attributes = append(g.Attributes, att)
 		// LN is read only.
 		if index == 1 {
@@ -149,6 +151,9 @@ func (g *GXDLMSObject) GetLastReadTime(attributeIndex int) time.Time {
 // attributeIndex: Attribute index.
 // tm: Read time.
 func (g *GXDLMSObject) SetLastReadTime(attributeIndex int, tm time.Time) {
+	if g.readTimes == nil {
+		g.readTimes = make(map[int]time.Time)
+	}
 	g.readTimes[attributeIndex] = tm
 }
 
@@ -147,10 +147,32 @@ func (g *GXDLMSObjectCollection) LoadFromFile(filename string) error {
 
 // LoadFromStream returns new collection.
 func (g *GXDLMSObjectCollection) LoadFromStream(stream *bufio.Reader) error {
+	return g.load(stream, false)
+}
+
+// UpdateFromStream updates the objects of the collection from serialized COSEM objects.
+// Serialized objects that are not in the collection are skipped.
+//
+// Parameters:
+//
+//	stream: Serialized COSEM objects.
+func (g *GXDLMSObjectCollection) UpdateFromStream(stream *bufio.Reader) error {
+	return g.load(stream, true)
+}
+
+// load reads the serialized COSEM objects.
+//
+// Parameters:
+//
+//	stream: Serialized COSEM objects.
+//	update: Are existing objects updated instead of creating a new collection.
+func (g *GXDLMSObjectCollection) load(stream *bufio.Reader, update bool) error {
 	var obj IGXDLMSBase
 	reader := NewGXXmlReaderFromStream(stream)
 	defer reader.Close()
-	g.Clear()
+	if !update {
+		g.Clear()
+	}
 	reader.Objects = g
 	var err error
 	var ot enums.ObjectType
@@ -198,7 +220,9 @@ func (g *GXDLMSObjectCollection) LoadFromStream(stream *bufio.Reader) error {
 					}
 					tmp := reader.Objects.FindByLN(obj.Base().ObjectType(), obj.Base().LogicalName())
 					if tmp == nil {
-						*g = append(*g, obj)
+						if !update {
+							*g = append(*g, obj)
+						}
 					} else {
 						// Version must be updated because component might be added to association view.
 						tmp.Base().Version = obj.Base().Version
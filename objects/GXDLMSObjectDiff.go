@@ -0,0 +1,519 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXDLMSValueChange describes a changed attribute value.
+type GXDLMSValueChange struct {
+	// Attribute index.
+	Index int
+
+	// Attribute name.
+	Name string
+
+	// Value in the source collection.
+	Old any
+
+	// Value in the target collection.
+	New any
+}
+
+// GXDLMSAccessChange describes changed access rights of an attribute or a method.
+type GXDLMSAccessChange struct {
+	// Attribute or method index.
+	Index int
+
+	// Is method access changed.
+	Method bool
+
+	// Access in the source collection.
+	// Value is AccessMode or AccessMode3 for attributes and MethodAccessMode or MethodAccessMode3 for methods.
+	Old any
+
+	// Access in the target collection.
+	New any
+}
+
+// GXDLMSCaptureObjectChange describes changes in the capture object list.
+type GXDLMSCaptureObjectChange struct {
+	// Attribute index of the capture object list.
+	Index int
+
+	// Capture objects that are only in the target collection.
+	Added []string
+
+	// Capture objects that are only in the source collection.
+	Removed []string
+
+	// Capture objects are same, but the order is different.
+	Reordered bool
+}
+
+// GXDLMSObjectDiff contains the differences of one COSEM object.
+type GXDLMSObjectDiff struct {
+	// Object in the source collection.
+	Source IGXDLMSBase
+
+	// Object in the target collection.
+	Target IGXDLMSBase
+
+	// Changed attribute values.
+	Values []GXDLMSValueChange
+
+	// Changed access rights.
+	Access []GXDLMSAccessChange
+
+	// Changed capture object lists.
+	CaptureObjects []GXDLMSCaptureObjectChange
+}
+
+// IsEmpty returns true if objects are equal.
+func (g *GXDLMSObjectDiff) IsEmpty() bool {
+	return len(g.Values) == 0 && len(g.Access) == 0 && len(g.CaptureObjects) == 0
+}
+
+// String returns the differences as text.
+func (g *GXDLMSObjectDiff) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "~ %s %s\n", g.Target.Base().ObjectType(), g.Target.Base().LogicalName())
+	for _, it := range g.Values {
+		fmt.Fprintf(&sb, "    %d %s: %s -> %s\n", it.Index, it.Name, diffValueString(it.Old), diffValueString(it.New))
+	}
+	for _, it := range g.Access {
+		if it.Method {
+			fmt.Fprintf(&sb, "    method %d access: %v -> %v\n", it.Index, it.Old, it.New)
+		} else {
+			fmt.Fprintf(&sb, "    attribute %d access: %v -> %v\n", it.Index, it.Old, it.New)
+		}
+	}
+	for _, it := range g.CaptureObjects {
+		for _, c := range it.Added {
+			fmt.Fprintf(&sb, "    %d capture object added: %s\n", it.Index, c)
+		}
+		for _, c := range it.Removed {
+			fmt.Fprintf(&sb, "    %d capture object removed: %s\n", it.Index, c)
+		}
+		if it.Reordered {
+			fmt.Fprintf(&sb, "    %d capture objects reordered\n", it.Index)
+		}
+	}
+	return sb.String()
+}
+
+// GXDLMSObjectCollectionDiff contains the differences of two object collections.
+type GXDLMSObjectCollectionDiff struct {
+	// Objects that are only in the target collection.
+	Added []IGXDLMSBase
+
+	// Objects that are only in the source collection.
+	Removed []IGXDLMSBase
+
+	// Objects that are in both collections, but are different.
+	Changed []*GXDLMSObjectDiff
+}
+
+// IsEmpty returns true if collections are equal.
+func (g *GXDLMSObjectCollectionDiff) IsEmpty() bool {
+	return len(g.Added) == 0 && len(g.Removed) == 0 && len(g.Changed) == 0
+}
+
+// String returns the differences as text.
+func (g *GXDLMSObjectCollectionDiff) String() string {
+	var sb strings.Builder
+	for _, it := range g.Added {
+		fmt.Fprintf(&sb, "+ %s %s\n", it.Base().ObjectType(), it.Base().LogicalName())
+	}
+	for _, it := range g.Removed {
+		fmt.Fprintf(&sb, "- %s %s\n", it.Base().ObjectType(), it.Base().LogicalName())
+	}
+	for _, it := range g.Changed {
+		sb.WriteString(it.String())
+	}
+	return sb.String()
+}
+
+// GXDLMSObjectComparer compares COSEM objects.
+type GXDLMSObjectComparer struct {
+	// Ignore returns true if the attribute is not compared.
+	// Access rights of the ignored attribute are still compared.
+	Ignore func(target IGXDLMSBase, index int) bool
+
+	// Are access rights compared.
+	CompareAccess bool
+}
+
+// NewGXDLMSObjectComparer creates a comparer that compares all attributes and access rights.
+func NewGXDLMSObjectComparer() *GXDLMSObjectComparer {
+	return &GXDLMSObjectComparer{CompareAccess: true}
+}
+
+// IgnoreDynamicValues returns true for the attributes that change while the meter is running,
+// like register values, clock time and profile generic buffer.
+// It can be used as Ignore function when configuration drift is checked.
+func IgnoreDynamicValues(target IGXDLMSBase, index int) bool {
+	switch target.Base().ObjectType() {
+	case enums.ObjectTypeClock:
+		return index == 2 || index == 4
+	case enums.ObjectTypeRegister:
+		return index == 2
+	case enums.ObjectTypeExtendedRegister:
+		return index == 2 || index == 4 || index == 5
+	case enums.ObjectTypeDemandRegister:
+		return index == 2 || index == 3 || index == 5 || index == 6 || index == 7
+	case enums.ObjectTypeProfileGeneric:
+		return index == 2 || index == 7
+	}
+	return false
+}
+
+// Compare compares two object collections. Objects are matched by object type and logical name.
+//
+// Parameters:
+//
+//	source: Source collection, example the configured profile.
+//	target: Target collection, example objects read from the meter.
+//
+// Returns:
+//
+//	Differences of the collections.
+func (g *GXDLMSObjectComparer) Compare(source GXDLMSObjectCollection, target GXDLMSObjectCollection) *GXDLMSObjectCollectionDiff {
+	ret := &GXDLMSObjectCollectionDiff{}
+	for _, it := range target {
+		if source.FindByLN(it.Base().ObjectType(), it.Base().LogicalName()) == nil {
+			ret.Added = append(ret.Added, it)
+		}
+	}
+	for _, s := range source {
+		t := target.FindByLN(s.Base().ObjectType(), s.Base().LogicalName())
+		if t == nil {
+			ret.Removed = append(ret.Removed, s)
+			continue
+		}
+		diff := g.CompareObject(s, t)
+		if !diff.IsEmpty() {
+			ret.Changed = append(ret.Changed, diff)
+		}
+	}
+	return ret
+}
+
+// CompareObject compares two COSEM objects of the same type.
+//
+// Parameters:
+//
+//	source: Source object.
+//	target: Target object.
+//
+// Returns:
+//
+//	Differences of the objects.
+func (g *GXDLMSObjectComparer) CompareObject(source IGXDLMSBase, target IGXDLMSBase) *GXDLMSObjectDiff {
+	ret := &GXDLMSObjectDiff{Source: source, Target: target}
+	oldValues := source.GetValues()
+	newValues := target.GetValues()
+	names := target.GetNames()
+	// Logical name is used to match the objects and it's not compared.
+	for index := 2; index <= target.GetAttributeCount(); index++ {
+		if g.CompareAccess {
+			if o, n := attributeAccess(source, index), attributeAccess(target, index); o != n {
+				ret.Access = append(ret.Access, GXDLMSAccessChange{Index: index, Old: o, New: n})
+			}
+		}
+		if g.Ignore != nil && g.Ignore(target, index) {
+			continue
+		}
+		if capture, ok := compareCaptureObjects(source, target, index); ok {
+			if capture != nil {
+				ret.CaptureObjects = append(ret.CaptureObjects, *capture)
+			}
+			continue
+		}
+		var o, n any
+		if index <= len(oldValues) {
+			o = oldValues[index-1]
+		}
+		if index <= len(newValues) {
+			n = newValues[index-1]
+		}
+		if !diffValueEqual(reflect.ValueOf(o), reflect.ValueOf(n)) {
+			name := ""
+			if index <= len(names) {
+				name = names[index-1]
+			}
+			ret.Values = append(ret.Values, GXDLMSValueChange{Index: index, Name: name, Old: o, New: n})
+		}
+	}
+	if g.CompareAccess {
+		for index := 1; index <= target.GetMethodCount(); index++ {
+			if o, n := methodAccess(source, index), methodAccess(target, index); o != n {
+				ret.Access = append(ret.Access, GXDLMSAccessChange{Index: index, Method: true, Old: o, New: n})
+			}
+		}
+	}
+	return ret
+}
+
+// Compare compares the collection to the target collection.
+// All attributes and access rights are compared.
+//
+// Parameters:
+//
+//	target: Target collection.
+//
+// Returns:
+//
+//	Differences of the collections.
+func (c GXDLMSObjectCollection) Compare(target GXDLMSObjectCollection) *GXDLMSObjectCollectionDiff {
+	return NewGXDLMSObjectComparer().Compare(c, target)
+}
+
+// attributeAccess returns the attribute access that is in use.
+// Association LN version 3 uses AccessMode3 and older versions AccessMode.
+func attributeAccess(target IGXDLMSBase, index int) any {
+	if access := target.Base().GetAccess(index); access != enums.AccessModeNoAccess {
+		return access
+	}
+	return target.Base().GetAccess3(index)
+}
+
+// methodAccess returns the method access that is in use.
+func methodAccess(target IGXDLMSBase, index int) any {
+	if access := target.Base().GetMethodAccess(index); access != enums.MethodAccessModeNoAccess {
+		return access
+	}
+	return target.Base().GetMethodAccess3(index)
+}
+
+// compareCaptureObjects compares capture object lists.
+// Returns false if the attribute is not a capture object list.
+func compareCaptureObjects(source IGXDLMSBase, target IGXDLMSBase, index int) (*GXDLMSCaptureObjectChange, bool) {
+	var o, n []string
+	switch s := source.(type) {
+	case *GXDLMSProfileGeneric:
+		if index != 3 {
+			return nil, false
+		}
+		for _, it := range s.CaptureObjects {
+			o = append(o, captureObjectString(it.Key, it.Value))
+		}
+		for _, it := range target.(*GXDLMSProfileGeneric).CaptureObjects {
+			n = append(n, captureObjectString(it.Key, it.Value))
+		}
+	case *GXDLMSPushSetup:
+		if index != 2 {
+			return nil, false
+		}
+		for _, it := range s.PushObjectList {
+			o = append(o, captureObjectString(it.Key, &it.Value))
+		}
+		for _, it := range target.(*GXDLMSPushSetup).PushObjectList {
+			n = append(n, captureObjectString(it.Key, &it.Value))
+		}
+	default:
+		return nil, false
+	}
+	ret := &GXDLMSCaptureObjectChange{Index: index}
+	ret.Added = stringsMissing(n, o)
+	ret.Removed = stringsMissing(o, n)
+	if len(ret.Added) == 0 && len(ret.Removed) == 0 {
+		if strings.Join(o, ",") == strings.Join(n, ",") {
+			return nil, true
+		}
+		ret.Reordered = true
+	}
+	return ret, true
+}
+
+func captureObjectString(target IGXDLMSBase, co *GXDLMSCaptureObject) string {
+	if target == nil {
+		return ""
+	}
+	if co == nil {
+		return fmt.Sprintf("%s %s", target.Base().ObjectType(), target.Base().LogicalName())
+	}
+	return fmt.Sprintf("%s %s:%d:%d", target.Base().ObjectType(), target.Base().LogicalName(), co.AttributeIndex, co.DataIndex)
+}
+
+// stringsMissing returns items from a that are not in b.
+func stringsMissing(a []string, b []string) []string {
+	var ret []string
+	for _, it := range a {
+		found := false
+		for _, it2 := range b {
+			if it == it2 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ret = append(ret, it)
+		}
+	}
+	return ret
+}
+
+// diffValueEqual compares attribute values.
+// Numbers are compared by value, COSEM objects by type and logical name and times by instant.
+func diffValueEqual(a reflect.Value, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return diffIsEmpty(a) && diffIsEmpty(b)
+	}
+	if a.Kind() == reflect.Interface {
+		return diffValueEqual(a.Elem(), b)
+	}
+	if b.Kind() == reflect.Interface {
+		return diffValueEqual(a, b.Elem())
+	}
+	if a.CanInterface() && b.CanInterface() {
+		if o1, ok := a.Interface().(IGXDLMSBase); ok {
+			o2, ok := b.Interface().(IGXDLMSBase)
+			if !ok || diffIsEmpty(a) || diffIsEmpty(b) {
+				return ok && diffIsEmpty(a) == diffIsEmpty(b)
+			}
+			return o1.Base().ObjectType() == o2.Base().ObjectType() && o1.Base().LogicalName() == o2.Base().LogicalName()
+		}
+		if t1, ok := a.Interface().(time.Time); ok {
+			t2, ok := b.Interface().(time.Time)
+			return ok && t1.Equal(t2)
+		}
+		if b1, ok := a.Interface().([]byte); ok {
+			b2, ok := b.Interface().([]byte)
+			return ok && bytes.Equal(b1, b2)
+		}
+		if v1, err := monitorValue(a.Interface()); err == nil && a.Kind() != reflect.Bool {
+			v2, err := monitorValue(b.Interface())
+			return err == nil && v1 == v2
+		}
+	}
+	if a.Kind() != b.Kind() {
+		return diffIsEmpty(a) && diffIsEmpty(b)
+	}
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return diffValueEqual(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for pos := 0; pos < a.Len(); pos++ {
+			if !diffValueEqual(a.Index(pos), b.Index(pos)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, k := range a.MapKeys() {
+			if !diffValueEqual(a.MapIndex(k), b.MapIndex(k)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() != b.Type() {
+			return false
+		}
+		for pos := 0; pos < a.NumField(); pos++ {
+			// Unexported fields are compared as text if the type doesn't have exported fields.
+			if !a.Type().Field(pos).IsExported() {
+				continue
+			}
+			if !diffValueEqual(a.Field(pos), b.Field(pos)) {
+				return false
+			}
+		}
+		if !diffHasExportedFields(a.Type()) {
+			return a.CanInterface() && b.CanInterface() && fmt.Sprint(a.Interface()) == fmt.Sprint(b.Interface())
+		}
+		return true
+	case reflect.Func, reflect.Chan:
+		return true
+	}
+	if a.CanInterface() && b.CanInterface() {
+		return a.Interface() == b.Interface()
+	}
+	return false
+}
+
+func diffHasExportedFields(t reflect.Type) bool {
+	for pos := 0; pos < t.NumField(); pos++ {
+		if t.Field(pos).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// diffIsEmpty returns true if value is nil or an empty collection.
+func diffIsEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+func diffValueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "<nil>"
+	case []byte:
+		return types.ToHex(v, true)
+	case IGXDLMSBase:
+		return fmt.Sprintf("%s %s", v.Base().ObjectType(), v.Base().LogicalName())
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -86,6 +86,9 @@ type GXDLMSProfileGeneric struct {
 
 	// Maximum Entries (rows) count.
 	ProfileEntries uint32
+
+	// Capture times that are calculated from the capture period when the buffer is read.
+	filledTimes map[time.Time]bool
 }
 
 func getObjects(settings *settings.GXDLMSSettings) *GXDLMSObjectCollection {
@@ -98,9 +101,47 @@ func (g *GXDLMSProfileGeneric) Base() *GXDLMSObject {
 	return &g.GXDLMSObject
 }
 
-// capture returns the copies the values of the objects to capture
-// into the buffer by reading capture objects.
-func (g *GXDLMSProfileGeneric) capture(server *internal.IGXDLMSServer, isInvoke bool) {
+// CaptureRow copies the current values of the capture objects into the buffer.
+// This is used on the server side. The oldest row is removed when the buffer is full.
+//
+// Returns:
+//
+//	Captured row.
+func (g *GXDLMSProfileGeneric) CaptureRow() ([]any, error) {
+	row := make([]any, len(g.CaptureObjects))
+	for pos, it := range g.CaptureObjects {
+		if it.Value.AttributeIndex == 0 {
+			row[pos] = it.Key
+			continue
+		}
+		values := it.Key.GetValues()
+		if it.Value.AttributeIndex > len(values) {
+			return nil, errors.New("Invalid attribute index.")
+		}
+		value := values[it.Value.AttributeIndex-1]
+		if it.Value.DataIndex != 0 {
+			var items []any
+			switch v := value.(type) {
+			case types.GXStructure:
+				items = v
+			case types.GXArray:
+				items = v
+			case []any:
+				items = v
+			}
+			if int(it.Value.DataIndex) > len(items) {
+				return nil, errors.New("Invalid data index.")
+			}
+			value = items[it.Value.DataIndex-1]
+		}
+		row[pos] = value
+	}
+	if g.ProfileEntries != 0 && len(g.Buffer) >= int(g.ProfileEntries) {
+		g.Buffer = g.Buffer[len(g.Buffer)-int(g.ProfileEntries)+1:]
+	}
+	g.Buffer = append(g.Buffer, row)
+	g.EntriesInUse = uint32(len(g.Buffer))
+	return row, nil
 }
 
 // Invoke returns the invokes method.
@@ -112,9 +153,10 @@ func (g *GXDLMSProfileGeneric) capture(server *internal.IGXDLMSServer, isInvoke
 func (g *GXDLMSProfileGeneric) Invoke(settings *settings.GXDLMSSettings, e *internal.ValueEventArgs) ([]byte, error) {
 	switch e.Index {
 	case 1:
-		//TODO: g.reset()
+		g.reset()
 	case 2:
-		//TODO: g.capture(e.Server, true)
+		_, err := g.CaptureRow()
+		return nil, err
 	default:
 		e.Error = enums.ErrorCodeReadWriteDenied
 	}
@@ -217,13 +259,22 @@ func (g *GXDLMSProfileGeneric) GetData(settings *settings.GXDLMSSettings,
 	columnStart := uint16(1)
 	columnEnd := uint16(0)
 	if e.Selector == 2 {
-		arr := e.Parameters.([]any)
-		columnStart = arr[2].(uint16)
-		columnEnd = arr[3].(uint16)
+		arr, ok := e.Parameters.(types.GXStructure)
+		if !ok || len(arr) < 4 {
+			return nil, errors.New("Invalid structure format.")
+		}
+		columnStart, err = toUint16(arr[2])
+		if err != nil {
+			return nil, err
+		}
+		columnEnd, err = toUint16(arr[3])
+		if err != nil {
+			return nil, err
+		}
 	}
 	if columnStart > 1 || columnEnd != 0 {
 		pos := uint16(1)
-		cols := []types.GXKeyValuePair[IGXDLMSBase, *GXDLMSCaptureObject]{}
+		cols = []types.GXKeyValuePair[IGXDLMSBase, *GXDLMSCaptureObject]{}
 		for _, it := range g.CaptureObjects {
 			if !(pos < columnStart || pos > columnEnd) {
 				cols = append(cols, it)
@@ -331,10 +382,89 @@ func (g *GXDLMSProfileGeneric) getProfileGenericData(settings *settings.GXDLMSSe
 	if e.Selector == 0 || e.Parameters == nil || e.RowEndIndex != 0 {
 		return g.GetData(settings, e, g.Buffer, columns)
 	}
+	arr, ok := e.Parameters.(types.GXStructure)
+	if !ok || len(arr) < 2 {
+		return nil, errors.New("Invalid structure format.")
+	}
 	table := [][]any{}
+	if e.Selector == 1 {
+		// Read by range.
+		if len(arr) < 3 {
+			return nil, errors.New("Invalid structure format.")
+		}
+		start, err := profileRangeTime(settings, arr[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := profileRangeTime(settings, arr[2])
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range g.Buffer {
+			if len(row) == 0 {
+				continue
+			}
+			tm, ok := profileRowTime(row[0])
+			if !ok {
+				continue
+			}
+			if !tm.Before(start) && !tm.After(end) {
+				table = append(table, row)
+			}
+		}
+	} else if e.Selector == 2 {
+		// Read by entry.
+		index, err := toUint32(arr[0])
+		if err != nil {
+			return nil, err
+		}
+		count, err := toUint32(arr[1])
+		if err != nil {
+			return nil, err
+		}
+		if index > 0 {
+			index--
+		}
+		if count == 0 || int(count) > len(g.Buffer) {
+			count = uint32(len(g.Buffer))
+		}
+		for pos := index; pos < count; pos++ {
+			table = append(table, g.Buffer[pos])
+		}
+	}
 	return g.GetData(settings, e, table, columns)
 }
 
+// profileRangeTime converts range selector start or end time.
+func profileRangeTime(settings *settings.GXDLMSSettings, value any) (time.Time, error) {
+	if v, ok := value.([]byte); ok {
+		tmp, err := internal.ChangeTypeFromByteArray(settings, v, enums.DataTypeDateTime)
+		if err != nil {
+			return time.Time{}, err
+		}
+		value = tmp
+	}
+	if tm, ok := profileRowTime(value); ok {
+		return tm, nil
+	}
+	return time.Time{}, errors.New("Invalid structure format.")
+}
+
+// profileRowTime returns the time of the capture time column.
+func profileRowTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case types.GXDateTime:
+		return v.Value, true
+	case *types.GXDateTime:
+		if v != nil {
+			return v.Value, true
+		}
+	case time.Time:
+		return v, true
+	}
+	return time.Time{}, false
+}
+
 // GetColumns returns the captured objects.
 //
 // Parameters:
@@ -363,7 +493,7 @@ func (g *GXDLMSProfileGeneric) getColumns(settings *settings.GXDLMSSettings) ([]
 		if err != nil {
 			return nil, err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeUint16, it.Key.Base().ObjectType())
+		err = internal.SetData(settings, data, enums.DataTypeUint16, uint16(it.Key.Base().ObjectType()))
 		if err != nil {
 			return nil, err
 		}
@@ -371,7 +501,7 @@ func (g *GXDLMSProfileGeneric) getColumns(settings *settings.GXDLMSSettings) ([]
 		if err != nil {
 			return nil, err
 		}
-		err = internal.SetData(settings, data, enums.DataTypeInt8, it.Value.AttributeIndex)
+		err = internal.SetData(settings, data, enums.DataTypeInt8, int8(it.Value.AttributeIndex))
 		if err != nil {
 			return nil, err
 		}
@@ -439,7 +569,7 @@ func (g *GXDLMSProfileGeneric) GetValue(settings *settings.GXDLMSSettings, e *in
 				return nil, err
 			}
 		} else {
-			err = internal.SetData(settings, data, enums.DataTypeUint16, g.SortObject.Base().ObjectType())
+			err = internal.SetData(settings, data, enums.DataTypeUint16, uint16(g.SortObject.Base().ObjectType()))
 			if err != nil {
 				return nil, err
 			}
@@ -451,7 +581,7 @@ func (g *GXDLMSProfileGeneric) GetValue(settings *settings.GXDLMSSettings, e *in
 			if err != nil {
 				return nil, err
 			}
-			err = internal.SetData(settings, data, enums.DataTypeInt8, g.SortAttributeIndex)
+			err = internal.SetData(settings, data, enums.DataTypeInt8, int8(g.SortAttributeIndex))
 			if err != nil {
 				return nil, err
 			}
@@ -485,7 +615,16 @@ func (g *GXDLMSProfileGeneric) setBuffer(settings *settings.GXDLMSSettings,
 	if e.Value != nil {
 		var index2 int
 		var lastDate time.Time
-		for _, tmp := range e.Value.(types.GXArray) {
+		rows, ok := e.Value.(types.GXArray)
+		if !ok {
+			// Buffer can be sent as compact array.
+			if v, ok := e.Value.(types.GXCompactArray); ok {
+				rows = v.Values
+			} else {
+				return errors.New("Invalid buffer value.")
+			}
+		}
+		for _, tmp := range rows {
 			row := tmp.(types.GXStructure)
 			if len(cols) != 0 {
 				if len(row) != len(cols) {
@@ -512,19 +651,22 @@ func (g *GXDLMSProfileGeneric) setBuffer(settings *settings.GXDLMSSettings,
 							}
 							if dt, ok := row[pos].(types.GXDateTime); ok {
 								lastDate = dt.Value
+								delete(g.filledTimes, lastDate)
 							}
 						}
 					} else if type_ == enums.DataTypeDateTime && row[pos] == nil && g.CapturePeriod != 0 {
-						if !lastDate.IsZero() && len(g.Buffer) != 0 {
-							lastDate = g.Buffer[len(g.Buffer)-1][pos].(types.GXDateTime).Value
+						if lastDate.IsZero() && len(g.Buffer) != 0 {
+							if dt, ok := g.Buffer[len(g.Buffer)-1][pos].(types.GXDateTime); ok {
+								lastDate = dt.Value
+							}
 						}
 						if !lastDate.IsZero() {
-							if g.SortMethod == enums.SortMethodFiFo || g.SortMethod == enums.SortMethodSmallest {
-								lastDate = lastDate.Add(time.Duration(g.CapturePeriod) * time.Second)
-							} else {
-								lastDate = lastDate.Add(-time.Duration(g.CapturePeriod) * time.Second)
-							}
+							lastDate = g.nextCaptureTime(lastDate)
 							row[pos] = types.GXDateTime{Value: lastDate}
+							if g.filledTimes == nil {
+								g.filledTimes = make(map[time.Time]bool)
+							}
+							g.filledTimes[lastDate] = true
 						}
 					} else if type_ == enums.DataTypeDateTime {
 						if _, ok := row[pos].(uint32); ok {
@@ -538,6 +680,11 @@ func (g *GXDLMSProfileGeneric) setBuffer(settings *settings.GXDLMSSettings,
 						if scaler != 1 {
 							row[pos] = internal.AnyToDouble(row[pos]) * scaler
 						}
+					} else if er, ok := cols[pos].Key.(*GXDLMSExtendedRegister); ok && index2 == 2 {
+						scaler := er.Scaler()
+						if scaler != 1 {
+							row[pos] = internal.AnyToDouble(row[pos]) * scaler
+						}
 					} else if dr, ok := cols[pos].Key.(*GXDLMSDemandRegister); ok && (index2 == 2 || index2 == 3) {
 						scaler := dr.Scaler()
 						if scaler != 1 {
@@ -547,7 +694,7 @@ func (g *GXDLMSProfileGeneric) setBuffer(settings *settings.GXDLMSSettings,
 						v := internal.NewValueEventArgs3(r, 3, 0, nil)
 						v.Value = row[pos]
 						r.SetValue(nil, v)
-						row[pos] = []any{r.Scaler, r.Unit}
+						row[pos] = []any{r.Scaler(), r.Unit}
 					}
 				}
 				g.Buffer = append(g.Buffer, row)
@@ -560,6 +707,19 @@ func (g *GXDLMSProfileGeneric) setBuffer(settings *settings.GXDLMSSettings,
 	return nil
 }
 
+// nextCaptureTime returns the capture time of the next row when the time is not sent.
+func (g *GXDLMSProfileGeneric) nextCaptureTime(last time.Time) time.Time {
+	period := time.Duration(g.CapturePeriod) * time.Second
+	// Some meters are returning 0 if capture period is one hour.
+	if period == 0 {
+		period = time.Hour
+	}
+	if g.SortMethod == enums.SortMethodFiFo || g.SortMethod == enums.SortMethodSmallest {
+		return last.Add(period)
+	}
+	return last.Add(-period)
+}
+
 func (g *GXDLMSProfileGeneric) setCaptureObjects(parent any,
 	settings *settings.GXDLMSSettings,
 	list *[]types.GXKeyValuePair[IGXDLMSBase, *GXDLMSCaptureObject],
@@ -705,6 +865,7 @@ func (g *GXDLMSProfileGeneric) SetValue(settings *settings.GXDLMSSettings, e *in
 func (g *GXDLMSProfileGeneric) Load(reader *GXXmlReader) error {
 	var err error
 	g.Buffer = g.Buffer[:0]
+	g.filledTimes = nil
 	if ret, err := reader.IsStartElementNamed("Buffer", true); ret && err == nil {
 		for {
 			ret, err = reader.IsStartElementNamed("Row", true)
@@ -810,12 +971,7 @@ func (g *GXDLMSProfileGeneric) Save(writer *GXXmlWriter) error {
 	var err error
 	writer.WriteStartElement("Buffer")
 	if g.Buffer != nil {
-		var lastdt *time.Time
-		add := g.CapturePeriod
-		// Some meters are returning 0 if capture period is one hour.
-		if add == 0 {
-			add = 60
-		}
+		var lastdt time.Time
 		// Get data types.
 		list := []enums.DataType{}
 		if len(g.CaptureObjects) != 0 {
@@ -839,15 +995,17 @@ func (g *GXDLMSProfileGeneric) Save(writer *GXXmlWriter) error {
 				if len(g.CaptureObjects) > pos {
 					c := g.CaptureObjects[pos]
 					pos++
-					if v, ok := c.Key.(*GXDLMSClock); ok && c.Value.AttributeIndex == 2 {
-						if it != nil {
-							lastdt = &v.Time.Value
-						} else if lastdt != nil {
-							//TODO: lastdt = types.NewGXDateTime(lastdt.Value.AddMinutes(add))
-							//TODO: writer.WriteElementObject("Cell", lastdt)
+					if _, ok := c.Key.(*GXDLMSClock); ok && c.Value.AttributeIndex == 2 {
+						if dt, ok := it.(types.GXDateTime); ok {
+							lastdt = dt.Value
+						} else if it == nil && !lastdt.IsZero() {
+							// Compressed time is calculated from the capture period.
+							lastdt = g.nextCaptureTime(lastdt)
+							writer.WriteElementObject("Cell", types.GXDateTime{Value: lastdt}, enums.DataTypeDateTime, enums.DataTypeDateTime)
 							continue
-						} else {
+						} else if it == nil {
 							writer.WriteElementObject("Cell", nil, enums.DataTypeDateTime, enums.DataTypeDateTime)
+							continue
 						}
 					}
 				}
@@ -968,6 +1126,9 @@ func (g *GXDLMSProfileGeneric) Capture(client IGXDLMSClient) ([][]byte, error) {
 
 // Reset returns the clears the buffer.
 func (g *GXDLMSProfileGeneric) reset() {
+	g.Buffer = g.Buffer[:0]
+	g.filledTimes = nil
+	g.EntriesInUse = 0
 }
 
 // GetSelectedColumns returns the get selected columns from parameters.
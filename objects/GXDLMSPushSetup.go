@@ -512,14 +512,14 @@ func (g *GXDLMSPushSetup) GetValue(settings *settings.GXDLMSSettings, e *interna
 				if err := buff.SetUint8(1); err != nil {
 					return nil, err
 				}
-				if err := internal.SetData(settings, buff, enums.DataTypeEnum, it.KeyInfo.IdentifiedKey.KeyType); err != nil {
+				if err := internal.SetData(settings, buff, enums.DataTypeEnum, uint8(it.KeyInfo.IdentifiedKey.KeyType)); err != nil {
 					return nil, err
 				}
 			case enums.DataProtectionKeyTypeWrapped:
 				if err := buff.SetUint8(2); err != nil {
 					return nil, err
 				}
-				if err := internal.SetData(settings, buff, enums.DataTypeEnum, it.KeyInfo.WrappedKey.KeyType); err != nil {
+				if err := internal.SetData(settings, buff, enums.DataTypeEnum, uint8(it.KeyInfo.WrappedKey.KeyType)); err != nil {
 					return nil, err
 				}
 				if err := internal.SetData(settings, buff, enums.DataTypeOctetString, it.KeyInfo.WrappedKey.Key); err != nil {
@@ -290,7 +290,7 @@ func (g *GXDLMSRegister) Reset(client IGXDLMSClient) ([][]byte, error) {
 //	Returns:
 //	    Collection of COSEM object values.
 func (g *GXDLMSRegister) GetValues() []any {
-	return []any{g.LogicalName(), g.Value, types.GXStructure{g.Scaler, g.Unit}}
+	return []any{g.LogicalName(), g.Value, types.GXStructure{g.Scaler(), g.Unit}}
 }
 
 // IsRead returns the is attribute read.
@@ -91,7 +91,14 @@ func (g *GXDLMSTokenGateway) Base() *GXDLMSObject {
 //	settings: DLMS settings.
 //	e: Invoke parameters.
 func (g *GXDLMSTokenGateway) Invoke(settings *settings.GXDLMSSettings, e *internal.ValueEventArgs) ([]byte, error) {
-	e.Error = enums.ErrorCodeReadWriteDenied
+	if v, ok := e.Parameters.([]byte); ok && e.Index == 1 {
+		// Token is processed and time stamped by the prepayment engine.
+		g.Token = v
+		g.Time = types.GXDateTime{}
+		g.StatusCode = enums.TokenStatusCodeTokenReceived
+	} else {
+		e.Error = enums.ErrorCodeReadWriteDenied
+	}
 	return nil, nil
 }
 
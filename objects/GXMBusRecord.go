@@ -0,0 +1,450 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXMBusRecord is a data record of the M-Bus application layer defined in EN 13757-3.
+type GXMBusRecord struct {
+	// Data information block (DIF and DIFE).
+	DataInformation []byte
+	// Value information block (VIF and VIFE).
+	ValueInformation []byte
+	// Function field.
+	Function enums.MBusFunction
+	// Storage number.
+	StorageNumber uint64
+	// Tariff.
+	Tariff uint32
+	// Sub unit.
+	SubUnit uint16
+	// Value. Integers are int64, BCD values are int64, dates are GXDateTime,
+	// and strings are string. Unknown data is returned as a byte array.
+	Value any
+	// Scaler as power of 10.
+	Scaler int8
+	// Unit.
+	Unit enums.Unit
+	// Description of the quantity.
+	Description string
+}
+
+// ScaledValue returns the value multiplied by the scaler.
+func (g *GXMBusRecord) ScaledValue() (float64, error) {
+	value, err := monitorValue(g.Value)
+	if err != nil {
+		return 0, err
+	}
+	return value * math.Pow10(int(g.Scaler)), nil
+}
+
+// String returns the record as a string.
+func (g *GXMBusRecord) String() string {
+	value := fmt.Sprint(g.Value)
+	if v, ok := g.Value.([]byte); ok {
+		value = types.ToHex(v, true)
+	} else if _, err := monitorValue(g.Value); err == nil && g.Scaler != 0 {
+		tmp, _ := g.ScaledValue()
+		value = fmt.Sprint(tmp)
+	}
+	return fmt.Sprintf("%s %s %s Storage: %d Tariff: %d SubUnit: %d", g.Description, value, g.Unit, g.StorageNumber, g.Tariff, g.SubUnit)
+}
+
+// MBusRecordsDecode decodes the data records of the M-Bus application layer.
+//
+// Idle fillers are skipped. Manufacturer specific data is returned
+// as the last record with a byte array value.
+func MBusRecordsDecode(data []byte) ([]GXMBusRecord, error) {
+	var list []GXMBusRecord
+	pos := 0
+	for pos < len(data) {
+		dif := data[pos]
+		if dif == 0x2F {
+			// Idle filler.
+			pos++
+			continue
+		}
+		if dif == 0x0F || dif == 0x1F {
+			list = append(list, GXMBusRecord{DataInformation: []byte{dif}, Value: data[pos+1:],
+				Description: "Manufacturer specific"})
+			break
+		}
+		item := GXMBusRecord{}
+		var err error
+		pos, err = item.decodeInformation(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos, err = item.decodeValue(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// MBusRecordDefinition returns the record definition from the data information and value information blocks.
+// Value of the returned record is nil.
+func MBusRecordDefinition(dataInformation []byte, valueInformation []byte) (*GXMBusRecord, error) {
+	item := &GXMBusRecord{}
+	data := append(append([]byte{}, dataInformation...), valueInformation...)
+	pos, err := item.decodeInformation(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pos != len(data) {
+		return nil, errors.New("invalid M-Bus record definition")
+	}
+	return item, nil
+}
+
+// decodeInformation decodes the data information and value information blocks.
+func (g *GXMBusRecord) decodeInformation(data []byte, pos int) (int, error) {
+	start := pos
+	dif := data[pos]
+	pos++
+	g.Function = enums.MBusFunction((dif >> 4) & 3)
+	g.StorageNumber = uint64(dif>>6) & 1
+	for cnt := 0; (data[pos-1] & 0x80) != 0; cnt++ {
+		if pos == len(data) || cnt == 10 {
+			return 0, errors.New("invalid M-Bus data information block")
+		}
+		dife := data[pos]
+		pos++
+		g.StorageNumber |= uint64(dife&0x0F) << (1 + 4*cnt)
+		g.Tariff |= uint32((dife>>4)&3) << (2 * cnt)
+		g.SubUnit |= uint16((dife>>6)&1) << cnt
+	}
+	g.DataInformation = data[start:pos]
+	if pos == len(data) {
+		return 0, errors.New("M-Bus value information block is missing")
+	}
+	start = pos
+	vif := data[pos]
+	pos++
+	plainText := false
+	switch vif & 0x7F {
+	case 0x7B, 0x7D:
+		// Extension tables.
+		if pos == len(data) {
+			return 0, errors.New("invalid M-Bus value information block")
+		}
+		g.extension(vif&0x7F, data[pos])
+		pos++
+	case 0x7C:
+		// Plain text VIF. Text follows the VIFE bytes.
+		plainText = true
+		g.Unit = enums.UnitOtherUnit
+	default:
+		g.primary(vif & 0x7F)
+	}
+	// Combinable VIFE.
+	for (data[pos-1] & 0x80) != 0 {
+		if pos == len(data) {
+			return 0, errors.New("invalid M-Bus value information block")
+		}
+		vife := data[pos] & 0x7F
+		pos++
+		if vife >= 0x70 && vife <= 0x77 {
+			// Multiplicative correction factor.
+			g.Scaler += int8(vife&0x7) - 6
+		} else if vife == 0x7D {
+			g.Scaler += 3
+		}
+	}
+	if plainText {
+		if pos == len(data) || pos+1+int(data[pos]) > len(data) {
+			return 0, errors.New("invalid M-Bus plain text unit")
+		}
+		g.Description = reverseString(data[pos+1 : pos+1+int(data[pos])])
+		pos += 1 + int(data[pos])
+	}
+	g.ValueInformation = data[start:pos]
+	return pos, nil
+}
+
+// timeUnit returns the time unit of the duration VIF.
+func timeUnit(value byte) enums.Unit {
+	switch value & 3 {
+	case 0:
+		return enums.UnitSecond
+	case 1:
+		return enums.UnitMinute
+	case 2:
+		return enums.UnitHour
+	}
+	return enums.UnitDay
+}
+
+// primary decodes the primary VIF.
+func (g *GXMBusRecord) primary(vif byte) {
+	n := int8(vif & 7)
+	nn := int8(vif & 3)
+	switch {
+	case vif <= 0x07:
+		g.Description, g.Unit, g.Scaler = "Energy", enums.UnitActiveEnergy, n-3
+	case vif <= 0x0F:
+		g.Description, g.Unit, g.Scaler = "Energy", enums.UnitEnergyJoule, n
+	case vif <= 0x17:
+		g.Description, g.Unit, g.Scaler = "Volume", enums.UnitVolumeCubicMeter, n-6
+	case vif <= 0x1F:
+		g.Description, g.Unit, g.Scaler = "Mass", enums.UnitMassKg, n-3
+	case vif <= 0x23:
+		g.Description, g.Unit = "On time", timeUnit(vif)
+	case vif <= 0x27:
+		g.Description, g.Unit = "Operating time", timeUnit(vif)
+	case vif <= 0x2F:
+		g.Description, g.Unit, g.Scaler = "Power", enums.UnitActivePower, n-3
+	case vif <= 0x37:
+		g.Description, g.Unit, g.Scaler = "Power", enums.UnitThermalPower, n
+	case vif <= 0x3F:
+		g.Description, g.Unit, g.Scaler = "Volume flow", enums.UnitVolumeFluxHour, n-6
+	case vif <= 0x47:
+		g.Description, g.Unit, g.Scaler = "Volume flow m3/min", enums.UnitOtherUnit, n-7
+	case vif <= 0x4F:
+		g.Description, g.Unit, g.Scaler = "Volume flow m3/s", enums.UnitOtherUnit, n-9
+	case vif <= 0x57:
+		g.Description, g.Unit, g.Scaler = "Mass flow kg/h", enums.UnitOtherUnit, n-3
+	case vif <= 0x5B:
+		g.Description, g.Unit, g.Scaler = "Flow temperature", enums.UnitTemperature, nn-3
+	case vif <= 0x5F:
+		g.Description, g.Unit, g.Scaler = "Return temperature", enums.UnitTemperature, nn-3
+	case vif <= 0x63:
+		g.Description, g.Unit, g.Scaler = "Temperature difference", enums.UnitKelvin, nn-3
+	case vif <= 0x67:
+		g.Description, g.Unit, g.Scaler = "External temperature", enums.UnitTemperature, nn-3
+	case vif <= 0x6B:
+		g.Description, g.Unit, g.Scaler = "Pressure", enums.UnitPressureBar, nn-3
+	case vif == 0x6C:
+		g.Description = "Date"
+	case vif == 0x6D:
+		g.Description = "Date time"
+	case vif == 0x6E:
+		g.Description = "Units for H.C.A."
+	case vif >= 0x70 && vif <= 0x73:
+		g.Description, g.Unit = "Averaging duration", timeUnit(vif)
+	case vif >= 0x74 && vif <= 0x77:
+		g.Description, g.Unit = "Actuality duration", timeUnit(vif)
+	case vif == 0x78:
+		g.Description = "Fabrication number"
+	case vif == 0x79:
+		g.Description = "Enhanced identification"
+	case vif == 0x7A:
+		g.Description = "Bus address"
+	case vif == 0x7E:
+		g.Description = "Any VIF"
+	case vif == 0x7F:
+		g.Description = "Manufacturer specific"
+	default:
+		g.Description = fmt.Sprintf("Reserved VIF %02X", vif)
+	}
+}
+
+// extension decodes the VIF of the extension table.
+func (g *GXMBusRecord) extension(table byte, value byte) {
+	vif := value & 0x7F
+	nn := int8(vif & 3)
+	if table == 0x7B {
+		switch {
+		case vif <= 0x01:
+			g.Description, g.Unit, g.Scaler = "Energy", enums.UnitActiveEnergy, int8(vif&1)+5
+		case vif >= 0x08 && vif <= 0x09:
+			g.Description, g.Unit, g.Scaler = "Energy", enums.UnitEnergyJoule, int8(vif&1)+8
+		case vif >= 0x10 && vif <= 0x11:
+			g.Description, g.Unit, g.Scaler = "Volume", enums.UnitVolumeCubicMeter, int8(vif&1)+2
+		case vif >= 0x18 && vif <= 0x19:
+			g.Description, g.Unit, g.Scaler = "Mass", enums.UnitMassKg, int8(vif&1)+5
+		default:
+			g.Description = fmt.Sprintf("Extension FB %02X", vif)
+		}
+		return
+	}
+	switch {
+	case vif <= 0x03:
+		g.Description, g.Unit, g.Scaler = "Credit", enums.UnitLocalCurrency, nn-3
+	case vif <= 0x07:
+		g.Description, g.Unit, g.Scaler = "Debit", enums.UnitLocalCurrency, nn-3
+	case vif == 0x08:
+		g.Description = "Access number"
+	case vif == 0x09:
+		g.Description = "Medium"
+	case vif == 0x0A:
+		g.Description = "Manufacturer"
+	case vif == 0x0C:
+		g.Description = "Version"
+	case vif == 0x0E:
+		g.Description = "Firmware version"
+	case vif == 0x0F:
+		g.Description = "Software version"
+	case vif == 0x17:
+		g.Description = "Error flags"
+	case vif == 0x3A:
+		g.Description = "Dimensionless"
+	case vif >= 0x40 && vif <= 0x4F:
+		g.Description, g.Unit, g.Scaler = "Voltage", enums.UnitVoltage, int8(vif&0x0F)-9
+	case vif >= 0x50 && vif <= 0x5F:
+		g.Description, g.Unit, g.Scaler = "Current", enums.UnitCurrent, int8(vif&0x0F)-12
+	case vif == 0x74:
+		g.Description, g.Unit = "Remaining battery life time", enums.UnitDay
+	default:
+		g.Description = fmt.Sprintf("Extension FD %02X", vif)
+	}
+}
+
+// dataLengths contains the length of the data field.
+var dataLengths = []int{0, 1, 2, 3, 4, 4, 6, 8, 0, 1, 2, 3, 4, -1, 6, 0}
+
+// decodeValue decodes the data field.
+func (g *GXMBusRecord) decodeValue(data []byte, pos int) (int, error) {
+	field := g.DataInformation[0] & 0x0F
+	length := dataLengths[field]
+	if length == -1 {
+		if pos == len(data) {
+			return 0, errors.New("invalid M-Bus variable length data")
+		}
+		lvar := data[pos]
+		pos++
+		switch {
+		case lvar <= 0xBF:
+			length = int(lvar)
+		case lvar <= 0xEF:
+			length = int(lvar & 0x0F)
+		default:
+			length = 0
+		}
+		if pos+length > len(data) {
+			return 0, errors.New("invalid M-Bus data length")
+		}
+		value := data[pos : pos+length]
+		switch {
+		case lvar <= 0xBF:
+			g.Value = reverseString(value)
+		case lvar <= 0xCF:
+			g.Value = bcdValue(value, false)
+		case lvar <= 0xDF:
+			g.Value = bcdValue(value, true)
+		default:
+			g.Value = value
+		}
+		return pos + length, nil
+	}
+	if pos+length > len(data) {
+		return 0, errors.New("invalid M-Bus data length")
+	}
+	value := data[pos : pos+length]
+	vif := g.ValueInformation[0] & 0x7F
+	switch {
+	case length == 0:
+		g.Value = nil
+	case vif == 0x6C && length == 2:
+		g.Value = mbusDate(value)
+	case vif == 0x6D && (length == 4 || length == 6):
+		g.Value = mbusDateTime(value)
+	case field == 0x5:
+		g.Value = float64(math.Float32frombits(binary.LittleEndian.Uint32(value)))
+	case field >= 0x9 && field <= 0xE:
+		g.Value = bcdValue(value, false)
+	default:
+		// Signed little-endian integer.
+		var v uint64
+		for i := length - 1; i >= 0; i-- {
+			v = v<<8 | uint64(value[i])
+		}
+		shift := 64 - 8*length
+		g.Value = int64(v<<shift) >> shift
+	}
+	return pos + length, nil
+}
+
+// bcdValue returns the little-endian BCD value.
+// The value is negative if the highest nibble is F.
+func bcdValue(value []byte, negative bool) int64 {
+	var ret int64
+	for i := len(value) - 1; i >= 0; i-- {
+		high := int64(value[i] >> 4)
+		if i == len(value)-1 && high == 0xF {
+			negative = true
+			high = 0
+		}
+		ret = ret*100 + high*10 + int64(value[i]&0x0F)
+	}
+	if negative {
+		return -ret
+	}
+	return ret
+}
+
+// mbusDate returns the type G date.
+func mbusDate(value []byte) types.GXDateTime {
+	year := int(value[0]&0xE0)>>5 | int(value[1]&0xF0)>>1
+	t := time.Date(2000+year, time.Month(value[1]&0x0F), int(value[0]&0x1F), 0, 0, 0, 0, time.Local)
+	ret := types.GXDateTime{Value: t}
+	ret.Skip = enums.DateTimeSkipsHour | enums.DateTimeSkipsMinute | enums.DateTimeSkipsSecond | enums.DateTimeSkipsMs | enums.DateTimeSkipsDeviation
+	return ret
+}
+
+// mbusDateTime returns the type F or type I date time.
+func mbusDateTime(value []byte) types.GXDateTime {
+	second := 0
+	if len(value) == 6 {
+		second = int(value[0] & 0x3F)
+		value = value[1:]
+	}
+	year := int(value[2]&0xE0)>>5 | int(value[3]&0xF0)>>1
+	t := time.Date(2000+year, time.Month(value[3]&0x0F), int(value[2]&0x1F), int(value[1]&0x1F), int(value[0]&0x3F), second, 0, time.Local)
+	ret := types.GXDateTime{Value: t}
+	ret.Skip = enums.DateTimeSkipsMs | enums.DateTimeSkipsDeviation
+	return ret
+}
+
+// reverseString returns the reversed ASCII string.
+func reverseString(value []byte) string {
+	sb := strings.Builder{}
+	for i := len(value) - 1; i >= 0; i-- {
+		sb.WriteByte(value[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,257 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// mbusSecurityMode returns the security mode and the amount of encrypted blocks from the configuration field.
+func mbusSecurityMode(configuration uint16) (int, int) {
+	return int(configuration>>8) & 0x1F, int(configuration>>4) & 0x0F
+}
+
+// mbusMode5Iv returns the initialization vector of the security mode 5.
+//
+// IV is M-field, A-field and 8 times the access number.
+func mbusMode5Iv(manufacturer uint16, id uint32, version uint8, deviceType uint8, accessNumber uint8) []byte {
+	iv := make([]byte, 16)
+	binary.LittleEndian.PutUint16(iv, manufacturer)
+	binary.LittleEndian.PutUint32(iv[2:], id)
+	iv[6] = version
+	iv[7] = deviceType
+	for pos := 8; pos != 16; pos++ {
+		iv[pos] = accessNumber
+	}
+	return iv
+}
+
+// mbusMode7Key derives the encryption key of the security mode 7.
+//
+// Key is AES-CMAC of the derivation constant, message counter, meter ID and padding.
+func mbusMode7Key(key []byte, messageCounter uint32, id uint32) ([]byte, error) {
+	data := make([]byte, 16)
+	data[0] = 0
+	binary.LittleEndian.PutUint32(data[1:], messageCounter)
+	binary.LittleEndian.PutUint32(data[5:], id)
+	for pos := 9; pos != 16; pos++ {
+		data[pos] = 0x07
+	}
+	return aesCmac(key, data)
+}
+
+// aesCmac returns AES-CMAC defined in RFC 4493.
+func aesCmac(key []byte, data []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	subKey := func(value []byte) []byte {
+		ret := make([]byte, 16)
+		for pos := 0; pos != 15; pos++ {
+			ret[pos] = value[pos]<<1 | value[pos+1]>>7
+		}
+		ret[15] = value[15] << 1
+		if (value[0] & 0x80) != 0 {
+			ret[15] ^= 0x87
+		}
+		return ret
+	}
+	l := make([]byte, 16)
+	c.Encrypt(l, l)
+	k1 := subKey(l)
+	k2 := subKey(k1)
+	n := (len(data) + 15) / 16
+	last := make([]byte, 16)
+	if n != 0 && len(data)%16 == 0 {
+		copy(last, data[16*(n-1):])
+		for pos := range last {
+			last[pos] ^= k1[pos]
+		}
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := data[16*(n-1):]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		for pos := range last {
+			last[pos] ^= k2[pos]
+		}
+	}
+	x := make([]byte, 16)
+	for block := 0; block != n-1; block++ {
+		for pos := 0; pos != 16; pos++ {
+			x[pos] ^= data[16*block+pos]
+		}
+		c.Encrypt(x, x)
+	}
+	for pos := range x {
+		x[pos] ^= last[pos]
+	}
+	c.Encrypt(x, x)
+	return x, nil
+}
+
+// mbusCipher encrypts or decrypts the encrypted blocks using AES-128-CBC.
+func mbusCipher(key []byte, iv []byte, blocks int, data []byte, encrypt bool) ([]byte, error) {
+	if blocks == 0 {
+		blocks = len(data) / 16
+	}
+	if blocks*16 > len(data) {
+		return nil, errors.New("invalid amount of encrypted M-Bus blocks")
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ret := append([]byte{}, data...)
+	if encrypt {
+		cipher.NewCBCEncrypter(c, iv).CryptBlocks(ret[:blocks*16], ret[:blocks*16])
+	} else {
+		cipher.NewCBCDecrypter(c, iv).CryptBlocks(ret[:blocks*16], ret[:blocks*16])
+		if !bytes.HasPrefix(ret, []byte{0x2F, 0x2F}) {
+			return nil, errors.New("M-Bus decryption failed")
+		}
+	}
+	return ret, nil
+}
+
+// mbusSecurity returns the key and initialization vector for the security mode.
+func (g *GXDLMSMBusClient) mbusSecurity(accessNumber uint8, messageCounter uint32) ([]byte, []byte, int, error) {
+	if len(g.EncryptionKey) != 16 {
+		return nil, nil, 0, errors.New("M-Bus encryption key is not set")
+	}
+	mode, blocks := mbusSecurityMode(g.Configuration)
+	switch mode {
+	case 5:
+		return g.EncryptionKey, mbusMode5Iv(g.ManufacturerID, g.IdentificationNumber, g.DataHeaderVersion,
+			uint8(g.DeviceType), accessNumber), blocks, nil
+	case 7:
+		key, err := mbusMode7Key(g.EncryptionKey, messageCounter, g.IdentificationNumber)
+		return key, make([]byte, 16), blocks, err
+	}
+	return nil, nil, 0, fmt.Errorf("M-Bus security mode %d is not supported", mode)
+}
+
+// Decrypt decrypts the application layer data of the M-Bus slave device.
+//
+// Security mode and the amount of encrypted blocks are read from the configuration field.
+// Mode 5 uses the encryption key as is. Mode 7 derives the key from the encryption key
+// using the message counter. Decrypted data starts with the 2F 2F verification bytes.
+//
+// Parameters:
+//
+//	data: Encrypted application layer data.
+//	accessNumber: Access number of the telegram header. Used only in mode 5.
+//	messageCounter: Message counter of the authentication and fragmentation layer. Used only in mode 7.
+func (g *GXDLMSMBusClient) Decrypt(data []byte, accessNumber uint8, messageCounter uint32) ([]byte, error) {
+	key, iv, blocks, err := g.mbusSecurity(accessNumber, messageCounter)
+	if err != nil {
+		return nil, err
+	}
+	return mbusCipher(key, iv, blocks, data, false)
+}
+
+// Encrypt encrypts the application layer data as the M-Bus slave device does.
+//
+// Data must start with the 2F 2F verification bytes and the encrypted part must be padded to 16 bytes.
+// Access number is the access number of the telegram header.
+func (g *GXDLMSMBusClient) Encrypt(data []byte, accessNumber uint8, messageCounter uint32) ([]byte, error) {
+	key, iv, blocks, err := g.mbusSecurity(accessNumber, messageCounter)
+	if err != nil {
+		return nil, err
+	}
+	return mbusCipher(key, iv, blocks, data, true)
+}
+
+// Records decrypts the application layer data if needed and decodes the data records.
+// Access number is the access number of the telegram header.
+func (g *GXDLMSMBusClient) Records(data []byte, accessNumber uint8, messageCounter uint32) ([]GXMBusRecord, error) {
+	if mode, _ := mbusSecurityMode(g.Configuration); mode != 0 {
+		var err error
+		data, err = g.Decrypt(data, accessNumber, messageCounter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return MBusRecordsDecode(data)
+}
+
+// CaptureRecords returns the record definitions of the capture definition.
+// Data and value information blocks are given as hex strings.
+func (g *GXDLMSMBusClient) CaptureRecords() ([]GXMBusRecord, error) {
+	var list []GXMBusRecord
+	for _, it := range g.CaptureDefinition {
+		item, err := MBusRecordDefinition(types.HexToBytes(it.Key), types.HexToBytes(it.Value))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *item)
+	}
+	return list, nil
+}
+
+// WrapKey encrypts the new encryption key with the default key using AES key wrap.
+func (g *GXDLMSMBusClient) WrapKey(defaultKey []byte, encryptionKey []byte) ([]byte, error) {
+	return internal.Encrypt(defaultKey, encryptionKey)
+}
+
+// TransferWrappedKey encrypts the new encryption key with the default key and transfers it to the M-Bus slave device.
+//
+// Parameters:
+//
+//	client: DLMS client settings.
+//	defaultKey: Default key of the M-Bus slave device.
+//	encryptionKey: New encryption key.
+//
+// Returns:
+//
+//	Generated DLMS data.
+func (g *GXDLMSMBusClient) TransferWrappedKey(client IGXDLMSClient, defaultKey []byte, encryptionKey []byte) ([][]uint8, error) {
+	data, err := g.WrapKey(defaultKey, encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return g.TransferKey(client, data)
+}
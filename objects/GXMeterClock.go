@@ -0,0 +1,289 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXMeterClock converts time stamps between UTC and the local time of the meter.
+//
+// The time zone and the daylight saving rules are taken from the clock object
+// of the meter instead of the time zone of the host.
+type GXMeterClock struct {
+	// Deviation of the local normal time to UTC in minutes.
+	TimeZone int
+
+	// Is time zone expressed as UTC to normal time.
+	// If false, local normal time is UTC minus time zone.
+	UseUtc2NormalTime bool
+
+	// Daylight saving begin in local normal time.
+	Begin types.GXDateTime
+
+	// Daylight saving end in local daylight saving time.
+	End types.GXDateTime
+
+	// Daylight saving deviation in minutes.
+	Deviation int
+
+	// Is daylight saving enabled.
+	Enabled bool
+}
+
+// NewGXMeterClock creates the meter clock model from the read clock object.
+//
+// Parameters:
+//
+//	clock: Clock object where time zone (3), daylight savings begin (5),
+//	  end (6), deviation (7) and enabled (8) are read.
+//	useUtc2NormalTime: Is time zone expressed as UTC to normal time.
+//
+// Returns:
+//
+//	Meter clock model.
+func NewGXMeterClock(clock *GXDLMSClock, useUtc2NormalTime bool) (*GXMeterClock, error) {
+	ret := &GXMeterClock{
+		TimeZone:          int(clock.TimeZone),
+		UseUtc2NormalTime: useUtc2NormalTime,
+		Begin:             clock.Begin,
+		End:               clock.End,
+		Deviation:         int(clock.Deviation),
+		Enabled:           clock.Enabled,
+	}
+	if ret.Enabled {
+		for _, it := range []*types.GXDateTime{&ret.Begin, &ret.End} {
+			if (it.Skip & enums.DateTimeSkipsMonth) != 0 {
+				return nil, fmt.Errorf("%w: month is not defined", dlmserrors.ErrInvalidDaylightSaving)
+			}
+			if (it.Skip&enums.DateTimeSkipsDay) != 0 && (it.Extra&(enums.DateTimeExtraInfoLastDay|enums.DateTimeExtraInfoLastDay2)) == 0 {
+				return nil, fmt.Errorf("%w: day is not defined", dlmserrors.ErrInvalidDaylightSaving)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// NormalOffset returns the offset of the local normal time to UTC.
+func (g *GXMeterClock) NormalOffset() time.Duration {
+	if g.UseUtc2NormalTime {
+		return time.Duration(g.TimeZone) * time.Minute
+	}
+	return -time.Duration(g.TimeZone) * time.Minute
+}
+
+// DaylightSavingOffset returns the offset of the local daylight saving time to UTC.
+func (g *GXMeterClock) DaylightSavingOffset() time.Duration {
+	return g.NormalOffset() + time.Duration(g.Deviation)*time.Minute
+}
+
+// transition resolves the wall clock time of the daylight saving begin or end in the given year.
+// Day of week together with the day of month selects the first matching day on or after the day.
+// Day of week together with the last day of month selects the last matching day of the month.
+func transition(value *types.GXDateTime, year int) (time.Time, bool) {
+	if (value.Skip&enums.DateTimeSkipsYear) == 0 && value.Value.Year() != year {
+		return time.Time{}, false
+	}
+	month := value.Value.Month()
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	backward := true
+	day := value.Value.Day()
+	switch {
+	case (value.Extra & enums.DateTimeExtraInfoLastDay) != 0:
+		day = lastDay
+	case (value.Extra & enums.DateTimeExtraInfoLastDay2) != 0:
+		day = lastDay - 1
+	default:
+		backward = false
+	}
+	ret := time.Date(year, month, day, skipValue(value.Skip, enums.DateTimeSkipsHour, value.Value.Hour()),
+		skipValue(value.Skip, enums.DateTimeSkipsMinute, value.Value.Minute()),
+		skipValue(value.Skip, enums.DateTimeSkipsSecond, value.Value.Second()), 0, time.UTC)
+	if (value.Skip&enums.DateTimeSkipsDayOfWeek) == 0 && value.DayOfWeek > 0 && value.DayOfWeek < 8 {
+		for cosemDayOfWeek(ret.Weekday()) != value.DayOfWeek {
+			if backward {
+				ret = ret.AddDate(0, 0, -1)
+			} else {
+				ret = ret.AddDate(0, 0, 1)
+			}
+		}
+	}
+	return ret, true
+}
+
+// Transitions returns the daylight saving begin and end in UTC for the given year.
+//
+// Parameters:
+//
+//	year: Year in the local time of the meter.
+//
+// Returns:
+//
+//	Begin and end of the daylight saving. False is returned if daylight saving is not used in the given year.
+func (g *GXMeterClock) Transitions(year int) (time.Time, time.Time, bool) {
+	if !g.Enabled || g.Deviation == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	begin, ok := transition(&g.Begin, year)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	end, ok := transition(&g.End, year)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	// Begin is given in normal time and end in daylight saving time.
+	return begin.Add(-g.NormalOffset()), end.Add(-g.DaylightSavingOffset()), true
+}
+
+// IsDaylightSaving returns true if daylight saving is active at the given time.
+//
+// Parameters:
+//
+//	value: Time to check.
+func (g *GXMeterClock) IsDaylightSaving(value time.Time) bool {
+	value = value.UTC()
+	begin, end, ok := g.Transitions(value.Add(g.NormalOffset()).Year())
+	if !ok {
+		return false
+	}
+	if begin.Before(end) {
+		return !value.Before(begin) && value.Before(end)
+	}
+	// Southern hemisphere where daylight saving continues over the new year.
+	return !value.Before(begin) || value.Before(end)
+}
+
+// Offset returns the offset of the meter local time to UTC at the given time.
+//
+// Parameters:
+//
+//	value: Time to check.
+func (g *GXMeterClock) Offset(value time.Time) time.Duration {
+	if g.IsDaylightSaving(value) {
+		return g.DaylightSavingOffset()
+	}
+	return g.NormalOffset()
+}
+
+// ToUTC converts the meter local time to UTC.
+//
+// If the deviation is given in the time stamp, it's used as is.
+// Otherwise the date and time fields are handled as the wall clock time of the meter.
+// At the end of the daylight saving the same wall clock time occurs twice and
+// the daylight saving active bit of the clock status selects between them.
+//
+// Parameters:
+//
+//	value: Meter local time.
+//
+// Returns:
+//
+//	Time in UTC.
+func (g *GXMeterClock) ToUTC(value types.GXDateTime) time.Time {
+	if (value.Skip & enums.DateTimeSkipsDeviation) == 0 {
+		return value.Value.UTC()
+	}
+	v := value.Value
+	wall := time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC)
+	normal := wall.Add(-g.NormalOffset())
+	dst := wall.Add(-g.DaylightSavingOffset())
+	normalValid := !g.IsDaylightSaving(normal)
+	dstValid := g.IsDaylightSaving(dst)
+	if normalValid != dstValid {
+		if dstValid {
+			return dst
+		}
+		return normal
+	}
+	// Wall clock time is ambiguous or it doesn't exist.
+	if (value.Status & enums.ClockStatusDaylightSavingActive) != 0 {
+		return dst
+	}
+	return normal
+}
+
+// ToMeterTime converts the time to the meter local time.
+//
+// Parameters:
+//
+//	value: Time to convert.
+//
+// Returns:
+//
+//	Meter local time with the deviation and the daylight saving active status bit.
+func (g *GXMeterClock) ToMeterTime(value time.Time) types.GXDateTime {
+	ret := types.GXDateTime{}
+	offset := g.NormalOffset()
+	if g.IsDaylightSaving(value) {
+		offset = g.DaylightSavingOffset()
+		ret.Status |= enums.ClockStatusDaylightSavingActive
+	}
+	ret.Value = value.In(time.FixedZone("", int(offset/time.Second)))
+	ret.DayOfWeek = cosemDayOfWeek(ret.Value.Weekday())
+	return ret
+}
+
+// UpdateRecords converts the capture times of the profile generic records to UTC.
+//
+// Status of the record is used to resolve the ambiguous hour at the end of the daylight saving.
+//
+// Parameters:
+//
+//	table: Profile generic records.
+func (g *GXMeterClock) UpdateRecords(table *GXProfileGenericTable) {
+	for pos := range table.Records {
+		r := &table.Records[pos]
+		if r.Time.IsZero() {
+			continue
+		}
+		dt := types.GXDateTime{Value: r.Time, Skip: enums.DateTimeSkipsDeviation, Status: r.Status}
+		if !r.TimeFilled {
+			for _, it := range r.Values {
+				if v, ok := it.(types.GXDateTime); ok && v.Value.Equal(r.Time) {
+					dt = v
+					dt.Status |= r.Status & enums.ClockStatusDaylightSavingActive
+					break
+				}
+			}
+		}
+		r.Time = g.ToUTC(dt)
+	}
+}
@@ -0,0 +1,336 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/settings"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// limiterState is the run-time state of the limiter.
+type limiterState struct {
+	// Is the over threshold action executed.
+	over bool
+	// Time when the monitored value crossed the threshold.
+	since time.Time
+}
+
+// registerMonitorState is the run-time state of the register monitor.
+type registerMonitorState struct {
+	// Is the monitored value over the threshold.
+	over []bool
+}
+
+// GXMonitorEvaluator evaluates limiters and register monitors on the server side.
+//
+// Evaluate is called periodically. It reads the monitored attributes
+// through the object model and executes the linked scripts
+// when the thresholds are crossed.
+type GXMonitorEvaluator struct {
+	// Server objects.
+	Objects GXDLMSObjectCollection
+	// DLMS settings used to read the monitored values and execute the scripts.
+	Settings *settings.GXDLMSSettings
+	// Clock returns the current time. time.Now is used if this is not set.
+	Clock func() time.Time
+	// OnExecute is called to execute the script.
+	// Script actions are applied to the object model if this is not set.
+	OnExecute func(table *GXDLMSScriptTable, script *GXDLMSScript) error
+
+	limiters map[*GXDLMSLimiter]*limiterState
+	monitors map[*GXDLMSRegisterMonitor]*registerMonitorState
+}
+
+// NewGXMonitorEvaluator creates a new monitor evaluator for the server objects.
+func NewGXMonitorEvaluator(settings *settings.GXDLMSSettings, objects GXDLMSObjectCollection) *GXMonitorEvaluator {
+	return &GXMonitorEvaluator{Settings: settings, Objects: objects,
+		limiters: map[*GXDLMSLimiter]*limiterState{},
+		monitors: map[*GXDLMSRegisterMonitor]*registerMonitorState{}}
+}
+
+// now returns the current time.
+func (g *GXMonitorEvaluator) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// Reset clears the run-time state of the limiters and register monitors.
+func (g *GXMonitorEvaluator) Reset() {
+	g.limiters = map[*GXDLMSLimiter]*limiterState{}
+	g.monitors = map[*GXDLMSRegisterMonitor]*registerMonitorState{}
+}
+
+// Evaluate evaluates all limiters and register monitors of the object model.
+func (g *GXMonitorEvaluator) Evaluate() error {
+	now := g.now()
+	for _, it := range g.Objects {
+		switch v := it.(type) {
+		case *GXDLMSLimiter:
+			if err := g.EvaluateLimiter(v, now); err != nil {
+				return err
+			}
+		case *GXDLMSRegisterMonitor:
+			if err := g.EvaluateRegisterMonitor(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmergencyProfileActive returns true if the emergency profile of the limiter is active at the given time.
+//
+// Emergency profile is active when the emergency profile ID is one of the group IDs
+// and the time is inside the activation period.
+func IsEmergencyProfileActive(limiter *GXDLMSLimiter, now time.Time) bool {
+	profile := &limiter.EmergencyProfile
+	found := false
+	for _, it := range limiter.EmergencyProfileGroupIDs {
+		if it == profile.ID {
+			found = true
+			break
+		}
+	}
+	if !found || profile.ActivationTime.Value.IsZero() {
+		return false
+	}
+	start := profile.ActivationTime.Value
+	end := start.Add(time.Duration(profile.Duration) * time.Second)
+	return !now.Before(start) && now.Before(end)
+}
+
+// EvaluateLimiter evaluates the limiter at the given time.
+//
+// Active threshold is updated from the normal or emergency threshold.
+// Over threshold action is executed when the monitored value is over the threshold
+// for the minimal over threshold duration. Under threshold action is executed
+// when the value is returned under the threshold for the minimal under threshold duration.
+func (g *GXMonitorEvaluator) EvaluateLimiter(limiter *GXDLMSLimiter, now time.Time) error {
+	if limiter.MonitoredValue == nil {
+		return nil
+	}
+	state, ok := g.limiters[limiter]
+	if !ok {
+		state = &limiterState{}
+		g.limiters[limiter] = state
+	}
+	limiter.EmergencyProfileActive = IsEmergencyProfileActive(limiter, now)
+	if limiter.EmergencyProfileActive {
+		limiter.ThresholdActive = limiter.ThresholdEmergency
+	} else {
+		limiter.ThresholdActive = limiter.ThresholdNormal
+	}
+	if limiter.ThresholdActive == nil {
+		return nil
+	}
+	value, err := g.readValue(limiter.MonitoredValue, limiter.MonitoredAttributeIndex)
+	if err != nil {
+		return err
+	}
+	threshold, err := monitorValue(limiter.ThresholdActive)
+	if err != nil {
+		return err
+	}
+	over := value > threshold
+	if over == state.over {
+		// Value is on the same side of the threshold as the executed action.
+		state.since = time.Time{}
+		return nil
+	}
+	if state.since.IsZero() {
+		state.since = now
+	}
+	duration := limiter.MinUnderThresholdDuration
+	action := &limiter.ActionUnderThreshold
+	if over {
+		duration = limiter.MinOverThresholdDuration
+		action = &limiter.ActionOverThreshold
+	}
+	if now.Sub(state.since) < time.Duration(duration)*time.Second {
+		return nil
+	}
+	state.over = over
+	state.since = time.Time{}
+	return g.execute(action)
+}
+
+// EvaluateRegisterMonitor evaluates the register monitor.
+//
+// Action up is executed when the monitored value rises over the threshold and
+// action down when it falls back under the threshold.
+// The first evaluation only stores the current state.
+func (g *GXMonitorEvaluator) EvaluateRegisterMonitor(monitor *GXDLMSRegisterMonitor) error {
+	mv := &monitor.MonitoredValue
+	target := g.Objects.FindByLN(mv.ObjectType, mv.LogicalName)
+	if target == nil {
+		return fmt.Errorf("monitored object %s %s not found", mv.ObjectType, mv.LogicalName)
+	}
+	value, err := g.readValue(target, mv.AttributeIndex)
+	if err != nil {
+		return err
+	}
+	state, initialized := g.monitors[monitor]
+	if !initialized || len(state.over) != len(monitor.Thresholds) {
+		state = &registerMonitorState{over: make([]bool, len(monitor.Thresholds))}
+		g.monitors[monitor] = state
+		initialized = false
+	}
+	for pos, it := range monitor.Thresholds {
+		threshold, err := monitorValue(it)
+		if err != nil {
+			return err
+		}
+		over := value > threshold
+		if initialized && over != state.over[pos] && pos < len(monitor.Actions) {
+			action := &monitor.Actions[pos].ActionDown
+			if over {
+				action = &monitor.Actions[pos].ActionUp
+			}
+			if err = g.execute(action); err != nil {
+				return err
+			}
+		}
+		state.over[pos] = over
+	}
+	return nil
+}
+
+// readValue reads the monitored attribute value from the object.
+func (g *GXMonitorEvaluator) readValue(target IGXDLMSBase, index int8) (float64, error) {
+	e := internal.NewValueEventArgs(g.Settings, target, uint8(index))
+	value, err := target.GetValue(g.Settings, e)
+	if err != nil {
+		return 0, err
+	}
+	if e.Error != enums.ErrorCodeOk {
+		return 0, fmt.Errorf("failed to read %s %s attribute %d: %s", target.Base().ObjectType(),
+			target.Base().LogicalName(), index, e.Error)
+	}
+	return monitorValue(value)
+}
+
+// execute executes the script of the action item.
+func (g *GXMonitorEvaluator) execute(action *GXDLMSActionItem) error {
+	if action.LogicalName == "" || action.LogicalName == "0.0.0.0.0.0" {
+		return nil
+	}
+	obj := g.Objects.FindByLN(enums.ObjectTypeScriptTable, action.LogicalName)
+	if obj == nil {
+		return fmt.Errorf("script table %s not found", action.LogicalName)
+	}
+	table := obj.(*GXDLMSScriptTable)
+	for pos := range table.Scripts {
+		script := &table.Scripts[pos]
+		if script.Id == action.ScriptSelector {
+			if g.OnExecute != nil {
+				return g.OnExecute(table, script)
+			}
+			return ExecuteScript(g.Settings, script)
+		}
+	}
+	return fmt.Errorf("script %d not found from script table %s", action.ScriptSelector, action.LogicalName)
+}
+
+// ExecuteScript applies the script actions to the target objects.
+func ExecuteScript(settings *settings.GXDLMSSettings, script *GXDLMSScript) error {
+	for _, it := range script.Actions {
+		if it.Target == nil {
+			continue
+		}
+		e := internal.NewValueEventArgs(settings, it.Target, uint8(it.Index))
+		switch it.Type {
+		case enums.ScriptActionTypeWrite:
+			e.Value = it.Parameter
+			if err := it.Target.SetValue(settings, e); err != nil {
+				return err
+			}
+		case enums.ScriptActionTypeExecute:
+			e.Action = true
+			e.Parameters = it.Parameter
+			if _, err := it.Target.Invoke(settings, e); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+		if e.Error != enums.ErrorCodeOk {
+			return fmt.Errorf("script action %s failed: %s", it.String(), e.Error)
+		}
+	}
+	return nil
+}
+
+// monitorValue converts the monitored value to float.
+func monitorValue(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case types.GXEnum:
+		return float64(v.Value), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("monitored value type %T is not supported", value)
+}
@@ -0,0 +1,502 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal"
+	"github.com/Gurux/gxdlms-go/settings"
+)
+
+// GXPrepaymentEngine links the account, credit, charge and token gateway objects
+// and runs the prepayment logic on the server side.
+//
+// Update is called periodically. It activates the passive unit charges,
+// collects the charges from the credits in priority order and updates the account status.
+// Tokens are accepted through the token gateways.
+type GXPrepaymentEngine struct {
+	// Account.
+	Account *GXDLMSAccount
+	// Server objects where credits, charges and token gateways are searched.
+	Objects GXDLMSObjectCollection
+	// DLMS settings used to read the commodity values.
+	Settings *settings.GXDLMSSettings
+	// Clock returns the current time. time.Now is used if this is not set.
+	Clock func() time.Time
+	// Index of the charge table that is used.
+	// The first charge table is used if the index is not found.
+	ChargeTableIndex string
+	// TokenDecoder returns the credit amount of the token.
+	// If this is not set, the token is a big-endian 32-bit signed amount.
+	TokenDecoder func(gateway *GXDLMSTokenGateway, token []byte) (int32, error)
+
+	// Previous commodity values of the consumption based charges.
+	consumption map[*GXDLMSCharge]float64
+}
+
+// NewGXPrepaymentEngine creates a new prepayment engine for the account.
+func NewGXPrepaymentEngine(settings *settings.GXDLMSSettings, account *GXDLMSAccount, objects GXDLMSObjectCollection) *GXPrepaymentEngine {
+	return &GXPrepaymentEngine{Settings: settings, Account: account, Objects: objects,
+		consumption: map[*GXDLMSCharge]float64{}}
+}
+
+// now returns the current time.
+func (g *GXPrepaymentEngine) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// Credits returns the credits of the account in priority order.
+func (g *GXPrepaymentEngine) Credits() []*GXDLMSCredit {
+	var list []*GXDLMSCredit
+	for _, ln := range g.Account.CreditReferences {
+		if it, ok := g.Objects.FindByLN(enums.ObjectTypeCredit, ln).(*GXDLMSCredit); ok {
+			list = append(list, it)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Priority < list[j].Priority
+	})
+	return list
+}
+
+// Charges returns the charges of the account in priority order.
+func (g *GXPrepaymentEngine) Charges() []*GXDLMSCharge {
+	var list []*GXDLMSCharge
+	for _, ln := range g.Account.ChargeReferences {
+		if it, ok := g.Objects.FindByLN(enums.ObjectTypeCharge, ln).(*GXDLMSCharge); ok {
+			list = append(list, it)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Priority < list[j].Priority
+	})
+	return list
+}
+
+// Update activates the account and the passive unit charges,
+// processes the received tokens and collects the consumption and time based charges.
+// Charges are collected even if a token is rejected and the first token error is returned.
+func (g *GXPrepaymentEngine) Update() error {
+	now := g.now()
+	a := g.Account
+	if a.AccountStatus == enums.AccountStatusNewInactiveAccount && !a.AccountActivationTime.Value.IsZero() &&
+		!now.Before(a.AccountActivationTime.Value) {
+		a.AccountStatus = enums.AccountStatusAccountActive
+	}
+	if a.AccountStatus == enums.AccountStatusAccountActive && !a.AccountClosureTime.Value.IsZero() &&
+		!now.Before(a.AccountClosureTime.Value) {
+		a.AccountStatus = enums.AccountStatusAccountClosed
+	}
+	if a.AccountStatus != enums.AccountStatusAccountActive {
+		g.updateStatus()
+		return nil
+	}
+	var tokenErr error
+	for _, it := range g.Objects.GetObjects(enums.ObjectTypeTokenGateway) {
+		gateway := it.(*GXDLMSTokenGateway)
+		if gateway.StatusCode == enums.TokenStatusCodeTokenReceived {
+			if gateway.Time.Value.IsZero() {
+				gateway.Time.Value = now
+			}
+			if err := g.processToken(gateway); err != nil && tokenErr == nil {
+				tokenErr = fmt.Errorf("token of %s: %w", gateway.LogicalName(), err)
+			}
+		}
+	}
+	for _, charge := range g.Charges() {
+		if !charge.UnitChargeActivationTime.Value.IsZero() && !now.Before(charge.UnitChargeActivationTime.Value) {
+			charge.UnitChargeActive = charge.UnitChargePassive
+			charge.UnitChargeActivationTime.Value = time.Time{}
+		}
+		var amount int32
+		switch charge.ChargeType {
+		case enums.ChargeTypeConsumptionBasedCollection:
+			value, err := g.commodityValue(charge)
+			if err != nil {
+				return err
+			}
+			previous, ok := g.consumption[charge]
+			g.consumption[charge] = value
+			if !ok || value <= previous {
+				continue
+			}
+			scaling := &charge.UnitChargeActive.ChargePerUnitScaling
+			amount = int32(math.Round((value - previous) * math.Pow10(int(scaling.CommodityScale)) * g.chargePerUnit(charge)))
+		case enums.ChargeTypeTimeBasedCollection:
+			if charge.Period == 0 {
+				continue
+			}
+			if charge.LastCollectionTime.Value.IsZero() {
+				charge.LastCollectionTime.Value = now
+				continue
+			}
+			period := time.Duration(charge.Period) * time.Second
+			count := now.Sub(charge.LastCollectionTime.Value) / period
+			if count <= 0 {
+				continue
+			}
+			amount = int32(math.Round(float64(count) * g.chargePerUnit(charge)))
+			charge.LastCollectionTime.Value = charge.LastCollectionTime.Value.Add(count * period)
+		default:
+			continue
+		}
+		g.collect(charge, amount, now)
+	}
+	g.updateStatus()
+	return tokenErr
+}
+
+// EnterToken enters the token through the token gateway and applies the credit amount.
+//
+// The status code of the gateway tells the result of the token.
+func (g *GXPrepaymentEngine) EnterToken(gateway *GXDLMSTokenGateway, token []byte, delivery enums.TokenDelivery) error {
+	gateway.Token = token
+	gateway.DeliveryMethod = delivery
+	gateway.Time.Value = g.now()
+	gateway.StatusCode = enums.TokenStatusCodeTokenReceived
+	err := g.processToken(gateway)
+	g.updateStatus()
+	return err
+}
+
+// processToken decodes the received token and distributes the amount.
+func (g *GXPrepaymentEngine) processToken(gateway *GXDLMSTokenGateway) error {
+	var amount int32
+	var err error
+	if g.TokenDecoder != nil {
+		amount, err = g.TokenDecoder(gateway, gateway.Token)
+	} else if len(gateway.Token) == 4 {
+		amount = int32(binary.BigEndian.Uint32(gateway.Token))
+	} else {
+		err = errors.New("invalid token length")
+	}
+	if err != nil {
+		if gateway.StatusCode == enums.TokenStatusCodeTokenReceived {
+			gateway.StatusCode = enums.TokenStatusCodeTokenFormatFailure
+		}
+		return err
+	}
+	if g.Account.AccountStatus != enums.AccountStatusAccountActive || amount <= 0 {
+		gateway.StatusCode = enums.TokenStatusCodeValidationResultFailure
+		return fmt.Errorf("token is not accepted")
+	}
+	err = g.addCredit(amount)
+	if err != nil {
+		gateway.StatusCode = enums.TokenStatusCodeTokenExecutionResultFailure
+		return err
+	}
+	gateway.StatusCode = enums.TokenStatusCodeTokenExecutionOk
+	return nil
+}
+
+// addCredit distributes the token amount.
+//
+// Used emergency credit is paid back first. Then payment event based charges
+// and the debt are collected. The rest is divided between the credits
+// using the token gateway configuration.
+func (g *GXPrepaymentEngine) addCredit(amount int32) error {
+	credits := g.Credits()
+	receivers := g.tokenReceivers(credits)
+	if len(receivers) == 0 {
+		return errors.New("no credit accepts tokens")
+	}
+	now := g.now()
+	// Pay back the used emergency credit.
+	for _, c := range credits {
+		if (c.CreditConfiguration&enums.CreditConfigurationPaidBack) == 0 || amount == 0 {
+			continue
+		}
+		if c.Status != enums.CreditStatusInUse && c.Status != enums.CreditStatusConsumed && c.Status != enums.CreditStatusInvoked {
+			continue
+		}
+		owed := c.PresetCreditAmount - c.CurrentCreditAmount
+		if owed <= 0 {
+			continue
+		}
+		pay := min(owed, amount)
+		c.CurrentCreditAmount += pay
+		amount -= pay
+		if c.CurrentCreditAmount == c.PresetCreditAmount {
+			c.CurrentCreditAmount = 0
+			c.Status = selectableStatus(c)
+		}
+	}
+	charges := g.Charges()
+	// Payment event based charges.
+	for _, charge := range charges {
+		if charge.ChargeType != enums.ChargeTypePaymentEventBasedCollection || amount == 0 {
+			continue
+		}
+		var value int32
+		if (charge.ChargeConfiguration & enums.ChargeConfigurationPercentageBasedCollection) != 0 {
+			value = int32(int64(amount) * int64(charge.Proportion) / 10000)
+		} else {
+			value = int32(math.Round(g.chargePerUnit(charge)))
+		}
+		if (charge.ChargeConfiguration & enums.ChargeConfigurationContinuousCollection) == 0 {
+			value = min(value, charge.TotalAmountRemaining)
+			charge.TotalAmountRemaining -= value
+		}
+		value = max(0, min(value, amount))
+		if value != 0 {
+			amount -= value
+			charge.TotalAmountPaid += value
+			charge.LastCollectionAmount = value
+			charge.LastCollectionTime.Value = now
+		}
+	}
+	// Debt of continuous charges that was not collected.
+	for _, charge := range charges {
+		if charge.ChargeType == enums.ChargeTypePaymentEventBasedCollection ||
+			(charge.ChargeConfiguration&enums.ChargeConfigurationContinuousCollection) == 0 || charge.TotalAmountRemaining <= 0 {
+			continue
+		}
+		value := min(charge.TotalAmountRemaining, amount)
+		charge.TotalAmountRemaining -= value
+		charge.TotalAmountPaid += value
+		amount -= value
+	}
+	// Divide the rest between the credits.
+	total := amount
+	for pos, it := range receivers {
+		part := total
+		if it.proportion != 0 && pos != len(receivers)-1 {
+			part = int32(int64(total) * int64(it.proportion) / 100)
+		}
+		part = min(part, amount)
+		it.credit.CurrentCreditAmount += part
+		amount -= part
+		if it.credit.Status == enums.CreditStatusConsumed && it.credit.CurrentCreditAmount > it.credit.Limit {
+			it.credit.Status = selectableStatus(it.credit)
+		}
+	}
+	return nil
+}
+
+// tokenReceiver is a credit that receives a proportion of the token amount.
+type tokenReceiver struct {
+	credit     *GXDLMSCredit
+	proportion uint8
+}
+
+// tokenReceivers returns the credits that receive the token amount.
+func (g *GXPrepaymentEngine) tokenReceivers(credits []*GXDLMSCredit) []tokenReceiver {
+	var list []tokenReceiver
+	for _, it := range g.Account.TokenGatewayConfigurations {
+		for _, c := range credits {
+			if c.LogicalName() == it.CreditReference && (c.CreditConfiguration&enums.CreditConfigurationTokens) != 0 {
+				list = append(list, tokenReceiver{credit: c, proportion: it.TokenProportion})
+			}
+		}
+	}
+	if len(list) == 0 {
+		for _, c := range credits {
+			if (c.CreditConfiguration&enums.CreditConfigurationTokens) != 0 || c.Type == enums.CreditTypeToken {
+				return []tokenReceiver{{credit: c}}
+			}
+		}
+	}
+	return list
+}
+
+// collect collects the charge amount from the credits.
+// Amount that can't be collected is added to the total amount remaining.
+func (g *GXPrepaymentEngine) collect(charge *GXDLMSCharge, amount int32, now time.Time) {
+	continuous := (charge.ChargeConfiguration & enums.ChargeConfigurationContinuousCollection) != 0
+	if !continuous {
+		// Debt recovery is collected until the total amount remaining is paid.
+		amount = min(amount, charge.TotalAmountRemaining)
+	}
+	if amount <= 0 {
+		return
+	}
+	collected := g.consume(amount)
+	if continuous {
+		charge.TotalAmountRemaining += amount - collected
+	} else {
+		charge.TotalAmountRemaining -= collected
+	}
+	charge.TotalAmountPaid += collected
+	charge.LastCollectionAmount = collected
+	charge.LastCollectionTime.Value = now
+}
+
+// consume consumes the amount from the credits in priority order and returns the consumed amount.
+func (g *GXPrepaymentEngine) consume(amount int32) int32 {
+	var collected int32
+	var used *GXDLMSCredit
+	for _, c := range g.Credits() {
+		if amount == 0 {
+			break
+		}
+		if c.Status != enums.CreditStatusEnabled && c.Status != enums.CreditStatusInvoked && c.Status != enums.CreditStatusInUse {
+			continue
+		}
+		if c.Status != enums.CreditStatusInUse && c.Type == enums.CreditTypeEmergency && c.CurrentCreditAmount == 0 {
+			// Emergency credit is taken into use.
+			c.CurrentCreditAmount = c.PresetCreditAmount
+		}
+		available := c.CurrentCreditAmount - c.Limit
+		if available <= 0 {
+			c.Status = enums.CreditStatusConsumed
+			continue
+		}
+		value := min(amount, available)
+		c.CurrentCreditAmount -= value
+		amount -= value
+		collected += value
+		c.Status = enums.CreditStatusInUse
+		used = c
+		if c.CurrentCreditAmount <= c.Limit {
+			c.Status = enums.CreditStatusConsumed
+		}
+	}
+	if used != nil {
+		for _, c := range g.Credits() {
+			if c != used && c.Status == enums.CreditStatusInUse {
+				// Credit that needs a confirmation stays selected.
+				if (c.CreditConfiguration & enums.CreditConfigurationConfirmation) != 0 {
+					c.Status = enums.CreditStatusInvoked
+				} else {
+					c.Status = enums.CreditStatusEnabled
+				}
+			}
+		}
+	}
+	return collected
+}
+
+// updateStatus updates the available credit, aggregated debt and credit status of the account.
+func (g *GXPrepaymentEngine) updateStatus() {
+	a := g.Account
+	credits := g.Credits()
+	a.AvailableCredit = 0
+	a.CurrentCreditStatus = enums.AccountCreditStatusNone
+	var inUse *GXDLMSCredit
+	for _, c := range credits {
+		if c.CurrentCreditAmount > 0 {
+			a.AvailableCredit += c.CurrentCreditAmount
+		}
+		if c.Status == enums.CreditStatusInUse && inUse == nil {
+			inUse = c
+		}
+	}
+	a.AggregatedDebt = 0
+	for _, it := range g.Charges() {
+		a.AggregatedDebt += it.TotalAmountRemaining
+	}
+	if inUse != nil {
+		for pos, ln := range a.CreditReferences {
+			if ln == inUse.LogicalName() {
+				a.CurrentCreditInUse = uint8(pos)
+				break
+			}
+		}
+		if (inUse.CreditConfiguration & enums.CreditConfigurationConfirmation) != 0 {
+			a.CurrentCreditStatus |= enums.AccountCreditStatusSelectableCreditInUse
+		}
+	}
+	if a.AvailableCredit > 0 {
+		a.CurrentCreditStatus |= enums.AccountCreditStatusInCredit
+		if a.AvailableCredit < a.LowCreditThreshold {
+			a.CurrentCreditStatus |= enums.AccountCreditStatusLowCredit
+		}
+	} else {
+		a.CurrentCreditStatus |= enums.AccountCreditStatusOutOfCredit
+	}
+	// Next credit after the credit in use.
+	for _, c := range credits {
+		if c == inUse || c.Status == enums.CreditStatusConsumed || c.Status == enums.CreditStatusInUse {
+			continue
+		}
+		if c.Status == enums.CreditStatusSelectable {
+			a.CurrentCreditStatus |= enums.AccountCreditStatusNextCreditSelectable
+		} else {
+			a.CurrentCreditStatus |= enums.AccountCreditStatusNextCreditEnabled
+		}
+		break
+	}
+}
+
+// commodityValue reads the commodity value of the charge.
+func (g *GXPrepaymentEngine) commodityValue(charge *GXDLMSCharge) (float64, error) {
+	c := &charge.UnitChargeActive.Commodity
+	if c.Target == nil {
+		return 0, fmt.Errorf("commodity of the charge %s is not set", charge.LogicalName())
+	}
+	e := internal.NewValueEventArgs(g.Settings, c.Target, uint8(c.Index))
+	value, err := c.Target.GetValue(g.Settings, e)
+	if err != nil {
+		return 0, err
+	}
+	if e.Error != enums.ErrorCodeOk {
+		return 0, fmt.Errorf("failed to read commodity %s: %s", c.Target.Base().LogicalName(), e.Error)
+	}
+	return monitorValue(value)
+}
+
+// chargePerUnit returns the scaled charge per unit of the active unit charge.
+func (g *GXPrepaymentEngine) chargePerUnit(charge *GXDLMSCharge) float64 {
+	u := &charge.UnitChargeActive
+	if len(u.ChargeTables) == 0 {
+		return 0
+	}
+	value := u.ChargeTables[0].ChargePerUnit
+	for _, it := range u.ChargeTables {
+		if it.Index == g.ChargeTableIndex {
+			value = it.ChargePerUnit
+			break
+		}
+	}
+	return float64(value) * math.Pow10(int(u.ChargePerUnitScaling.PriceScale))
+}
+
+// selectableStatus returns the status of the credit when it can be used again.
+func selectableStatus(credit *GXDLMSCredit) enums.CreditStatus {
+	if (credit.CreditConfiguration & enums.CreditConfigurationConfirmation) != 0 {
+		return enums.CreditStatusSelectable
+	}
+	return enums.CreditStatusEnabled
+}
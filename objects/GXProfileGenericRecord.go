@@ -0,0 +1,226 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// GXProfileGenericColumn describes a column of the profile generic buffer.
+type GXProfileGenericColumn struct {
+	// Captured object.
+	Target IGXDLMSBase
+
+	// Captured attribute index.
+	AttributeIndex int
+
+	// Captured data index.
+	DataIndex uint16
+
+	// Column header.
+	Name string
+
+	// Description of the captured object.
+	Description string
+
+	// Scaler of the register value. Register values are scaled when the buffer is read.
+	Scaler float64
+
+	// Unit of the register value.
+	Unit enums.Unit
+}
+
+// GXProfileGenericRecord is one decoded row of the profile generic buffer.
+type GXProfileGenericRecord struct {
+	// Capture time. Time is zero if the profile doesn't capture the clock.
+	Time time.Time
+
+	// Is capture time calculated from the capture period.
+	TimeFilled bool
+
+	// Status of the row from the clock status or the status register.
+	Status enums.ClockStatus
+
+	// Column values. Clock times are GXDateTime and status values are ClockStatus.
+	Values []any
+}
+
+// GXProfileGenericTable contains the decoded profile generic buffer.
+type GXProfileGenericTable struct {
+	// Column definitions.
+	Columns []GXProfileGenericColumn
+
+	// Decoded rows.
+	Records []GXProfileGenericRecord
+}
+
+// String returns the table as tab separated text.
+func (g *GXProfileGenericTable) String() string {
+	var sb strings.Builder
+	for pos, it := range g.Columns {
+		if pos != 0 {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(it.Name)
+		if it.Unit != enums.UnitNone {
+			fmt.Fprintf(&sb, " [%s]", it.Unit)
+		}
+	}
+	sb.WriteString("\n")
+	for _, r := range g.Records {
+		for pos, it := range r.Values {
+			if pos != 0 {
+				sb.WriteString("\t")
+			}
+			if it != nil {
+				fmt.Fprint(&sb, it)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// GetColumnDefinitions returns the column definitions of the capture objects.
+//
+// Parameters:
+//
+//	columns: Read columns. If nil, all the capture objects are used.
+func (g *GXDLMSProfileGeneric) GetColumnDefinitions(columns []types.GXKeyValuePair[IGXDLMSBase, *GXDLMSCaptureObject]) []GXProfileGenericColumn {
+	if columns == nil {
+		columns = g.CaptureObjects
+	}
+	ret := make([]GXProfileGenericColumn, 0, len(columns))
+	for _, it := range columns {
+		c := GXProfileGenericColumn{Target: it.Key, Scaler: 1}
+		if it.Value != nil {
+			c.AttributeIndex = it.Value.AttributeIndex
+			c.DataIndex = it.Value.DataIndex
+		}
+		if it.Key != nil {
+			c.Name = fmt.Sprintf("%s:%d", it.Key.Base().LogicalName(), c.AttributeIndex)
+			c.Description = it.Key.Base().Description
+			switch r := it.Key.(type) {
+			case *GXDLMSRegister:
+				if c.AttributeIndex == 2 {
+					c.Scaler, c.Unit = r.Scaler(), r.Unit
+				}
+			case *GXDLMSExtendedRegister:
+				if c.AttributeIndex == 2 {
+					c.Scaler, c.Unit = r.Scaler(), r.Unit
+				}
+			case *GXDLMSDemandRegister:
+				if c.AttributeIndex == 2 || c.AttributeIndex == 3 {
+					c.Scaler, c.Unit = r.Scaler(), r.Unit
+				}
+			}
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+// GetRecords decodes the buffer to the records.
+// Compressed capture times are calculated from the capture period and
+// the status columns are decoded to ClockStatus.
+//
+// Parameters:
+//
+//	columns: Read columns. If nil, all the capture objects are used.
+//
+// Returns:
+//
+//	Decoded table.
+func (g *GXDLMSProfileGeneric) GetRecords(columns []types.GXKeyValuePair[IGXDLMSBase, *GXDLMSCaptureObject]) (*GXProfileGenericTable, error) {
+	ret := &GXProfileGenericTable{Columns: g.GetColumnDefinitions(columns)}
+	var last time.Time
+	for _, row := range g.Buffer {
+		if len(row) != len(ret.Columns) {
+			return nil, errors.New("The number of columns does not match.")
+		}
+		r := GXProfileGenericRecord{Values: make([]any, len(row))}
+		timeFound := false
+		for pos, value := range row {
+			c := ret.Columns[pos]
+			switch {
+			case isCaptureTimeColumn(c):
+				if !timeFound {
+					if dt, ok := value.(types.GXDateTime); ok {
+						r.Time = dt.Value
+						r.TimeFilled = g.filledTimes[dt.Value]
+					} else if value == nil && !last.IsZero() {
+						r.Time = g.nextCaptureTime(last)
+						r.TimeFilled = true
+						value = types.GXDateTime{Value: r.Time}
+					}
+					timeFound = true
+					last = r.Time
+				}
+			case isStatusColumn(c):
+				if v, err := monitorValue(value); err == nil {
+					r.Status = enums.ClockStatus(v)
+					value = r.Status
+				}
+			}
+			r.Values[pos] = value
+		}
+		ret.Records = append(ret.Records, r)
+	}
+	return ret, nil
+}
+
+// isCaptureTimeColumn returns true if the column is the clock time.
+func isCaptureTimeColumn(c GXProfileGenericColumn) bool {
+	if c.Target == nil {
+		return false
+	}
+	return c.Target.Base().ObjectType() == enums.ObjectTypeClock && c.AttributeIndex == 2
+}
+
+// isStatusColumn returns true if the column is the clock status or the profile status (0.0.96.10.x.255).
+func isStatusColumn(c GXProfileGenericColumn) bool {
+	if c.Target == nil {
+		return false
+	}
+	if c.Target.Base().ObjectType() == enums.ObjectTypeClock {
+		return c.AttributeIndex == 4
+	}
+	return c.AttributeIndex == 2 && strings.HasPrefix(c.Target.Base().LogicalName(), "0.0.96.10.")
+}
@@ -0,0 +1,356 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// StsEncryptionAlgorithmDea is the Data Encryption Algorithm (EA09).
+const StsEncryptionAlgorithmDea = 9
+
+// errStsCrc is returned when the CRC of the token is invalid.
+var errStsCrc = fmt.Errorf("%w: CRC check failed", dlmserrors.ErrInvalidToken)
+
+// stsBaseDate is the default base date of the token identifier.
+var stsBaseDate = time.Date(1993, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// stsMaxMantissa is the maximum mantissa of the transfer amount.
+const stsMaxMantissa = 1 << 14
+
+// GXStsToken is a 20-digit STS token defined in IEC 62055-41.
+//
+// The 64-bit data block contains the subclass, random number,
+// token identifier, amount and CRC. Credit transfer and meter specific
+// management tokens are encrypted with the decoder key.
+type GXStsToken struct {
+	// Token class.
+	Class enums.StsTokenClass
+	// Token subclass.
+	SubClass uint8
+	// Random number.
+	Random uint8
+	// Token identifier. Minutes from the base date.
+	TokenIdentifier uint32
+	// Transfer amount of credit transfer tokens or the register value of management tokens.
+	Amount uint16
+	// Base date of the token identifier. 1993-01-01 is used if this is not set.
+	// The base date depends on the key revision, for example 2014-01-01.
+	BaseDate time.Time
+}
+
+// baseDate returns the base date of the token identifier.
+func (g *GXStsToken) baseDate() time.Time {
+	if g.BaseDate.IsZero() {
+		return stsBaseDate
+	}
+	return g.BaseDate
+}
+
+// Time returns the time when the token was generated.
+func (g *GXStsToken) Time() time.Time {
+	return g.baseDate().Add(time.Duration(g.TokenIdentifier) * time.Minute)
+}
+
+// SetTime sets the token identifier from the time when the token is generated.
+func (g *GXStsToken) SetTime(value time.Time) error {
+	minutes := value.Sub(g.baseDate()) / time.Minute
+	if minutes < 0 || minutes >= 1<<24 {
+		return fmt.Errorf("%w: token identifier is out of range", dlmserrors.ErrInvalidToken)
+	}
+	g.TokenIdentifier = uint32(minutes)
+	return nil
+}
+
+// Value returns the transfer amount.
+// Electricity is given in 0.1 kWh units.
+func (g *GXStsToken) Value() uint64 {
+	return StsAmountToValue(g.Amount)
+}
+
+// SetValue sets the transfer amount.
+// Value is rounded down if it can't be presented exactly.
+func (g *GXStsToken) SetValue(value uint64) error {
+	amount, err := StsValueToAmount(value)
+	if err != nil {
+		return err
+	}
+	g.Amount = amount
+	return nil
+}
+
+// StsAmountToValue converts the 16-bit amount with 2-bit exponent and 14-bit mantissa to value.
+func StsAmountToValue(amount uint16) uint64 {
+	exponent := int(amount >> 14)
+	ret := uint64(amount & (stsMaxMantissa - 1))
+	offset := uint64(0)
+	multiplier := uint64(1)
+	for pos := 0; pos != exponent; pos++ {
+		offset += stsMaxMantissa * multiplier
+		multiplier *= 10
+	}
+	return ret*multiplier + offset
+}
+
+// StsValueToAmount converts the value to the 16-bit amount with 2-bit exponent and 14-bit mantissa.
+// Value is rounded down if it can't be presented exactly.
+func StsValueToAmount(value uint64) (uint16, error) {
+	offset := uint64(0)
+	multiplier := uint64(1)
+	for exponent := 0; exponent != 4; exponent++ {
+		if value < offset+stsMaxMantissa*multiplier {
+			return uint16(exponent<<14) | uint16((value-offset)/multiplier), nil
+		}
+		offset += stsMaxMantissa * multiplier
+		multiplier *= 10
+	}
+	return 0, fmt.Errorf("%w: transfer amount %d is too big", dlmserrors.ErrInvalidToken, value)
+}
+
+// crc returns the CRC of the token.
+//
+// CRC-16 is counted from the class and the first 48 bits of the data block.
+func (g *GXStsToken) crc() uint16 {
+	data := []byte{byte(g.Class), g.SubClass<<4 | g.Random&0xF,
+		byte(g.TokenIdentifier >> 16), byte(g.TokenIdentifier >> 8), byte(g.TokenIdentifier),
+		byte(g.Amount >> 8), byte(g.Amount)}
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for bit := 0; bit != 8; bit++ {
+			if (crc & 1) != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// isEncrypted returns true if the token class is encrypted.
+func isEncrypted(class enums.StsTokenClass) bool {
+	return class == enums.StsTokenClassCreditTransfer || class == enums.StsTokenClassMeterSpecificManagement
+}
+
+// stsCipher encrypts or decrypts the data block.
+func stsCipher(block []byte, key []byte, algorithm uint8, encrypt bool) error {
+	switch algorithm {
+	case StsEncryptionAlgorithmDea:
+		c, err := des.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if encrypt {
+			c.Encrypt(block, block)
+		} else {
+			c.Decrypt(block, block)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: encryption algorithm %d is not supported", dlmserrors.ErrInvalidToken, algorithm)
+	}
+}
+
+// Encode encrypts the token and returns it as a 20-digit string.
+//
+// Parameters:
+//
+//	key: Decoder key.
+//	algorithm: Encryption algorithm.
+func (g *GXStsToken) Encode(key []byte, algorithm uint8) (string, error) {
+	if g.SubClass > 0xF || g.Random > 0xF || g.TokenIdentifier >= 1<<24 || g.Class > enums.StsTokenClassReserved {
+		return "", fmt.Errorf("%w: token field is out of range", dlmserrors.ErrInvalidToken)
+	}
+	block := make([]byte, 8)
+	block[0] = g.SubClass<<4 | g.Random
+	block[1] = byte(g.TokenIdentifier >> 16)
+	block[2] = byte(g.TokenIdentifier >> 8)
+	block[3] = byte(g.TokenIdentifier)
+	binary.BigEndian.PutUint16(block[4:], g.Amount)
+	binary.BigEndian.PutUint16(block[6:], g.crc())
+	if isEncrypted(g.Class) {
+		if err := stsCipher(block, key, algorithm, true); err != nil {
+			return "", err
+		}
+	}
+	// Class bits are transposed with the bits 28 and 27 of the data block.
+	data := binary.BigEndian.Uint64(block)
+	high := (data >> 27) & 3
+	data = data&^(3<<27) | uint64(g.Class)<<27
+	value := new(big.Int).SetUint64(high)
+	value.Lsh(value, 64)
+	value.Or(value, new(big.Int).SetUint64(data))
+	return fmt.Sprintf("%020s", value.String()), nil
+}
+
+// StsTokenDecode decrypts and decodes the 20-digit token.
+//
+// Spaces and hyphens are ignored. An error is returned if the token format or CRC is invalid.
+//
+// Parameters:
+//
+//	token: Token digits.
+//	key: Decoder key.
+//	algorithm: Encryption algorithm.
+func StsTokenDecode(token string, key []byte, algorithm uint8) (*GXStsToken, error) {
+	token = strings.NewReplacer(" ", "", "-", "").Replace(token)
+	value, ok := new(big.Int).SetString(token, 10)
+	if len(token) != 20 || !ok || value.Sign() < 0 || value.BitLen() > 66 {
+		return nil, fmt.Errorf("%w: token must contain 20 digits", dlmserrors.ErrInvalidToken)
+	}
+	data := new(big.Int).And(value, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	high := new(big.Int).Rsh(value, 64).Uint64()
+	ret := &GXStsToken{Class: enums.StsTokenClass((data >> 27) & 3)}
+	data = data&^(3<<27) | high<<27
+	block := make([]byte, 8)
+	binary.BigEndian.PutUint64(block, data)
+	if isEncrypted(ret.Class) {
+		if err := stsCipher(block, key, algorithm, false); err != nil {
+			return nil, err
+		}
+	}
+	ret.SubClass = block[0] >> 4
+	ret.Random = block[0] & 0xF
+	ret.TokenIdentifier = uint32(block[1])<<16 | uint32(block[2])<<8 | uint32(block[3])
+	ret.Amount = binary.BigEndian.Uint16(block[4:])
+	if binary.BigEndian.Uint16(block[6:]) != ret.crc() {
+		return nil, errStsCrc
+	}
+	return ret, nil
+}
+
+// Descriptions returns the human readable descriptions of the token fields.
+func (g *GXStsToken) Descriptions() []string {
+	ret := []string{
+		fmt.Sprintf("Class: %s", g.Class),
+		fmt.Sprintf("SubClass: %d", g.SubClass),
+		fmt.Sprintf("TID: %d (%s)", g.TokenIdentifier, g.Time().Format("2006-01-02 15:04")),
+	}
+	if g.Class == enums.StsTokenClassCreditTransfer {
+		ret = append(ret, fmt.Sprintf("Amount: %d", g.Value()))
+	} else {
+		ret = append(ret, fmt.Sprintf("Register: %d", g.Amount))
+	}
+	return ret
+}
+
+// String returns the token fields as a string.
+func (g *GXStsToken) String() string {
+	return strings.Join(g.Descriptions(), ", ")
+}
+
+// GXStsTokenDecoder validates the STS tokens entered through the token gateway.
+//
+// Used token identifiers are remembered so the same token can't be entered twice.
+type GXStsTokenDecoder struct {
+	// IEC 62055-41 attributes where the encryption algorithm is read.
+	Attributes *GXDLMSIec6205541Attributes
+	// Decoder key.
+	Key []byte
+	// Maximum amount of remembered token identifiers.
+	MaxUsedTokens int
+	// Base date of the token identifier.
+	BaseDate time.Time
+
+	usedTokens []uint32
+}
+
+// NewGXStsTokenDecoder creates a new STS token decoder.
+func NewGXStsTokenDecoder(attributes *GXDLMSIec6205541Attributes, key []byte) *GXStsTokenDecoder {
+	return &GXStsTokenDecoder{Attributes: attributes, Key: key, MaxUsedTokens: 50}
+}
+
+// Validate decodes and validates the token of the token gateway.
+//
+// Status code, data value and descriptions of the gateway are updated.
+// The token is marked as used when it's valid.
+func (g *GXStsTokenDecoder) Validate(gateway *GXDLMSTokenGateway) (*GXStsToken, error) {
+	gateway.Descriptions = nil
+	gateway.DataValue = ""
+	algorithm := uint8(StsEncryptionAlgorithmDea)
+	if g.Attributes != nil && g.Attributes.EncryptionAlgorithm != 0 {
+		algorithm = g.Attributes.EncryptionAlgorithm
+	}
+	token, err := StsTokenDecode(string(gateway.Token), g.Key, algorithm)
+	if err != nil {
+		if errors.Is(err, errStsCrc) {
+			gateway.StatusCode = enums.TokenStatusCodeAuthenticationFailure
+		} else {
+			gateway.StatusCode = enums.TokenStatusCodeTokenFormatFailure
+		}
+		return nil, err
+	}
+	token.BaseDate = g.BaseDate
+	gateway.Descriptions = token.Descriptions()
+	gateway.DataValue = fmt.Sprintf("%016b", token.Amount)
+	if isEncrypted(token.Class) {
+		for _, it := range g.usedTokens {
+			if it == token.TokenIdentifier {
+				gateway.StatusCode = enums.TokenStatusCodeValidationResultFailure
+				return token, fmt.Errorf("%w: token is already used", dlmserrors.ErrInvalidToken)
+			}
+		}
+		g.usedTokens = append(g.usedTokens, token.TokenIdentifier)
+		if g.MaxUsedTokens > 0 && len(g.usedTokens) > g.MaxUsedTokens {
+			g.usedTokens = g.usedTokens[len(g.usedTokens)-g.MaxUsedTokens:]
+		}
+	}
+	gateway.StatusCode = enums.TokenStatusCodeValidationOk
+	return token, nil
+}
+
+// TokenAmount validates the token and returns the transfer amount.
+// This can be used as a token decoder of the prepayment engine.
+func (g *GXStsTokenDecoder) TokenAmount(gateway *GXDLMSTokenGateway, token []byte) (int32, error) {
+	gateway.Token = token
+	ret, err := g.Validate(gateway)
+	if err != nil {
+		return 0, err
+	}
+	if ret.Class != enums.StsTokenClassCreditTransfer {
+		return 0, fmt.Errorf("%w: token is not a credit transfer token", dlmserrors.ErrInvalidToken)
+	}
+	return int32(ret.Value()), nil
+}
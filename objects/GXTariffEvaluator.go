@@ -0,0 +1,426 @@
+﻿package objects
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/types"
+)
+
+// maxTariffSearchDays is the number of days that are searched when the next switch time is resolved.
+const maxTariffSearchDays = 2 * 366
+
+// GXTariffState describes the active tariff at the given time.
+type GXTariffState struct {
+	// Time when the tariff was evaluated.
+	Time time.Time
+
+	// Passive is true if the passive calendar has been activated at the evaluated time.
+	Passive bool
+
+	// Active season profile.
+	Season *GXDLMSSeasonProfile
+
+	// Active week profile.
+	Week *GXDLMSWeekProfile
+
+	// Special day that overrides the week profile, or nil.
+	SpecialDay *GXDLMSSpecialDay
+
+	// Active day ID.
+	DayID uint8
+
+	// Active day profile action.
+	Action *GXDLMSDayProfileAction
+
+	// Time when the active action was started.
+	ActionStart time.Time
+
+	// Time when the next action is executed. Zero if there are no more actions.
+	NextSwitch time.Time
+}
+
+// GXTariffEvaluator resolves the active season, week, day and script from the activity calendar.
+//
+// Times are handled in the meter local time. The location of the evaluated time is used
+// to build the season, special day and action times.
+type GXTariffEvaluator struct {
+	// Activity calendar.
+	Calendar *GXDLMSActivityCalendar
+
+	// Optional special days table.
+	SpecialDays *GXDLMSSpecialDaysTable
+}
+
+// NewGXTariffEvaluator creates a tariff evaluator.
+//
+// Parameters:
+//
+//	calendar: Activity calendar.
+//	specialDays: Optional special days table.
+func NewGXTariffEvaluator(calendar *GXDLMSActivityCalendar, specialDays *GXDLMSSpecialDaysTable) *GXTariffEvaluator {
+	return &GXTariffEvaluator{Calendar: calendar, SpecialDays: specialDays}
+}
+
+// calendarTables contains the season, week and day profiles of the calendar.
+type calendarTables struct {
+	passive bool
+	seasons []GXDLMSSeasonProfile
+	weeks   []GXDLMSWeekProfile
+	days    []GXDLMSDayProfile
+}
+
+// dayInfo contains the resolved profiles of the day.
+type dayInfo struct {
+	season     *GXDLMSSeasonProfile
+	week       *GXDLMSWeekProfile
+	specialDay *GXDLMSSpecialDay
+	dayID      uint8
+	day        *GXDLMSDayProfile
+}
+
+// Evaluate returns the active tariff at the given time.
+//
+// Parameters:
+//
+//	value: Time in meter local time.
+//
+// Returns:
+//
+//	Active tariff.
+func (g *GXTariffEvaluator) Evaluate(value time.Time) (*GXTariffState, error) {
+	if g.Calendar == nil {
+		return nil, errors.New("activity calendar is not set")
+	}
+	tables := g.tables(value)
+	info, err := g.resolveDay(tables, value)
+	if err != nil {
+		return nil, err
+	}
+	ret := &GXTariffState{
+		Time:       value,
+		Passive:    tables.passive,
+		Season:     info.season,
+		Week:       info.week,
+		SpecialDay: info.specialDay,
+		DayID:      info.dayID,
+	}
+	ret.Action, ret.ActionStart, err = g.activeAction(tables, value)
+	if err != nil {
+		return nil, err
+	}
+	ret.NextSwitch, err = g.nextSwitch(tables, value)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ActivationTime returns the time when the passive calendar is activated.
+// Zero time is returned if the activation time is not specified.
+func (g *GXTariffEvaluator) ActivationTime(location *time.Location) time.Time {
+	t := g.Calendar.Time
+	if t.Value.IsZero() || (t.Skip&(enums.DateTimeSkipsYear|enums.DateTimeSkipsMonth|enums.DateTimeSkipsDay)) != 0 {
+		return time.Time{}
+	}
+	v := t.Value
+	return time.Date(v.Year(), v.Month(), v.Day(), skipValue(t.Skip, enums.DateTimeSkipsHour, v.Hour()),
+		skipValue(t.Skip, enums.DateTimeSkipsMinute, v.Minute()), skipValue(t.Skip, enums.DateTimeSkipsSecond, v.Second()), 0, location)
+}
+
+// tables returns the calendar tables that are used at the given time.
+func (g *GXTariffEvaluator) tables(value time.Time) *calendarTables {
+	activation := g.ActivationTime(value.Location())
+	if !activation.IsZero() && !value.Before(activation) && len(g.Calendar.SeasonProfilePassive) != 0 {
+		return &calendarTables{
+			passive: true,
+			seasons: g.Calendar.SeasonProfilePassive,
+			weeks:   g.Calendar.WeekProfileTablePassive,
+			days:    g.Calendar.DayProfileTablePassive,
+		}
+	}
+	return &calendarTables{
+		seasons: g.Calendar.SeasonProfileActive,
+		weeks:   g.Calendar.WeekProfileTableActive,
+		days:    g.Calendar.DayProfileTableActive,
+	}
+}
+
+// resolveDay returns the season, week and day profile of the given time.
+func (g *GXTariffEvaluator) resolveDay(tables *calendarTables, value time.Time) (*dayInfo, error) {
+	ret := &dayInfo{}
+	if g.SpecialDays != nil {
+		for pos := range g.SpecialDays.Entries {
+			if isSpecialDay(&g.SpecialDays.Entries[pos].Date, value) {
+				ret.specialDay = &g.SpecialDays.Entries[pos]
+				ret.dayID = ret.specialDay.DayId
+				break
+			}
+		}
+	}
+	var start time.Time
+	for pos := range tables.seasons {
+		it := &tables.seasons[pos]
+		t, ok := seasonStart(it, value)
+		if ok && (ret.season == nil || t.After(start)) {
+			ret.season = it
+			start = t
+		}
+	}
+	if ret.season == nil && ret.specialDay == nil {
+		return nil, errors.New("active season profile not found")
+	}
+	if ret.season != nil {
+		for pos := range tables.weeks {
+			if bytes.Equal(tables.weeks[pos].Name, ret.season.WeekName) {
+				ret.week = &tables.weeks[pos]
+				break
+			}
+		}
+		if ret.week == nil && ret.specialDay == nil {
+			return nil, errors.New("week profile not found: " + string(ret.season.WeekName))
+		}
+	}
+	if ret.specialDay == nil {
+		ret.dayID = weekDayID(ret.week, value.Weekday())
+	}
+	for pos := range tables.days {
+		if tables.days[pos].DayID == ret.dayID {
+			ret.day = &tables.days[pos]
+			break
+		}
+	}
+	if ret.day == nil {
+		return nil, errors.New("day profile not found")
+	}
+	return ret, nil
+}
+
+// actionTimes returns the start times of the actions of the day in ascending order.
+func (g *GXTariffEvaluator) actionTimes(tables *calendarTables, day time.Time) ([]time.Time, []*GXDLMSDayProfileAction, error) {
+	info, err := g.resolveDay(tables, day)
+	if err != nil {
+		return nil, nil, err
+	}
+	actions := make([]*GXDLMSDayProfileAction, 0, len(info.day.DaySchedules))
+	for pos := range info.day.DaySchedules {
+		actions = append(actions, &info.day.DaySchedules[pos])
+	}
+	times := make([]time.Time, len(actions))
+	for pos, it := range actions {
+		times[pos] = actionStart(it, day)
+	}
+	sort.Sort(&actionSorter{times: times, actions: actions})
+	return times, actions, nil
+}
+
+// activeAction returns the latest action that has been started before or at the given time.
+// If no action has been started during the day, the last action of the previous days is used.
+// When the passive calendar is activated, the action is started at the activation time.
+func (g *GXTariffEvaluator) activeAction(tables *calendarTables, value time.Time) (*GXDLMSDayProfileAction, time.Time, error) {
+	day := startOfDay(value)
+	for i := 0; i != 8; i++ {
+		times, actions, err := g.actionTimes(tables, day)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		for pos := len(times) - 1; pos >= 0; pos-- {
+			if !times[pos].After(value) {
+				start := times[pos]
+				if activation := g.ActivationTime(value.Location()); tables.passive && start.Before(activation) {
+					start = activation
+				}
+				return actions[pos], start, nil
+			}
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return nil, time.Time{}, nil
+}
+
+// nextSwitch returns the next time when an action is executed or the passive calendar is activated.
+func (g *GXTariffEvaluator) nextSwitch(tables *calendarTables, value time.Time) (time.Time, error) {
+	activation := g.ActivationTime(value.Location())
+	if !activation.After(value) {
+		activation = time.Time{}
+	}
+	day := startOfDay(value)
+	for i := 0; i != maxTariffSearchDays; i++ {
+		if !activation.IsZero() && activation.Before(day) {
+			return activation, nil
+		}
+		times, _, err := g.actionTimes(tables, day)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, it := range times {
+			if it.After(value) {
+				if !activation.IsZero() && activation.Before(it) {
+					return activation, nil
+				}
+				return it, nil
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return activation, nil
+}
+
+// actionSorter sorts the actions by the start time.
+type actionSorter struct {
+	times   []time.Time
+	actions []*GXDLMSDayProfileAction
+}
+
+func (s *actionSorter) Len() int {
+	return len(s.times)
+}
+
+func (s *actionSorter) Less(i, j int) bool {
+	return s.times[i].Before(s.times[j])
+}
+
+func (s *actionSorter) Swap(i, j int) {
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+	s.actions[i], s.actions[j] = s.actions[j], s.actions[i]
+}
+
+// startOfDay returns the midnight of the given day.
+func startOfDay(value time.Time) time.Time {
+	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
+}
+
+// skipValue returns zero if the field is skipped.
+func skipValue(skip enums.DateTimeSkips, field enums.DateTimeSkips, value int) int {
+	if (skip & field) != 0 {
+		return 0
+	}
+	return value
+}
+
+// actionStart returns the start time of the action at the given day.
+func actionStart(action *GXDLMSDayProfileAction, day time.Time) time.Time {
+	t := &action.StartTime
+	return time.Date(day.Year(), day.Month(), day.Day(), skipValue(t.Skip, enums.DateTimeSkipsHour, t.Value.Hour()),
+		skipValue(t.Skip, enums.DateTimeSkipsMinute, t.Value.Minute()),
+		skipValue(t.Skip, enums.DateTimeSkipsSecond, t.Value.Second()), 0, day.Location())
+}
+
+// seasonStart returns the latest start time of the season that is before or at the given time.
+// Season is repeated every year if the year is not specified.
+func seasonStart(season *GXDLMSSeasonProfile, value time.Time) (time.Time, bool) {
+	s := &season.Start
+	build := func(year int) time.Time {
+		month := time.January
+		if (s.Skip & enums.DateTimeSkipsMonth) == 0 {
+			month = s.Value.Month()
+		}
+		day := 1
+		if (s.Skip & enums.DateTimeSkipsDay) == 0 {
+			day = s.Value.Day()
+		}
+		return time.Date(year, month, day, skipValue(s.Skip, enums.DateTimeSkipsHour, s.Value.Hour()),
+			skipValue(s.Skip, enums.DateTimeSkipsMinute, s.Value.Minute()),
+			skipValue(s.Skip, enums.DateTimeSkipsSecond, s.Value.Second()), 0, value.Location())
+	}
+	if (s.Skip & enums.DateTimeSkipsYear) == 0 {
+		t := build(s.Value.Year())
+		return t, !t.After(value)
+	}
+	t := build(value.Year())
+	if t.After(value) {
+		t = build(value.Year() - 1)
+	}
+	return t, true
+}
+
+// isSpecialDay returns true if the special day date matches the given time.
+func isSpecialDay(date *types.GXDate, value time.Time) bool {
+	if (date.Skip&enums.DateTimeSkipsYear) == 0 && date.Value.Year() != value.Year() {
+		return false
+	}
+	if (date.Skip&enums.DateTimeSkipsMonth) == 0 && date.Value.Month() != value.Month() {
+		return false
+	}
+	lastDay := time.Date(value.Year(), value.Month()+1, 0, 0, 0, 0, 0, value.Location()).Day()
+	switch {
+	case (date.Extra & enums.DateTimeExtraInfoLastDay) != 0:
+		if value.Day() != lastDay {
+			return false
+		}
+	case (date.Extra & enums.DateTimeExtraInfoLastDay2) != 0:
+		if value.Day() != lastDay-1 {
+			return false
+		}
+	case (date.Skip&enums.DateTimeSkipsDay) == 0 && date.Value.Day() != value.Day():
+		return false
+	}
+	if (date.Skip&enums.DateTimeSkipsDayOfWeek) == 0 && date.DayOfWeek > 0 && date.DayOfWeek < 8 &&
+		date.DayOfWeek != cosemDayOfWeek(value.Weekday()) {
+		return false
+	}
+	return true
+}
+
+// cosemDayOfWeek converts the week day to COSEM day of week (1 = Monday, 7 = Sunday).
+func cosemDayOfWeek(value time.Weekday) int {
+	if value == time.Sunday {
+		return 7
+	}
+	return int(value)
+}
+
+// weekDayID returns the day ID of the week day.
+func weekDayID(week *GXDLMSWeekProfile, value time.Weekday) uint8 {
+	switch value {
+	case time.Monday:
+		return week.Monday
+	case time.Tuesday:
+		return week.Tuesday
+	case time.Wednesday:
+		return week.Wednesday
+	case time.Thursday:
+		return week.Thursday
+	case time.Friday:
+		return week.Friday
+	case time.Saturday:
+		return week.Saturday
+	}
+	return week.Sunday
+}
@@ -37,7 +37,10 @@ package settings
 import (
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"log"
+	"math"
+	"sort"
 
 	"github.com/Gurux/gxdlms-go/enums"
 	"github.com/Gurux/gxdlms-go/internal/constants"
@@ -54,6 +57,18 @@ type GXDLMSSettings struct {
 	// gbtWindowSize is the General Block Transfer window size.
 	gbtWindowSize uint8
 
+	// gbtMaxRetries is the maximum amount of General Block Transfer retransmissions.
+	gbtMaxRetries uint8
+
+	// gbtRetries is the amount of General Block Transfer retransmissions in the current transfer.
+	gbtRetries uint8
+
+	// gbtBlocks are the sent General Block Transfer blocks that peer has not acknowledged.
+	gbtBlocks map[uint16][]byte
+
+	// gbtPeerWindowSize is the General Block Transfer window size of the peer.
+	gbtPeerWindowSize uint8
+
 	// assignedAssociation is the assigned association for the server.
 	assignedAssociation any
 
@@ -79,6 +94,9 @@ type GXDLMSSettings struct {
 	// ReceiverFrame is the HDLC receiver frame sequence number.
 	ReceiverFrame uint8
 
+	// receivedFrames is the amount of I-frames received after the last sent frame.
+	receivedFrames uint8
+
 	sourceSystemTitle []byte
 
 	// ClientPublicKeyCertificate is the optional ECDSA public key certificate that is sent in part of AARQ.
@@ -269,6 +287,9 @@ type GXDLMSSettings struct {
 
 	// customPdu is the event invoked when custom PDU is handled.
 	CustomPdu CustomPduEventHandler
+
+	// Trace is called when a frame is sent or received.
+	Trace IGXDLMSTrace
 }
 
 // NewGXDLMSSettings creates a new DLMS settings instance with default values.
@@ -308,6 +329,7 @@ func NewGXDLMSSettingsWithParams(server bool, ln bool, interfaceType enums.Inter
 		maxReceivePDUSize:  defaultMaxReceivePDUSize,
 		isServer:           server,
 		gbtWindowSize:      1,
+		gbtMaxRetries:      3,
 		UserID:             -1,
 		Standard:           enums.StandardDLMS,
 		challengeSize:      16,
@@ -417,6 +439,61 @@ func (s *GXDLMSSettings) ResetFrameSequence() {
 		s.SenderFrame = 0xFE
 		s.ReceiverFrame = 0xE
 	}
+	s.receivedFrames = 0
+	if s.Hdlc != nil {
+		s.Hdlc.ClearUnacknowledged()
+	}
+}
+
+// windowSizeRX returns the HDLC window size in receive.
+func (s *GXDLMSSettings) windowSizeRX() uint8 {
+	if s.Hdlc == nil {
+		return 1
+	}
+	return s.Hdlc.WindowSizeRX()
+}
+
+// windowSizeTX returns the HDLC window size in transmit.
+func (s *GXDLMSSettings) windowSizeTX() uint8 {
+	if s.Hdlc == nil {
+		return 1
+	}
+	return s.Hdlc.WindowSizeTX()
+}
+
+// acceptFrame updates the receiver sequence when I-frame is accepted.
+func (s *GXDLMSSettings) acceptFrame(frame uint8) bool {
+	s.ReceiverFrame = frame
+	s.receivedFrames++
+	return true
+}
+
+// isWindowAcknowledged returns true if I-frame acknowledges the sent window.
+// When the window size is bigger than one, peer acknowledges all the frames
+// in the window with one N(R) and it can be up to window size frames ahead.
+func (s *GXDLMSSettings) isWindowAcknowledged(frame uint8) bool {
+	if s.windowSizeTX() < 2 {
+		return false
+	}
+	if (frame & 0xE) != (IncreaseSendSequence(s.ReceiverFrame) & 0xE) {
+		return false
+	}
+	diff := ((frame >> 5) - (s.ReceiverFrame >> 5)) & 0x7
+	return diff != 0 && diff <= s.windowSizeTX()
+}
+
+// nextReceiverSequence increases N(R) with the amount of the received frames.
+func (s *GXDLMSSettings) nextReceiverSequence(value uint8) uint8 {
+	count := s.receivedFrames
+	if count == 0 {
+		// Reply is expected before this frame is sent.
+		count = 1
+	}
+	for ; count != 0; count-- {
+		value = IncreaseReceiverSequence(value)
+	}
+	s.receivedFrames = 0
+	return value
 }
 
 // CheckFrame checks if the frame is valid.
@@ -444,7 +521,12 @@ func (s *GXDLMSSettings) CheckFrame(frame uint8, xml interface{}) bool {
 		if frame == (s.SenderFrame & 0xF1) {
 			return false
 		}
-		s.ReceiverFrame = IncreaseReceiverSequence(s.ReceiverFrame)
+		if s.windowSizeTX() < 2 {
+			s.ReceiverFrame = IncreaseReceiverSequence(s.ReceiverFrame)
+		} else {
+			// Peer acknowledges all the received frames from the window.
+			s.ReceiverFrame = frame&0xE0 | 0x10 | s.ReceiverFrame&0xE
+		}
 		return true
 	}
 	// Handle I-frame.
@@ -452,43 +534,40 @@ func (s *GXDLMSSettings) CheckFrame(frame uint8, xml interface{}) bool {
 	if (s.SenderFrame & 0x1) == 0 {
 		expected = IncreaseReceiverSequence(IncreaseSendSequence(s.ReceiverFrame))
 		if frame == expected {
-			s.ReceiverFrame = frame
-			return true
+			return s.acceptFrame(frame)
 		}
 		// If the final bit is not set.
-		if frame == (expected & ^uint8(0x10)) && s.Hdlc.WindowSizeRX() != 1 {
-			s.ReceiverFrame = frame
-			return true
+		if frame == (expected & ^uint8(0x10)) && s.windowSizeRX() != 1 {
+			return s.acceptFrame(frame)
+		}
+		// If peer acknowledges the whole sent window.
+		if s.isWindowAcknowledged(frame) {
+			return s.acceptFrame(frame)
 		}
 		// If Final bit is not set for the previous message.
-		if (s.ReceiverFrame&0x10) == 0 && s.Hdlc.WindowSizeRX() != 1 {
+		if (s.ReceiverFrame&0x10) == 0 && s.windowSizeRX() != 1 {
 			expected = 0x10 | IncreaseSendSequence(s.ReceiverFrame)
 			if frame == expected {
-				s.ReceiverFrame = frame
-				return true
+				return s.acceptFrame(frame)
 			}
 			// If the final bit is not set.
 			if frame == (expected & ^uint8(0x10)) {
-				s.ReceiverFrame = frame
-				return true
+				return s.acceptFrame(frame)
 			}
 		}
 	} else {
 		// If answer for RR.
 		expected = IncreaseSendSequence(s.ReceiverFrame)
 		if frame == expected {
-			s.ReceiverFrame = frame
-			return true
+			return s.acceptFrame(frame)
 		}
 		if frame == (expected & ^uint8(0x10)) {
-			s.ReceiverFrame = frame
-			return true
+			return s.acceptFrame(frame)
 		}
-		if s.Hdlc.WindowSizeRX() != 1 {
+		if s.windowSizeRX() != 1 {
 			// If HDLC window size is bigger than one.
 			if frame == (expected | 0x10) {
-				s.ReceiverFrame = frame
-				return true
+				return s.acceptFrame(frame)
 			}
 		}
 	}
@@ -503,9 +582,10 @@ func (s *GXDLMSSettings) CheckFrame(frame uint8, xml interface{}) bool {
 }
 
 // NextSend generates I-frame.
+// N(R) acknowledges all the I-frames that are received after the last sent frame.
 func (s *GXDLMSSettings) NextSend(first bool) uint8 {
 	if first {
-		s.SenderFrame = IncreaseReceiverSequence(IncreaseSendSequence(s.SenderFrame))
+		s.SenderFrame = s.nextReceiverSequence(IncreaseSendSequence(s.SenderFrame))
 	} else {
 		s.SenderFrame = IncreaseSendSequence(s.SenderFrame)
 	}
@@ -513,8 +593,9 @@ func (s *GXDLMSSettings) NextSend(first bool) uint8 {
 }
 
 // ReceiverReady generates Receiver Ready S-frame.
+// N(R) acknowledges all the I-frames that are received after the last sent frame.
 func (s *GXDLMSSettings) ReceiverReady() uint8 {
-	s.SenderFrame = IncreaseReceiverSequence(s.SenderFrame) | 1
+	s.SenderFrame = s.nextReceiverSequence(s.SenderFrame) | 1
 	return s.SenderFrame & 0xF1
 }
 
@@ -528,6 +609,9 @@ func (s *GXDLMSSettings) KeepAlive() uint8 {
 func (s *GXDLMSSettings) ResetBlockIndex() {
 	s.BlockIndex = s.StartingBlockIndex
 	s.BlockNumberAck = 0
+	s.gbtRetries = 0
+	s.gbtBlocks = nil
+	s.gbtPeerWindowSize = 0
 }
 
 // IncreaseBlockIndex increases block index.
@@ -549,6 +633,103 @@ func (s *GXDLMSSettings) SetGbtWindowSize(value uint8) error {
 	return nil
 }
 
+// GbtMaxRetries returns the maximum amount of General Block Transfer retransmissions.
+// Transfer is aborted if lost blocks are not received after the retransmissions.
+func (s *GXDLMSSettings) GbtMaxRetries() uint8 {
+	return s.gbtMaxRetries
+}
+
+// SetGbtMaxRetries sets the maximum amount of General Block Transfer retransmissions.
+func (s *GXDLMSSettings) SetGbtMaxRetries(value uint8) {
+	s.gbtMaxRetries = value
+}
+
+// IncreaseGbtRetries increases the amount of General Block Transfer retransmissions.
+// Error is returned if the maximum amount of retransmissions is exceeded.
+func (s *GXDLMSSettings) IncreaseGbtRetries() error {
+	if s.gbtRetries >= s.gbtMaxRetries {
+		return fmt.Errorf("general block transfer failed after %d retransmissions", s.gbtRetries)
+	}
+	s.gbtRetries++
+	return nil
+}
+
+// ResetGbtRetries resets the amount of General Block Transfer retransmissions.
+// This is called when the next expected block is received.
+func (s *GXDLMSSettings) ResetGbtRetries() {
+	s.gbtRetries = 0
+}
+
+// AddGbtBlock adds sent General Block Transfer block. Block is kept until peer acknowledges it.
+//
+// Parameters:
+//
+//	blockNumber: Block number.
+//	pdu: Sent PDU.
+func (s *GXDLMSSettings) AddGbtBlock(blockNumber uint16, pdu []byte) {
+	if s.gbtBlocks == nil {
+		s.gbtBlocks = make(map[uint16][]byte)
+	}
+	s.gbtBlocks[blockNumber] = pdu
+}
+
+// AcknowledgeGbtBlocks removes the sent General Block Transfer blocks that peer has acknowledged.
+//
+// Parameters:
+//
+//	blockNumberAck: The last block number that peer has received.
+//	windowSize: Peer's window size.
+//
+// Returns:
+//
+//	True, if peer has not received all the blocks from the window.
+func (s *GXDLMSSettings) AcknowledgeGbtBlocks(blockNumberAck uint16, windowSize uint8) bool {
+	if len(s.gbtBlocks) == 0 {
+		return false
+	}
+	if windowSize == 0 {
+		windowSize = 1
+	}
+	s.gbtPeerWindowSize = windowSize
+	// The last block of the window that peer acknowledges.
+	last := s.firstGbtBlock() + uint16(windowSize) - 1
+	for k := range s.gbtBlocks {
+		if k <= blockNumberAck {
+			delete(s.gbtBlocks, k)
+		}
+	}
+	return len(s.gbtBlocks) != 0 && s.firstGbtBlock() <= last
+}
+
+// firstGbtBlock returns the oldest General Block Transfer block number that peer has not acknowledged.
+func (s *GXDLMSSettings) firstGbtBlock() uint16 {
+	first := uint16(math.MaxUint16)
+	for k := range s.gbtBlocks {
+		if k < first {
+			first = k
+		}
+	}
+	return first
+}
+
+// GbtBlocks returns the sent General Block Transfer blocks from the window that peer has not acknowledged.
+// Blocks are returned in block number order.
+func (s *GXDLMSSettings) GbtBlocks() [][]byte {
+	keys := make([]int, 0, len(s.gbtBlocks))
+	for k := range s.gbtBlocks {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+	if s.gbtPeerWindowSize != 0 && len(keys) > int(s.gbtPeerWindowSize) {
+		keys = keys[:s.gbtPeerWindowSize]
+	}
+	ret := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		ret = append(ret, s.gbtBlocks[uint16(k)])
+	}
+	return ret
+}
+
 // MaxPduSize returns the maximum PDU size.
 func (s *GXDLMSSettings) MaxPduSize() uint16 {
 	return s.maxReceivePDUSize
@@ -697,8 +878,10 @@ func (s *GXDLMSSettings) CopyTo(target *GXDLMSSettings) {
 	//     s.Cipher.(*GXCiphering).CopyTo(target.Cipher.(*GXCiphering))
 	// }
 	target.UserID = s.UserID
+	target.Trace = s.Trace
 	target.UseUtc2NormalTime = s.UseUtc2NormalTime
 	target.gbtWindowSize = s.gbtWindowSize
+	target.gbtMaxRetries = s.gbtMaxRetries
 	// TODO: Copy Objects when implemented
 	// target.Objects.Clear()
 	// target.Objects.AddRange(s.Objects)
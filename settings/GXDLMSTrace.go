@@ -0,0 +1,73 @@
+﻿package settings
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import "github.com/Gurux/gxdlms-go/enums"
+
+// IGXDLMSTrace receives the sent and received frames.
+// Known passwords and keys are masked before the trace is called.
+type IGXDLMSTrace interface {
+	// Trace is called when a frame is sent or received.
+	//
+	// Parameters:
+	//
+	//	e: Trace arguments.
+	Trace(e *GXDLMSTraceEventArgs)
+}
+
+// GXDLMSTraceEventArgs describes the traced frame.
+type GXDLMSTraceEventArgs struct {
+	// Is the frame sent or received.
+	Direction enums.TraceDirection
+
+	// Is the frame sent or received by the server.
+	Server bool
+
+	// Used interface type.
+	InterfaceType enums.InterfaceType
+
+	// Raw frame.
+	Frame []byte
+
+	// Decrypted APDU. PDU is set on the first sent frame and on the
+	// received frame that completes the PDU. Otherwise it's nil.
+	Pdu []byte
+
+	// Command of the PDU.
+	Command enums.Command
+
+	// Invoke ID of the PDU.
+	InvokeID uint32
+}
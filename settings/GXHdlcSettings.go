@@ -47,6 +47,9 @@ type GXHdlcSettings struct {
 
 	windowSizeTX uint8
 	windowSizeRX uint8
+
+	// unacknowledged are the sent I-frames that the peer has not acknowledged yet.
+	unacknowledged [][]byte
 }
 
 // MaxInfoTX returns the the maximum information field length in transmit.
@@ -105,6 +108,72 @@ func (g *GXHdlcSettings) SetWindowSizeRX(value uint8) error {
 	return nil
 }
 
+// AddUnacknowledged adds sent I-frame to the retransmission queue.
+func (g *GXHdlcSettings) AddUnacknowledged(frame []byte) {
+	g.unacknowledged = append(g.unacknowledged, frame)
+}
+
+// Acknowledge removes the I-frames that the peer has acknowledged with N(R).
+//
+// Parameters:
+//
+//	nr: Receive sequence number N(R) from the received frame.
+func (g *GXHdlcSettings) Acknowledge(nr uint8) {
+	nr &= 0x7
+	// Peer can acknowledge only frames that are inside of the window.
+	count := len(g.unacknowledged)
+	if count > 8 {
+		count = 8
+	}
+	for pos := 0; pos != count; pos++ {
+		if hdlcSendSequence(g.unacknowledged[pos]) == nr {
+			g.unacknowledged = g.unacknowledged[pos:]
+			return
+		}
+	}
+	// All the frames in the window are acknowledged if N(R) is next after the last one.
+	if count != 0 && (hdlcSendSequence(g.unacknowledged[count-1])+1)&0x7 == nr {
+		g.unacknowledged = g.unacknowledged[count:]
+	}
+}
+
+// Unacknowledged returns the I-frames that must be sent again.
+// Frames are returned from the oldest unacknowledged frame to the frame where the poll bit is set.
+func (g *GXHdlcSettings) Unacknowledged() [][]byte {
+	for pos, it := range g.unacknowledged {
+		if hdlcControl(it)&0x10 != 0 {
+			return g.unacknowledged[:pos+1]
+		}
+	}
+	return g.unacknowledged
+}
+
+// ClearUnacknowledged clears the retransmission queue.
+func (g *GXHdlcSettings) ClearUnacknowledged() {
+	g.unacknowledged = nil
+}
+
+// hdlcControl returns the control field of the HDLC frame.
+func hdlcControl(frame []byte) uint8 {
+	// BOP, format (2 bytes), destination address and source address.
+	pos := 3
+	// Address is ended when the lowest bit is set.
+	for addresses := 0; addresses != 2 && pos < len(frame); pos++ {
+		if frame[pos]&0x1 != 0 {
+			addresses++
+		}
+	}
+	if pos < len(frame) {
+		return frame[pos]
+	}
+	return 0
+}
+
+// hdlcSendSequence returns the send sequence number N(S) of the HDLC frame.
+func hdlcSendSequence(frame []byte) uint8 {
+	return (hdlcControl(frame) >> 1) & 0x7
+}
+
 func NewGXHdlcSettings() *GXHdlcSettings {
 	return &GXHdlcSettings{
 		maxInfoTX:    128,
@@ -48,6 +48,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/Gurux/gxdlms-go/dlmserrors"
 	"github.com/Gurux/gxdlms-go/enums"
 	"github.com/Gurux/gxdlms-go/types"
 )
@@ -365,14 +366,14 @@ func DecryptAesGcm(p *AesGcmParameter, data *types.GXByteBuffer) ([]byte, error)
 		aad := buildAAD(sc, p.AuthenticationKey(), plain, enums.SecurityAuthentication)
 		expected := gcm.Seal(nil, nonce, nil, aad)
 		if !compareTag(tag, expected) {
-			return nil, errors.New("invalid authentication tag")
+			return nil, dlmserrors.ErrInvalidAuthenticationTag
 		}
 		return plain, nil
 	case enums.SecurityEncryption, enums.SecurityAuthenticationEncryption:
 		aad := buildAAD(sc, p.AuthenticationKey(), nil, p.Security())
 		plain, err := gcm.Open(nil, nonce, payload, aad)
 		if err != nil {
-			return nil, err
+			return nil, dlmserrors.ErrInvalidAuthenticationTag
 		}
 		return plain, nil
 	default:
@@ -591,7 +591,7 @@ func getBytes(bb *GXByteBuffer, target any) (int, error) {
 			}
 			cnt += n
 
-			tmp := GXByteBuffer{}
+			tmp = GXByteBuffer{}
 			tmp.SetUint8(byte(constants.BerTypeConstructed | constants.BerTypeSequence))
 			SetObjectCount(cnt, &tmp)
 			tmp.SetByteBuffer(&tmp2)
@@ -659,6 +659,8 @@ func getBytes(bb *GXByteBuffer, target any) (int, error) {
 		cnt += bb.Size() - start2
 		bb.SetByteBuffer(tmp)
 		return cnt, nil
+	case GXAsn1Sequence:
+		return getBytes(bb, &v)
 	default:
 		return 0, fmt.Errorf("invalid type: %T", target)
 	}
@@ -37,8 +37,6 @@ package types
 import (
 	"fmt"
 	"math/big"
-
-	"github.com/Gurux/gxdlms-go/internal/buffer"
 )
 
 // GXAsn1Integer stores an ASN.1 INTEGER value in encoded byte order.
@@ -57,8 +55,11 @@ func NewGXAsn1Integer(value []byte) *GXAsn1Integer {
 // NewGXAsn1IntegerFromBigInteger creates an ASN.1 integer from a big.Int value.
 func NewGXAsn1IntegerFromBigInteger(value big.Int) *GXAsn1Integer {
 	g := &GXAsn1Integer{}
-	g.value = value.Bytes()
-	buffer.Reverse(g.value)
+	// ASN.1 INTEGER is big-endian two's complement value.
+	g.value = append([]byte{0}, value.Bytes()...)
+	if len(g.value) != 1 && g.value[1] < 0x80 {
+		g.value = g.value[1:]
+	}
 	return g
 }
 
@@ -69,10 +70,7 @@ func (g *GXAsn1Integer) Value() []byte {
 
 // ToBigInteger returns integer value as big integer.
 func (g *GXAsn1Integer) ToBigInteger() *big.Int {
-	tmp := make([]byte, len(g.value))
-	copy(tmp, g.value)
-	buffer.Reverse(tmp)
-	return new(big.Int).SetBytes(tmp)
+	return new(big.Int).SetBytes(g.value)
 }
 
 // ToByte returns integer value as byte.
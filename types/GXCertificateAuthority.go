@@ -0,0 +1,389 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+	"github.com/Gurux/gxdlms-go/internal/helpers"
+)
+
+// GXCertificateAuthority is a local certificate authority that issues DLMS certificates.
+//
+// Certificate authority signs PKCS #10 certificate signing requests into X.509
+// certificates that follow the DLMS certificate profile without the Gurux certificate server.
+// The system title of the device is the Common Name of the subject.
+type GXCertificateAuthority struct {
+	// Private key of the certificate authority.
+	key *GXPkcs8
+
+	// Certificate of the certificate authority.
+	certificate *GXx509Certificate
+
+	// Chain contains the certificates of the upper level certificate authorities.
+	// The first item is the issuer of this certificate authority and the last item is the root.
+	Chain []*GXx509Certificate
+
+	// Validity is the validity period of the issued certificates.
+	// The issued certificate is never valid longer than the certificate of the certificate authority.
+	Validity time.Duration
+}
+
+// NewGXCertificateAuthority creates a certificate authority from the private key and the certificate.
+//
+// Parameters:
+//
+//	key: Private key of the certificate authority.
+//	certificate: Certificate of the certificate authority.
+func NewGXCertificateAuthority(key *GXPkcs8, certificate *GXx509Certificate) (*GXCertificateAuthority, error) {
+	if key == nil || key.PrivateKey() == nil {
+		return nil, errors.New("certificate authority private key is not set")
+	}
+	if certificate == nil || certificate.PublicKey == nil {
+		return nil, errors.New("certificate authority certificate is not set")
+	}
+	pub := key.PublicKey()
+	if pub == nil {
+		var err error
+		pub, err = PublicKeyFromECDSAPrivate(key.PrivateKey())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !helpers.Compare(PublicKeyToBytes(pub), PublicKeyToBytes(certificate.PublicKey)) {
+		return nil, errors.New("private key doesn't match to the certificate authority certificate")
+	}
+	if (certificate.KeyUsage & enums.KeyUsageKeyCertSign) == 0 {
+		return nil, errors.New("certificate authority certificate can't be used to sign certificates")
+	}
+	return &GXCertificateAuthority{
+		key:         key,
+		certificate: certificate,
+		Validity:    10 * 365 * 24 * time.Hour,
+	}, nil
+}
+
+// CertificateAuthorityLoad loads the private key and the certificate of the certificate authority from PEM files.
+//
+// Parameters:
+//
+//	keyPath: Path to the PKCS #8 private key.
+//	certificatePath: Path to the X.509 certificate.
+func CertificateAuthorityLoad(keyPath string, certificatePath string) (*GXCertificateAuthority, error) {
+	key, err := Pkcs8Load(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := X509CertificateLoad(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewGXCertificateAuthority(key, cert)
+}
+
+// CertificateAuthorityCreateRoot creates a self-signed root certificate authority.
+//
+// Parameters:
+//
+//	key: Private key of the root.
+//	subject: Subject of the root. Example: "CN=Root, O=Gurux, C=FI".
+//	validFrom: Start of the validity period.
+//	validTo: End of the validity period.
+func CertificateAuthorityCreateRoot(key *GXPkcs8, subject string, validFrom time.Time, validTo time.Time) (*GXCertificateAuthority, error) {
+	if key == nil || key.PrivateKey() == nil {
+		return nil, errors.New("certificate authority private key is not set")
+	}
+	pub, err := PublicKeyFromECDSAPrivate(key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	cert := newCertificateAuthorityCertificate(pub, subject, validFrom, validTo)
+	cert.Issuer = subject
+	cert.AuthorityKeyIdentifier = cert.SubjectKeyIdentifier
+	cert, err = signCertificate(cert, key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	return NewGXCertificateAuthority(key, cert)
+}
+
+// Key returns the private key of the certificate authority.
+func (g *GXCertificateAuthority) Key() *GXPkcs8 {
+	return g.key
+}
+
+// Certificate returns the certificate of the certificate authority.
+func (g *GXCertificateAuthority) Certificate() *GXx509Certificate {
+	return g.certificate
+}
+
+// CreateIntermediate creates a certificate authority that is certified by this certificate authority.
+//
+// Parameters:
+//
+//	key: Private key of the intermediate certificate authority.
+//	subject: Subject of the intermediate certificate authority.
+func (g *GXCertificateAuthority) CreateIntermediate(key *GXPkcs8, subject string) (*GXCertificateAuthority, error) {
+	if key == nil || key.PrivateKey() == nil {
+		return nil, errors.New("certificate authority private key is not set")
+	}
+	pub, err := PublicKeyFromECDSAPrivate(key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	from, to := g.validity()
+	cert := newCertificateAuthorityCertificate(pub, subject, from, to)
+	g.setIssuer(cert)
+	cert, err = signCertificate(cert, g.key.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+	ret, err := NewGXCertificateAuthority(key, cert)
+	if err != nil {
+		return nil, err
+	}
+	ret.Chain = append([]*GXx509Certificate{g.certificate}, g.Chain...)
+	ret.Validity = g.Validity
+	return ret, nil
+}
+
+// Sign signs the certificate signing request into the DLMS certificate.
+//
+// Parameters:
+//
+//	request: PKCS #10 certificate signing request. Common Name of the subject is the system title.
+//	certificateType: Certificate type.
+//	extendedKeyUsage: Extended key usage. This is used only with TLS certificates.
+//
+// Returns:
+//
+//	Signed X.509 certificate.
+func (g *GXCertificateAuthority) Sign(request *GXPkcs10, certificateType enums.CertificateType, extendedKeyUsage enums.ExtendedKeyUsage) (*GXx509Certificate, error) {
+	if request == nil || request.PublicKey() == nil {
+		return nil, errors.New("certificate signing request is not set")
+	}
+	systemTitle, err := certificateSystemTitle(request.Subject())
+	if err != nil {
+		return nil, err
+	}
+	cert := &GXx509Certificate{
+		version:          enums.CertificateVersionVersion3,
+		PublicKey:        request.PublicKey(),
+		Subject:          Asn1SystemTitleToSubject(systemTitle),
+		ExtendedKeyUsage: extendedKeyUsage,
+	}
+	switch certificateType {
+	case enums.CertificateTypeDigitalSignature:
+		cert.KeyUsage = enums.KeyUsageDigitalSignature
+	case enums.CertificateTypeKeyAgreement:
+		cert.KeyUsage = enums.KeyUsageKeyAgreement
+	case enums.CertificateTypeTLS:
+		cert.KeyUsage = enums.KeyUsageDigitalSignature | enums.KeyUsageKeyAgreement
+		if extendedKeyUsage == enums.ExtendedKeyUsageNone {
+			return nil, errors.New("extended key usage is mandatory for TLS certificate")
+		}
+	default:
+		return nil, errors.New("invalid certificate type")
+	}
+	if certificateType != enums.CertificateTypeTLS && extendedKeyUsage != enums.ExtendedKeyUsageNone {
+		return nil, errors.New("extended key usage is used only with TLS certificate")
+	}
+	cert.SubjectKeyIdentifier = subjectKeyIdentifier(cert.PublicKey)
+	cert.ValidFrom, cert.ValidTo = g.validity()
+	g.setIssuer(cert)
+	return signCertificate(cert, g.key.PrivateKey())
+}
+
+// SignRequests signs the certificate signing requests.
+// This can be used instead of GXPkcs10.GetCertificate when the certificate server is not available.
+//
+// Parameters:
+//
+//	requests: Certificate signing requests with the certificate types.
+//
+// Returns:
+//
+//	Signed X.509 certificates in the same order as the requests.
+func (g *GXCertificateAuthority) SignRequests(requests []GXCertificateRequest) ([]GXx509Certificate, error) {
+	certs := make([]GXx509Certificate, 0, len(requests))
+	for _, it := range requests {
+		cert, err := g.Sign(it.Certificate, it.CertificateType, it.ExtendedKeyUsage)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, *cert)
+	}
+	return certs, nil
+}
+
+// GetChain returns the certificate chain from the certificate to the root certificate authority.
+//
+// Parameters:
+//
+//	certificate: Certificate issued by this certificate authority.
+func (g *GXCertificateAuthority) GetChain(certificate *GXx509Certificate) ([]*GXx509Certificate, error) {
+	if certificate == nil {
+		return nil, errors.New("certificate is not set")
+	}
+	ok, err := certificate.IsCertified(g.certificate.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("certificate is not issued by this certificate authority")
+	}
+	ret := []*GXx509Certificate{certificate, g.certificate}
+	return append(ret, g.Chain...), nil
+}
+
+// validity returns the validity period of the issued certificate.
+func (g *GXCertificateAuthority) validity() (time.Time, time.Time) {
+	// ASN.1 UTC time doesn't include fractions of the second.
+	from := time.Now().UTC().Truncate(time.Second)
+	to := from.Add(g.Validity)
+	if !g.certificate.ValidTo.IsZero() && to.After(g.certificate.ValidTo) {
+		to = g.certificate.ValidTo.UTC()
+	}
+	return from, to
+}
+
+// setIssuer sets the issuer information of the certificate authority to the issued certificate.
+func (g *GXCertificateAuthority) setIssuer(cert *GXx509Certificate) {
+	cert.Issuer = g.certificate.Subject
+	cert.AuthorityKeyIdentifier = g.certificate.SubjectKeyIdentifier
+	if len(cert.AuthorityKeyIdentifier) == 0 {
+		cert.AuthorityKeyIdentifier = subjectKeyIdentifier(g.certificate.PublicKey)
+	}
+}
+
+// newCertificateAuthorityCertificate returns a certificate that can be used to sign certificates.
+func newCertificateAuthorityCertificate(pub *ecdsa.PublicKey, subject string, validFrom time.Time, validTo time.Time) *GXx509Certificate {
+	return &GXx509Certificate{
+		version:              enums.CertificateVersionVersion3,
+		PublicKey:            pub,
+		Subject:              subject,
+		KeyUsage:             enums.KeyUsageKeyCertSign | enums.KeyUsageCrlSign,
+		BasicConstraints:     true,
+		SubjectKeyIdentifier: subjectKeyIdentifier(pub),
+		ValidFrom:            validFrom.UTC().Truncate(time.Second),
+		ValidTo:              validTo.UTC().Truncate(time.Second),
+	}
+}
+
+// signCertificate signs the certificate with the private key of the issuer.
+//
+// Parameters:
+//
+//	cert: Certificate to sign.
+//	key: Private key of the issuer.
+//
+// Returns:
+//
+//	Signed certificate parsed from the encoded bytes.
+func signCertificate(cert *GXx509Certificate, key *ecdsa.PrivateKey) (*GXx509Certificate, error) {
+	pub, err := PublicKeyFromECDSAPrivate(key)
+	if err != nil {
+		return nil, err
+	}
+	cert.SignatureAlgorithm = signatureAlgorithm(pub)
+	// Serial number is a positive random number with 127 bits.
+	cert.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+	list, err := cert.GetDataList()
+	if err != nil {
+		return nil, err
+	}
+	data, err := Asn1ToByteArray(list)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewGXEcdsaFromPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// Signature is ASN.1 sequence of two integers.
+	cert.Signature, err = e.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := cert.Encoded()
+	if err != nil {
+		return nil, err
+	}
+	return NewGXx509Certificate(raw)
+}
+
+// signatureAlgorithm returns the signature algorithm for the key of the issuer.
+func signatureAlgorithm(key *ecdsa.PublicKey) enums.HashAlgorithm {
+	if key.Curve.Params().BitSize == 384 {
+		return enums.HashAlgorithmSha384WithEcdsa
+	}
+	return enums.HashAlgorithmSha256WithEcdsa
+}
+
+// subjectKeyIdentifier returns the subject key identifier as SHA-1 hash of the public key (RFC 5280).
+func subjectKeyIdentifier(key *ecdsa.PublicKey) []byte {
+	sum := sha1.Sum(PublicKeyToBytes(key))
+	return sum[:]
+}
+
+// certificateSystemTitle returns the system title from the Common Name of the subject.
+func certificateSystemTitle(subject string) ([]byte, error) {
+	for _, it := range strings.Split(subject, ",") {
+		it = strings.TrimSpace(it)
+		if strings.HasPrefix(it, "CN=") {
+			value := strings.TrimSpace(it[3:])
+			if len(value) != 16 {
+				return nil, fmt.Errorf("system title is not included in Common Name: %s", value)
+			}
+			systemTitle := HexToBytes(value)
+			if len(systemTitle) != 8 {
+				return nil, fmt.Errorf("invalid system title: %s", value)
+			}
+			return systemTitle, nil
+		}
+	}
+	return nil, errors.New("common name doesn't exist")
+}
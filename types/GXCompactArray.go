@@ -0,0 +1,91 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// GXCompactDataType is the type description of a compact array element.
+type GXCompactDataType struct {
+	// Type is the element data type.
+	Type enums.DataType
+	// Count is the number of elements when Type is an array.
+	Count int
+	// Elements are the member types of a structure.
+	// Array has exactly one element type.
+	Elements []GXCompactDataType
+}
+
+// String returns the type description in a readable form.
+func (g GXCompactDataType) String() string {
+	switch g.Type {
+	case enums.DataTypeStructure:
+		var sb strings.Builder
+		sb.WriteString("{")
+		for pos, it := range g.Elements {
+			if pos != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(it.String())
+		}
+		sb.WriteString("}")
+		return sb.String()
+	case enums.DataTypeArray:
+		if len(g.Elements) == 1 {
+			return fmt.Sprintf("%s[%d]", g.Elements[0].String(), g.Count)
+		}
+	}
+	return g.Type.String()
+}
+
+// GXCompactArray is a value that is encoded as DLMS compact array.
+//
+// Delta typed elements are given as absolute values.
+// Difference to the previous element is calculated when the value is encoded.
+type GXCompactArray struct {
+	// Description is the type description of the array elements.
+	Description GXCompactDataType
+	// Values are the array elements.
+	Values GXArray
+}
+
+// String implements fmt.Stringer.
+func (g GXCompactArray) String() string {
+	return g.Values.String()
+}
@@ -35,6 +35,7 @@ package types
 //---------------------------------------------------------------------------
 
 import (
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -85,11 +86,20 @@ func schemeSize(scheme enums.Ecc) int {
 }
 
 func PublicKeyFromECDSAPrivate(priv *ecdsa.PrivateKey) (*ecdsa.PublicKey, error) {
-	ecdhPriv, err := priv.ECDH()
+	var curve ecdh.Curve
+	switch priv.Curve.Params().BitSize {
+	case 256:
+		curve = ecdh.P256()
+	case 384:
+		curve = ecdh.P384()
+	default:
+		return nil, errors.New("unsupported ECC scheme")
+	}
+	// Public key is calculated from the private key scalar because it's not set for raw private keys.
+	ecdhPriv, err := curve.NewPrivateKey(PrivateKeyToBytes(priv))
 	if err != nil {
 		return nil, err
 	}
-
 	raw := ecdhPriv.PublicKey().Bytes()
 	return ecdsa.ParseUncompressedPublicKey(priv.Curve, raw)
 }
@@ -312,14 +322,13 @@ func PrivateKeyToBytes(priv *ecdsa.PrivateKey) []byte {
 	return data
 }
 
+// PublicKeyToBytes returns the public key as an uncompressed point (0x04 || X || Y).
 func PublicKeyToBytes(pub *ecdsa.PublicKey) []byte {
-	data := pub.X.Bytes()
 	size := (pub.Curve.Params().BitSize + 7) / 8
-	if len(data) < size {
-		newData := make([]byte, size)
-		copy(newData[size-len(data):], data)
-		data = newData
-	}
+	data := make([]byte, 1+2*size)
+	data[0] = 4
+	pub.X.FillBytes(data[1 : 1+size])
+	pub.Y.FillBytes(data[1+size:])
 	return data
 }
 
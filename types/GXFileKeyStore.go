@@ -0,0 +1,417 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/dlmserrors"
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// historyTimeFormat is the time format of the history file extension.
+const historyTimeFormat = "20060102T150405.000000000Z"
+
+// GXFileKeyStore is a directory-backed key store.
+//
+// Files are saved using the same layout as the other Gurux tools:
+//
+//	Keys/D<system title>.pem            P-256 private keys.
+//	Keys384/D<system title>.pem         P-384 private keys.
+//	Certificates/D<system title>.pem    P-256 certificates.
+//	Certificates384/D<system title>.pem P-384 certificates.
+//	SymmetricKeys/<key type><system title>.key
+//
+// Prefix is D for digital signature, A for key agreement and T for TLS.
+// Replaced files are moved to the History folder of the same directory and
+// the time of the replacement is added to the file name. When a key or a
+// certificate of the other curve is replaced, the old file is moved to the
+// history of its own directory.
+type GXFileKeyStore struct {
+	// Root directory of the key store.
+	path string
+}
+
+// NewGXFileKeyStore creates a key store for the given directory.
+// Directory is created if it doesn't exist.
+func NewGXFileKeyStore(path string) (*GXFileKeyStore, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+	return &GXFileKeyStore{path: path}, nil
+}
+
+// Path returns the root directory of the key store.
+func (g *GXFileKeyStore) Path() string {
+	return g.path
+}
+
+// PrivateKey returns the private key of the system title.
+func (g *GXFileKeyStore) PrivateKey(systemTitle []byte, certificateType enums.CertificateType) (*GXPkcs8, error) {
+	for _, scheme := range []enums.Ecc{enums.EccP256, enums.EccP384} {
+		name, err := privateKeyFileName(scheme, systemTitle, certificateType)
+		if err != nil {
+			return nil, err
+		}
+		key, err := Pkcs8Load(filepath.Join(g.path, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return key, err
+	}
+	return nil, dlmserrors.ErrKeyNotFound
+}
+
+// SetPrivateKey saves the private key of the system title.
+func (g *GXFileKeyStore) SetPrivateKey(systemTitle []byte, certificateType enums.CertificateType, key *GXPkcs8) error {
+	if key == nil || key.PrivateKey() == nil {
+		return errors.New("private key is not set")
+	}
+	scheme, err := PrivateKeyScheme(key.PrivateKey())
+	if err != nil {
+		return err
+	}
+	name, err := privateKeyFileName(scheme, systemTitle, certificateType)
+	if err != nil {
+		return err
+	}
+	other, err := privateKeyFileName(otherScheme(scheme), systemTitle, certificateType)
+	if err != nil {
+		return err
+	}
+	data, err := key.ToPem()
+	if err != nil {
+		return err
+	}
+	if err = g.write(name, []byte(data)); err != nil {
+		return err
+	}
+	return g.retire(other)
+}
+
+// PrivateKeyHistory returns the previous private keys of the system title. The newest key is first.
+func (g *GXFileKeyStore) PrivateKeyHistory(systemTitle []byte, certificateType enums.CertificateType) ([]*GXPkcs8, error) {
+	var files []string
+	for _, scheme := range []enums.Ecc{enums.EccP256, enums.EccP384} {
+		name, err := privateKeyFileName(scheme, systemTitle, certificateType)
+		if err != nil {
+			return nil, err
+		}
+		tmp, err := g.history(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tmp...)
+	}
+	sortHistory(files)
+	var ret []*GXPkcs8
+	for _, it := range files {
+		key, err := Pkcs8Load(it)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, key)
+	}
+	return ret, nil
+}
+
+// Certificate returns the certificate of the system title.
+func (g *GXFileKeyStore) Certificate(systemTitle []byte, certificateType enums.CertificateType) (*GXx509Certificate, error) {
+	for _, scheme := range []enums.Ecc{enums.EccP256, enums.EccP384} {
+		name, err := certificateFileName(scheme, systemTitle, certificateType)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := X509CertificateLoad(filepath.Join(g.path, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return cert, err
+	}
+	return nil, dlmserrors.ErrKeyNotFound
+}
+
+// SetCertificate saves the certificate of the system title.
+func (g *GXFileKeyStore) SetCertificate(systemTitle []byte, certificateType enums.CertificateType, certificate *GXx509Certificate) error {
+	if certificate == nil || certificate.PublicKey == nil {
+		return errors.New("certificate is not set")
+	}
+	scheme, err := PublicKeyScheme(certificate.PublicKey)
+	if err != nil {
+		return err
+	}
+	name, err := certificateFileName(scheme, systemTitle, certificateType)
+	if err != nil {
+		return err
+	}
+	other, err := certificateFileName(otherScheme(scheme), systemTitle, certificateType)
+	if err != nil {
+		return err
+	}
+	data, err := certificate.ToPem()
+	if err != nil {
+		return err
+	}
+	if err = g.write(name, []byte(data)); err != nil {
+		return err
+	}
+	return g.retire(other)
+}
+
+// CertificateHistory returns the previous certificates of the system title. The newest certificate is first.
+func (g *GXFileKeyStore) CertificateHistory(systemTitle []byte, certificateType enums.CertificateType) ([]*GXx509Certificate, error) {
+	var files []string
+	for _, scheme := range []enums.Ecc{enums.EccP256, enums.EccP384} {
+		name, err := certificateFileName(scheme, systemTitle, certificateType)
+		if err != nil {
+			return nil, err
+		}
+		tmp, err := g.history(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tmp...)
+	}
+	sortHistory(files)
+	var ret []*GXx509Certificate
+	for _, it := range files {
+		cert, err := X509CertificateLoad(it)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cert)
+	}
+	return ret, nil
+}
+
+// Key returns the symmetric key of the system title.
+func (g *GXFileKeyStore) Key(systemTitle []byte, keyType enums.GlobalKeyType) ([]byte, error) {
+	name, err := symmetricKeyFileName(systemTitle, keyType)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := readSymmetricKey(filepath.Join(g.path, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, dlmserrors.ErrKeyNotFound
+	}
+	return ret, err
+}
+
+// SetKey saves the symmetric key of the system title.
+func (g *GXFileKeyStore) SetKey(systemTitle []byte, keyType enums.GlobalKeyType, key []byte) error {
+	if len(key) != 16 && len(key) != 32 {
+		return errors.New("invalid symmetric key length")
+	}
+	name, err := symmetricKeyFileName(systemTitle, keyType)
+	if err != nil {
+		return err
+	}
+	return g.write(name, []byte(ToHex(key, false)))
+}
+
+// KeyHistory returns the previous symmetric keys of the system title. The newest key is first.
+func (g *GXFileKeyStore) KeyHistory(systemTitle []byte, keyType enums.GlobalKeyType) ([][]byte, error) {
+	name, err := symmetricKeyFileName(systemTitle, keyType)
+	if err != nil {
+		return nil, err
+	}
+	files, err := g.history(name)
+	if err != nil {
+		return nil, err
+	}
+	var ret [][]byte
+	for _, it := range files {
+		key, err := readSymmetricKey(it)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, key)
+	}
+	return ret, nil
+}
+
+// write saves the data atomically.
+// Existing file is moved to the history before the new file is taken into use.
+//
+// Parameters:
+//
+//	name: File name relative to the root directory.
+//	data: File content.
+func (g *GXFileKeyStore) write(name string, data []byte) error {
+	path := filepath.Join(g.path, name)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Data is written to the temporary file first so readers never see a partial file.
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if err2 := tmp.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		target, err := historyFileName(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Link(path, target); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// retire moves the file to the history if it exists.
+//
+// Parameters:
+//
+//	name: File name relative to the root directory.
+func (g *GXFileKeyStore) retire(name string) error {
+	path := filepath.Join(g.path, name)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	target, err := historyFileName(path)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path, target)
+}
+
+// historyFileName returns the history file name of the file.
+// History directory is created if it doesn't exist.
+func historyFileName(path string) (string, error) {
+	history := filepath.Join(filepath.Dir(path), "History")
+	if err := os.MkdirAll(history, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(history, filepath.Base(path)+"."+time.Now().UTC().Format(historyTimeFormat)), nil
+}
+
+// history returns the history files of the file. The newest file is first.
+func (g *GXFileKeyStore) history(name string) ([]string, error) {
+	path := filepath.Join(g.path, name)
+	dir := filepath.Join(filepath.Dir(path), "History")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	prefix := filepath.Base(path) + "."
+	var ret []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			ret = append(ret, filepath.Join(dir, e.Name()))
+		}
+	}
+	sortHistory(ret)
+	return ret, nil
+}
+
+// sortHistory sorts the history files so that the newest file is first.
+// Files of both curves have the same base name and they are sorted by the time stamp.
+func sortHistory(files []string) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return filepath.Base(files[i]) > filepath.Base(files[j])
+	})
+}
+
+// privateKeyFileName returns the file name of the private key.
+func privateKeyFileName(scheme enums.Ecc, systemTitle []byte, certificateType enums.CertificateType) (string, error) {
+	if len(systemTitle) != 8 {
+		return "", errors.New("Invalid system title.")
+	}
+	return (&GXPkcs8{}).GetFilePathFromSystemTitle(scheme, certificateType, systemTitle)
+}
+
+// certificateFileName returns the file name of the certificate.
+// Certificates use the same file name as the private key in the certificate directory.
+func certificateFileName(scheme enums.Ecc, systemTitle []byte, certificateType enums.CertificateType) (string, error) {
+	name, err := privateKeyFileName(scheme, systemTitle, certificateType)
+	if err != nil {
+		return "", err
+	}
+	dir := "Certificates"
+	if scheme == enums.EccP384 {
+		dir = "Certificates384"
+	}
+	return filepath.Join(dir, filepath.Base(name)), nil
+}
+
+// otherScheme returns the curve that is not the given one.
+func otherScheme(scheme enums.Ecc) enums.Ecc {
+	if scheme == enums.EccP384 {
+		return enums.EccP256
+	}
+	return enums.EccP384
+}
+
+// symmetricKeyFileName returns the file name of the symmetric key.
+func symmetricKeyFileName(systemTitle []byte, keyType enums.GlobalKeyType) (string, error) {
+	if len(systemTitle) != 8 {
+		return "", errors.New("Invalid system title.")
+	}
+	name := keyType.String()
+	if name == "" {
+		return "", fmt.Errorf("invalid key type: %d", keyType)
+	}
+	return filepath.Join("SymmetricKeys", name+ToHex(systemTitle, false)+".key"), nil
+}
+
+// readSymmetricKey reads the hex encoded symmetric key from the file.
+func readSymmetricKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return HexToBytes(strings.TrimSpace(string(data))), nil
+}
@@ -0,0 +1,72 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// GXIKeyStore stores private keys, certificates and symmetric keys by system title.
+//
+// Get methods return dlmserrors.ErrKeyNotFound if the key doesn't exist.
+// When a key is replaced, the previous key is kept in the history.
+type GXIKeyStore interface {
+	// PrivateKey returns the private key of the system title.
+	PrivateKey(systemTitle []byte, certificateType enums.CertificateType) (*GXPkcs8, error)
+
+	// SetPrivateKey saves the private key of the system title.
+	SetPrivateKey(systemTitle []byte, certificateType enums.CertificateType, key *GXPkcs8) error
+
+	// PrivateKeyHistory returns the previous private keys of the system title. The newest key is first.
+	PrivateKeyHistory(systemTitle []byte, certificateType enums.CertificateType) ([]*GXPkcs8, error)
+
+	// Certificate returns the certificate of the system title.
+	Certificate(systemTitle []byte, certificateType enums.CertificateType) (*GXx509Certificate, error)
+
+	// SetCertificate saves the certificate of the system title.
+	SetCertificate(systemTitle []byte, certificateType enums.CertificateType, certificate *GXx509Certificate) error
+
+	// CertificateHistory returns the previous certificates of the system title. The newest certificate is first.
+	CertificateHistory(systemTitle []byte, certificateType enums.CertificateType) ([]*GXx509Certificate, error)
+
+	// Key returns the symmetric key of the system title.
+	Key(systemTitle []byte, keyType enums.GlobalKeyType) ([]byte, error)
+
+	// SetKey saves the symmetric key of the system title.
+	SetKey(systemTitle []byte, keyType enums.GlobalKeyType, key []byte) error
+
+	// KeyHistory returns the previous symmetric keys of the system title. The newest key is first.
+	KeyHistory(systemTitle []byte, keyType enums.GlobalKeyType) ([][]byte, error)
+}
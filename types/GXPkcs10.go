@@ -241,28 +241,27 @@ func (g *GXPkcs10) init(data []byte) error {
 		return err
 	}
 	tmp3 := *ret.(*GXAsn1Sequence)
-	bb := GXByteBuffer{}
 	var size int
-	var add int
 	if g.signatureAlgorithm == enums.HashAlgorithmSha256WithEcdsa {
 		size = 32
 	} else {
 		size = 48
 	}
-	v := tmp3[0].(*GXAsn1Integer).Value()
-	if len(v) == size {
-		add = 0
-	} else {
-		add = 1
-	}
-	bb.SetAt(v, add, size)
-	v = tmp3[1].(*GXAsn1Integer).Value()
-	if len(v) == size {
-		add = 0
-	} else {
-		add = 1
+	// Signature is converted from ASN.1 integers to R || S.
+	bb := GXByteBuffer{}
+	for _, it := range tmp3 {
+		i, ok := it.(*GXAsn1Integer)
+		if !ok {
+			return errors.New("Invalid Signature.")
+		}
+		n := i.ToBigInteger()
+		if n.Sign() < 0 || (n.BitLen()+7)/8 > size {
+			return errors.New("Invalid Signature.")
+		}
+		v := make([]byte, size)
+		n.FillBytes(v)
+		bb.Set(v)
 	}
-	bb.SetAt(v, add, size)
 	tmp4, err := tmp2.SubArray(tmp2.Position(), tmp2.Available())
 	if err != nil {
 		return err
@@ -291,7 +290,7 @@ func (g *GXPkcs10) getData() []any {
 		return nil
 	}
 	tmp := []any{NewGXAsn1ObjectIdentifier("1.2.840.10045.2.1"), alg}
-	attributes := GXAsn1Context{}
+	attributes := NewGXAsn1Context()
 	for _, v := range g.attributes {
 		s := GXAsn1Sequence{}
 		ret, err := PkcsObjectIdentifierToString(v.Key)
@@ -306,11 +305,15 @@ func (g *GXPkcs10) getData() []any {
 		s = append(s, NewGXKeyValuePair[any, any](values, nil))
 		attributes.Items = append(attributes.Items, s)
 	}
-	ret, err := Asn1EncodeSubject(g.subject)
+	pairs, err := Asn1EncodeSubject(g.subject)
 	if err != nil {
 		return nil
 	}
-	return []any{int8(g.version), ret, []any{tmp, subjectPKInfo}, attributes}
+	subject := GXAsn1Sequence{}
+	for _, pair := range pairs {
+		subject = append(subject, NewGXKeyValuePair[any, any](pair.Key, pair.Value))
+	}
+	return []any{int8(g.version), subject, []any{tmp, subjectPKInfo}, attributes}
 }
 
 // Sign signs the CSR using the provided ECDSA private key and hash algorithm.
@@ -329,31 +332,8 @@ func (g *GXPkcs10) Sign(key *ecdsa.PrivateKey, hashAlgorithm enums.HashAlgorithm
 		return err
 	}
 	g.signatureAlgorithm = hashAlgorithm
-	bb := GXByteBuffer{}
-	ret, err := e.Sign(data)
-	if err != nil {
-		return err
-	}
-	err = bb.Set(ret)
-	if err != nil {
-		return err
-	}
-	var size int
-	if g.signatureAlgorithm == enums.HashAlgorithmSha256WithEcdsa {
-		size = 32
-	} else {
-		size = 48
-	}
-	v1, err := bb.SubArray(0, size)
-	if err != nil {
-		return err
-	}
-	v2, err := bb.SubArray(size, size)
-	if err != nil {
-		return err
-	}
-	tmp := []any{NewGXAsn1Integer(v1), NewGXAsn1Integer(v2)}
-	g.signature, err = Asn1ToByteArray(tmp)
+	// Signature is ASN.1 sequence of two integers.
+	g.signature, err = e.Sign(data)
 	if err != nil {
 		return err
 	}
@@ -83,7 +83,7 @@ func NewGXPkcs8(data []byte) (*GXPkcs8, error) {
 
 // NewGXPkcs8FromKeys creates a GXPkcs8 object from an existing public/private key pair.
 func NewGXPkcs8FromKeys(keyValuePair *GXKeyValuePair[*ecdsa.PublicKey, *ecdsa.PrivateKey]) (*GXPkcs8, error) {
-	ret := &GXPkcs8{}
+	ret := &GXPkcs8{algorithm: enums.X9ObjectIdentifierIdECPublicKey}
 	ret.publicKey = keyValuePair.Key
 	ret.privateKey = keyValuePair.Value
 	return ret, nil
@@ -112,22 +112,22 @@ func (g *GXPkcs8) encoded() ([]byte, error) {
 		return nil, err
 	}
 	d1 = append(d1, NewGXAsn1ObjectIdentifier(s))
-	var alg GXAsn1ObjectIdentifier
+	var alg *GXAsn1ObjectIdentifier
 	sc, err := PublicKeyScheme(g.publicKey)
 	if err != nil {
 		return nil, err
 	}
 	if sc == enums.EccP256 {
-		alg = *NewGXAsn1ObjectIdentifier("1.2.840.10045.3.1.7")
+		alg = NewGXAsn1ObjectIdentifier("1.2.840.10045.3.1.7")
 	} else {
-		alg = *NewGXAsn1ObjectIdentifier("1.3.132.0.34")
+		alg = NewGXAsn1ObjectIdentifier("1.3.132.0.34")
 	}
 	d1 = append(d1, alg)
 	d = append(d, d1)
 	d2 := GXAsn1Sequence{}
 	d2 = append(d2, int8(1))
 	d2 = append(d2, PrivateKeyToBytes(g.privateKey))
-	d3 := GXAsn1Context{}
+	d3 := NewGXAsn1Context()
 	d3.Index = 1
 	bs, err := NewGXBitString(PublicKeyToBytes(g.publicKey), 0)
 	if err != nil {
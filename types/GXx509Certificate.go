@@ -162,7 +162,7 @@ func (g *GXx509Certificate) Encoded() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	tmp := []any{*NewGXAsn1ObjectIdentifier(ret)}
+	tmp := []any{NewGXAsn1ObjectIdentifier(ret)}
 	l, err := g.GetDataList()
 	if err != nil {
 		return nil, err
@@ -330,6 +330,10 @@ func (g *GXx509Certificate) init(data []byte) error {
 					switch X509CertificateTypeFromString(id.String()) {
 					case enums.X509CertificateTypeSubjectKeyIdentifier:
 						if b, ok := value.([]byte); ok {
+							// Extension value is an octet string that contains the key identifier octet string.
+							if len(b) > 2 && b[0] == byte(constants.BerTypeOctetString) && int(b[1]) == len(b)-2 {
+								b = b[2:]
+							}
 							g.SubjectKeyIdentifier = b
 						}
 					case enums.X509CertificateTypeSubjectAlternativeName:
@@ -685,6 +689,7 @@ func (g *GXx509Certificate) GetDataList() ([]any, error) {
 	if g.BasicConstraints {
 		// BasicConstraints is critical if it exists.
 		s1 = append(s1, g.BasicConstraints)
+		seq = append(seq, g.BasicConstraints)
 	} else if g.KeyUsage == enums.KeyUsageNone {
 		return nil, errors.New("Key usage not present.")
 	}
@@ -1004,6 +1009,7 @@ func (g *GXx509Certificate) ToPem() (string, error) {
 	}
 	sb.WriteString("-----BEGIN CERTIFICATE-----\n")
 	sb.WriteString(g.ToDer())
+	sb.WriteString("\n")
 	sb.WriteString("-----END CERTIFICATE-----\n")
 	return sb.String(), nil
 }
@@ -1030,13 +1036,25 @@ func (g *GXx509Certificate) IsCertified(certifier *ecdsa.PublicKey) (bool, error
 	if certifier == nil {
 		return false, errors.New("certifier")
 	}
+	encoded, err := g.Encoded()
+	if err != nil {
+		return false, err
+	}
+	return verifySignedData(encoded, g.Signature, g.SignatureAlgorithm, certifier)
+}
+
+// verifySignedData verifies the signature of the ASN.1 signed data (certificate or CRL).
+//
+// Parameters:
+//
+//	data: ASN.1 encoded signed data.
+//	signature: ASN.1 encoded signature.
+//	algorithm: Signature algorithm.
+//	certifier: Public key of the signer.
+func verifySignedData(data []byte, signature []byte, algorithm enums.HashAlgorithm, certifier *ecdsa.PublicKey) (bool, error) {
 	// Get raw data
 	tmp2 := GXByteBuffer{}
-	encoded, error := g.Encoded()
-	if error != nil {
-		return false, error
-	}
-	err := tmp2.Set(encoded)
+	err := tmp2.Set(data)
 	if err != nil {
 		return false, err
 	}
@@ -1051,31 +1069,35 @@ func (g *GXx509Certificate) IsCertified(certifier *ecdsa.PublicKey) (bool, error
 	if err != nil {
 		return false, err
 	}
-	v, err := Asn1FromByteArray(g.Signature)
+	v, err := Asn1FromByteArray(signature)
 	if err != nil {
 		return false, err
 	}
-	tmp3 := v.(GXAsn1Sequence)
-	bb := GXByteBuffer{}
+	tmp3, ok := v.(*GXAsn1Sequence)
+	if !ok || len(*tmp3) != 2 {
+		return false, errors.New("Invalid signature.")
+	}
 	size := 0
-	if g.SignatureAlgorithm == enums.HashAlgorithmSha256WithEcdsa {
+	if algorithm == enums.HashAlgorithmSha256WithEcdsa {
 		size = 32
 	} else {
 		size = 48
 	}
-	v2 := tmp3[0].(*GXAsn1Integer).Value()
-	a := 0
-	if len(v2) != size {
-		a = 1
-	}
-	bb.SetAt(v2, a, size)
-
-	v2 = tmp3[1].(*GXAsn1Integer).Value()
-	a = 0
-	if len(v2) != size {
-		a = 1
+	// Signature is converted from ASN.1 integers to R || S.
+	bb := GXByteBuffer{}
+	for _, it := range *tmp3 {
+		i, ok := it.(*GXAsn1Integer)
+		if !ok {
+			return false, errors.New("Invalid signature.")
+		}
+		n := i.ToBigInteger()
+		if n.Sign() < 0 || (n.BitLen()+7)/8 > size {
+			return false, errors.New("Invalid signature.")
+		}
+		value := make([]byte, size)
+		n.FillBytes(value)
+		bb.Set(value)
 	}
-	bb.SetAt(v2, a, size)
 	tmp4, err := tmp2.SubArray(tmp2.Position(), tmp2.Available())
 	if err != nil {
 		return false, err
@@ -0,0 +1,294 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// maxCertificatePathLength is the maximum number of certificates in the certificate path.
+const maxCertificatePathLength = 10
+
+// GXx509ValidationResult is the result of the certificate validation.
+type GXx509ValidationResult struct {
+	// Status tells which check failed.
+	Status enums.CertificateValidationStatus
+
+	// Certificate that failed the check. This is nil if the certificate is valid.
+	Certificate *GXx509Certificate
+
+	// Chain contains the certificates from the validated certificate to the trusted certificate.
+	Chain []*GXx509Certificate
+}
+
+// Valid returns true if the certificate is valid.
+func (g *GXx509ValidationResult) Valid() bool {
+	return g.Status == enums.CertificateValidationStatusValid
+}
+
+// String returns a human-readable validation result.
+func (g *GXx509ValidationResult) String() string {
+	if g.Certificate == nil {
+		return g.Status.String()
+	}
+	return fmt.Sprintf("%s: %s", g.Status.String(), g.Certificate.Subject)
+}
+
+// GXx509CertificateValidator validates DLMS certificates against the trusted certificates.
+//
+// Validator builds the certificate path from the certificate to the trusted certificate and
+// checks the signatures, validity periods, key usages and revocation of each certificate.
+type GXx509CertificateValidator struct {
+	// Trusted certificates (root certificate authorities).
+	TrustedCertificates GXx509CertificateCollection
+
+	// Intermediate certificate authorities that are used to build the certificate path.
+	IntermediateCertificates GXx509CertificateCollection
+
+	// Certificate revocation lists.
+	Crls []*GXx509Crl
+
+	// Clock returns the time that is used to check the validity periods.
+	// Current time is used if clock is not set.
+	Clock func() time.Time
+}
+
+// NewGXx509CertificateValidator creates a validator for the trusted certificates.
+//
+// Parameters:
+//
+//	trusted: Trusted certificates.
+func NewGXx509CertificateValidator(trusted GXx509CertificateCollection) *GXx509CertificateValidator {
+	return &GXx509CertificateValidator{TrustedCertificates: trusted}
+}
+
+// LoadCrls reads certificate revocation lists from the specified directory.
+//
+// Supported file extensions are .crl and .pem. PEM files that don't contain a revocation list are skipped.
+// An error is returned if a file fails to load, because a missing revocation list would accept the revoked certificates.
+func (g *GXx509CertificateValidator) LoadCrls(path string) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		full := filepath.Join(path, e.Name())
+		var crl *GXx509Crl
+		switch strings.ToLower(filepath.Ext(full)) {
+		case ".crl":
+			crl, err = X509CrlLoad(full)
+		case ".pem":
+			var data []byte
+			if data, err = os.ReadFile(full); err == nil {
+				// Certificates and keys are also saved as PEM.
+				if !strings.Contains(string(data), "-----BEGIN X509 CRL-----") {
+					continue
+				}
+				crl, err = X509CrlFromPem(string(data))
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load certificate revocation list %s: %w", full, err)
+		}
+		g.Crls = append(g.Crls, crl)
+	}
+	return nil
+}
+
+// now returns the time that is used to check the validity periods.
+func (g *GXx509CertificateValidator) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// Validate validates the certificate.
+//
+// Parameters:
+//
+//	certificate: Certificate to validate.
+//	certificateType: Intended certificate type.
+//	systemTitle: System title of the meter. System title is not checked if it's nil.
+//
+// Returns:
+//
+//	Validation result.
+func (g *GXx509CertificateValidator) Validate(certificate *GXx509Certificate, certificateType enums.CertificateType, systemTitle []byte) *GXx509ValidationResult {
+	ret := &GXx509ValidationResult{Certificate: certificate}
+	if !isKeyUsageValid(certificate, certificateType) {
+		ret.Status = enums.CertificateValidationStatusInvalidKeyUsage
+		return ret
+	}
+	if systemTitle != nil {
+		st, err := certificateSystemTitle(certificate.Subject)
+		if err != nil || !bytes.Equal(st, systemTitle) {
+			ret.Status = enums.CertificateValidationStatusSystemTitleMismatch
+			return ret
+		}
+	}
+	now := g.now()
+	current := certificate
+	for len(ret.Chain) < maxCertificatePathLength {
+		ret.Chain = append(ret.Chain, current)
+		ret.Certificate = current
+		if now.Before(current.ValidFrom) {
+			ret.Status = enums.CertificateValidationStatusNotYetValid
+			return ret
+		}
+		if now.After(current.ValidTo) {
+			ret.Status = enums.CertificateValidationStatusExpired
+			return ret
+		}
+		if g.isTrusted(current) {
+			ret.Status = enums.CertificateValidationStatusValid
+			ret.Certificate = nil
+			return ret
+		}
+		issuer, status := g.findIssuer(current)
+		if status != enums.CertificateValidationStatusValid {
+			ret.Status = status
+			return ret
+		}
+		if !issuer.BasicConstraints || (issuer.KeyUsage&enums.KeyUsageKeyCertSign) == 0 {
+			ret.Status = enums.CertificateValidationStatusInvalidIssuer
+			ret.Certificate = issuer
+			return ret
+		}
+		if status := g.revocationStatus(current, issuer, now); status != enums.CertificateValidationStatusValid {
+			ret.Status = status
+			return ret
+		}
+		current = issuer
+	}
+	ret.Status = enums.CertificateValidationStatusUntrusted
+	return ret
+}
+
+// isKeyUsageValid returns true if the key usage of the certificate matches to the certificate type.
+func isKeyUsageValid(certificate *GXx509Certificate, certificateType enums.CertificateType) bool {
+	switch certificateType {
+	case enums.CertificateTypeDigitalSignature:
+		return certificate.KeyUsage == enums.KeyUsageDigitalSignature
+	case enums.CertificateTypeKeyAgreement:
+		return certificate.KeyUsage == enums.KeyUsageKeyAgreement
+	case enums.CertificateTypeTLS:
+		return certificate.KeyUsage == enums.KeyUsageDigitalSignature|enums.KeyUsageKeyAgreement &&
+			certificate.ExtendedKeyUsage != enums.ExtendedKeyUsageNone
+	case enums.CertificateTypeOther:
+		return true
+	}
+	return false
+}
+
+// isTrusted returns true if the certificate is one of the trusted certificates.
+func (g *GXx509CertificateValidator) isTrusted(certificate *GXx509Certificate) bool {
+	for _, it := range g.TrustedCertificates {
+		if it == certificate {
+			return true
+		}
+		a, err := it.Encoded()
+		if err != nil {
+			continue
+		}
+		b, err := certificate.Encoded()
+		if err != nil {
+			return false
+		}
+		if bytes.Equal(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// findIssuer returns the certificate that has signed the certificate.
+//
+// Returns:
+//
+//	Issuer certificate and the validation status when the issuer is not found.
+func (g *GXx509CertificateValidator) findIssuer(certificate *GXx509Certificate) (*GXx509Certificate, enums.CertificateValidationStatus) {
+	status := enums.CertificateValidationStatusUntrusted
+	for _, list := range []GXx509CertificateCollection{g.TrustedCertificates, g.IntermediateCertificates} {
+		for _, it := range list {
+			if it == certificate || it.Subject != certificate.Issuer {
+				continue
+			}
+			if len(certificate.AuthorityKeyIdentifier) != 0 && len(it.SubjectKeyIdentifier) != 0 &&
+				!bytes.Equal(certificate.AuthorityKeyIdentifier, it.SubjectKeyIdentifier) {
+				continue
+			}
+			ok, err := certificate.IsCertified(it.PublicKey)
+			if err == nil && ok {
+				return it, enums.CertificateValidationStatusValid
+			}
+			status = enums.CertificateValidationStatusInvalidSignature
+		}
+	}
+	return nil, status
+}
+
+// revocationStatus checks the certificate against the certificate revocation lists of the issuer.
+// Revocation list is out of date if the next update time has passed.
+func (g *GXx509CertificateValidator) revocationStatus(certificate *GXx509Certificate, issuer *GXx509Certificate, now time.Time) enums.CertificateValidationStatus {
+	for _, it := range g.Crls {
+		if it.Issuer != issuer.Subject {
+			continue
+		}
+		// Revocation list is accepted only if the issuer has signed it.
+		if ok, err := it.IsCertified(issuer.PublicKey); err != nil || !ok {
+			continue
+		}
+		if !it.NextUpdate.IsZero() && now.After(it.NextUpdate) {
+			return enums.CertificateValidationStatusStaleCrl
+		}
+		if it.IsRevoked(certificate) {
+			return enums.CertificateValidationStatusRevoked
+		}
+	}
+	return enums.CertificateValidationStatusValid
+}
@@ -0,0 +1,259 @@
+package types
+
+//
+// --------------------------------------------------------------------------
+//  Gurux Ltd
+//
+//
+//
+// Filename:        $HeadURL$
+//
+// Version:         $Revision$,
+//                  $Date$
+//                  $Author$
+//
+// Copyright (c) Gurux Ltd
+//
+//---------------------------------------------------------------------------
+//
+//  DESCRIPTION
+//
+// This file is a part of Gurux Device Framework.
+//
+// Gurux Device Framework is Open Source software; you can redistribute it
+// and/or modify it under the terms of the GNU General Public License
+// as published by the Free Software Foundation; version 2 of the License.
+// Gurux Device Framework is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.
+// See the GNU General Public License for more details.
+//
+// More information of Gurux products: https://www.gurux.org
+//
+// This code is licensed under the GNU General Public License v2.
+// Full text may be retrieved at http://www.gnu.org/licenses/gpl-2.0.txt
+//---------------------------------------------------------------------------
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Gurux/gxdlms-go/enums"
+)
+
+// GXRevokedCertificate describes a revoked certificate in the certificate revocation list.
+type GXRevokedCertificate struct {
+	// Serial number of the revoked certificate.
+	SerialNumber *big.Int
+
+	// Time when the certificate was revoked.
+	RevocationDate time.Time
+}
+
+// GXx509Crl represents a X.509 certificate revocation list (RFC 5280).
+type GXx509Crl struct {
+	// Loaded certificate revocation list as raw data.
+	rawData []byte
+
+	// Issuer of the certificate revocation list.
+	Issuer string
+
+	// Signature algorithm.
+	SignatureAlgorithm enums.HashAlgorithm
+
+	// Signature.
+	Signature []byte
+
+	// Issue date of the certificate revocation list.
+	ThisUpdate time.Time
+
+	// Date when the next certificate revocation list is issued.
+	NextUpdate time.Time
+
+	// Revoked certificates.
+	RevokedCertificates []GXRevokedCertificate
+}
+
+// NewGXx509Crl parses a DER-encoded certificate revocation list.
+func NewGXx509Crl(data []byte) (*GXx509Crl, error) {
+	ret := &GXx509Crl{}
+	err := ret.init(data)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// RawData returns the original certificate revocation list bytes.
+func (g *GXx509Crl) RawData() []byte {
+	return g.rawData
+}
+
+// init parses DER bytes of the certificate revocation list into this instance.
+func (g *GXx509Crl) init(data []byte) error {
+	g.rawData = data
+	ret, err := Asn1FromByteArray(data)
+	if err != nil {
+		return err
+	}
+	seq, ok := asn1Sequence(ret)
+	if !ok || len(*seq) != 3 {
+		return errors.New("Invalid certificate revocation list.")
+	}
+	tbs, ok := asn1Sequence((*seq)[0])
+	if !ok || len(*tbs) < 3 {
+		return errors.New("Invalid certificate revocation list.")
+	}
+	pos := 0
+	// Version is optional.
+	if _, ok := (*tbs)[0].(int8); ok {
+		pos++
+	}
+	signSeq, ok := asn1Sequence((*tbs)[pos])
+	if !ok || len(*signSeq) == 0 {
+		return errors.New("Invalid signature algorithm.")
+	}
+	signOID, ok := asn1OidString((*signSeq)[0])
+	if !ok {
+		return errors.New("Invalid signature algorithm.")
+	}
+	g.SignatureAlgorithm = HashAlgorithmFromString(signOID)
+	if g.SignatureAlgorithm != enums.HashAlgorithmSha256WithEcdsa &&
+		g.SignatureAlgorithm != enums.HashAlgorithmSha384WithEcdsa {
+		return errors.New("Invalid signature algorithm. " + signOID)
+	}
+	pos++
+	issuer, ok := asn1Sequence((*tbs)[pos])
+	if !ok {
+		return errors.New("Invalid issuer.")
+	}
+	g.Issuer = Asn1GetSubject(issuer)
+	pos++
+	if pos == len(*tbs) {
+		return errors.New("Invalid certificate revocation list.")
+	}
+	if g.ThisUpdate, ok = (*tbs)[pos].(time.Time); !ok {
+		return errors.New("Invalid this update.")
+	}
+	pos++
+	// Next update is optional.
+	if pos < len(*tbs) {
+		if t, ok := (*tbs)[pos].(time.Time); ok {
+			g.NextUpdate = t
+			pos++
+		}
+	}
+	// Revoked certificates are optional.
+	if pos < len(*tbs) {
+		if revoked, ok := asn1Sequence((*tbs)[pos]); ok {
+			for _, it := range *revoked {
+				entry, ok := asn1Sequence(it)
+				if !ok || len(*entry) < 2 {
+					return errors.New("Invalid revoked certificate.")
+				}
+				sn, err := asn1BigInteger((*entry)[0])
+				if err != nil {
+					return err
+				}
+				date, ok := (*entry)[1].(time.Time)
+				if !ok {
+					return errors.New("Invalid revocation date.")
+				}
+				g.RevokedCertificates = append(g.RevokedCertificates, GXRevokedCertificate{SerialNumber: sn, RevocationDate: date})
+			}
+		}
+	}
+	bs, ok := (*seq)[2].(*GXBitString)
+	if !ok {
+		return errors.New("Invalid signature.")
+	}
+	g.Signature = bs.Value()
+	return nil
+}
+
+// asn1BigInteger converts the parsed ASN.1 integer to big integer.
+func asn1BigInteger(value any) (*big.Int, error) {
+	switch v := value.(type) {
+	case int8:
+		return big.NewInt(int64(v)), nil
+	case int16:
+		return big.NewInt(int64(v)), nil
+	case int32:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case *GXAsn1Integer:
+		return v.ToBigInteger(), nil
+	}
+	return nil, errors.New("Invalid serial number.")
+}
+
+// IsCertified verifies that certifier signed this certificate revocation list.
+func (g *GXx509Crl) IsCertified(certifier *ecdsa.PublicKey) (bool, error) {
+	if certifier == nil {
+		return false, errors.New("certifier")
+	}
+	return verifySignedData(g.rawData, g.Signature, g.SignatureAlgorithm, certifier)
+}
+
+// IsRevoked returns true if the certificate is revoked.
+//
+// Parameters:
+//
+//	certificate: Certificate to check.
+func (g *GXx509Crl) IsRevoked(certificate *GXx509Certificate) bool {
+	if certificate == nil || certificate.SerialNumber == nil || certificate.Issuer != g.Issuer {
+		return false
+	}
+	for _, it := range g.RevokedCertificates {
+		if it.SerialNumber.Cmp(certificate.SerialNumber) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// X509CrlFromPem parses a certificate revocation list from PEM text.
+func X509CrlFromPem(data string) (*GXx509Crl, error) {
+	const START = "-----BEGIN X509 CRL-----"
+	const END = "-----END X509 CRL-----"
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	start := strings.Index(data, START)
+	if start == -1 {
+		return nil, errors.New("Invalid PEM file.")
+	}
+	data = data[start+len(START):]
+	end := strings.Index(data, END)
+	if end == -1 {
+		return nil, errors.New("Invalid PEM file.")
+	}
+	return X509CrlFromDer(data[0:end])
+}
+
+// X509CrlFromDer parses a certificate revocation list from a base64-encoded DER string.
+func X509CrlFromDer(der string) (*GXx509Crl, error) {
+	der = strings.ReplaceAll(der, "\r\n", "")
+	der = strings.ReplaceAll(der, "\n", "")
+	data, err := base64.StdEncoding.DecodeString(der)
+	if err != nil {
+		return nil, err
+	}
+	return NewGXx509Crl(data)
+}
+
+// X509CrlLoad loads a certificate revocation list from a PEM or DER file.
+func X509CrlLoad(path string) (*GXx509Crl, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(data), "-----BEGIN X509 CRL-----") {
+		return X509CrlFromPem(string(data))
+	}
+	return NewGXx509Crl(data)
+}